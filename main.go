@@ -43,6 +43,8 @@ import (
 	"github.com/tigera/operator/pkg/apis"
 	"github.com/tigera/operator/pkg/awssgsetup"
 	"github.com/tigera/operator/pkg/components"
+	"github.com/tigera/operator/pkg/controller/installation"
+	"github.com/tigera/operator/pkg/controller/migration/convert"
 	"github.com/tigera/operator/pkg/controller/options"
 	"github.com/tigera/operator/pkg/controller/utils"
 	"github.com/tigera/operator/pkg/dns"
@@ -81,6 +83,9 @@ func main() {
 	var showVersion bool
 	var printImages string
 	var sgSetup bool
+	var printMigration bool
+	var reportFormat string
+	var migrationKustomizeDir string
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", true,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -92,6 +97,13 @@ func main() {
 		"Print the default images the operator could deploy and exit. Possible values: list")
 	flag.BoolVar(&sgSetup, "aws-sg-setup", false,
 		"Setup Security Groups in AWS (should only be used on OpenShift).")
+	flag.BoolVar(&printMigration, "print-migration", false,
+		"Convert the existing Calico install which the operator would take over, print the result and exit. "+
+			"The FelixConfiguration settings carried forward by the migration are written to the cluster.")
+	flag.StringVar(&reportFormat, "report-format", string(convert.ResultFormatText),
+		"The format in which --print-migration prints the result. Possible values: text, json")
+	flag.StringVar(&migrationKustomizeDir, "migration-kustomize-dir", "",
+		"With --print-migration, also write the migrated resources and a kustomization.yaml to this directory.")
 	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
@@ -154,6 +166,32 @@ func main() {
 		os.Exit(0)
 	}
 
+	if printMigration {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+		cli, err := client.New(cfg, client.Options{Scheme: scheme})
+		if err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+		cs, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+		opts := installation.PrintMigrationOptions{
+			Format:       convert.ResultFormat(reportFormat),
+			KustomizeDir: migrationKustomizeDir,
+		}
+		if err := installation.PrintMigration(ctx, cs, cli, os.Stdout, opts); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr(),
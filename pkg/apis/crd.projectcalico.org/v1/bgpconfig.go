@@ -0,0 +1,74 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	KindBGPConfiguration     = "BGPConfiguration"
+	KindBGPConfigurationList = "BGPConfigurationList"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BGPConfiguration contains the configuration for any BGP routing.
+type BGPConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the BGPConfiguration.
+	Spec BGPConfigurationSpec `json:"spec,omitempty"`
+}
+
+// BGPConfigurationSpec contains the values of the BGP configuration.
+type BGPConfigurationSpec struct {
+	// LogSeverityScreen is the log severity above which logs are sent to the stdout. [Default: INFO]
+	LogSeverityScreen string `json:"logSeverityScreen,omitempty" validate:"omitempty,logLevel"`
+
+	// NodeToNodeMeshEnabled sets whether full node to node BGP mesh is enabled. [Default: true]
+	NodeToNodeMeshEnabled *bool `json:"nodeToNodeMeshEnabled,omitempty" validate:"omitempty"`
+
+	// ASNumber is the default AS number used by a node. [Default: 64512]
+	ASNumber *uint32 `json:"asNumber,omitempty" validate:"omitempty"`
+
+	// ServiceClusterIPs are the CIDR blocks from which service cluster IPs are allocated.
+	// If specified, Calico will advertise these blocks, as well as any cluster IPs within them.
+	ServiceClusterIPs []ServiceClusterIPBlock `json:"serviceClusterIPs,omitempty" validate:"omitempty,dive"`
+
+	// ServiceExternalIPs are the CIDR blocks for Kubernetes Service External IPs.
+	// Kubernetes Service ExternalIPs will only be advertised if they are within one of these blocks.
+	ServiceExternalIPs []ServiceExternalIPBlock `json:"serviceExternalIPs,omitempty" validate:"omitempty,dive"`
+}
+
+// ServiceClusterIPBlock represents a single allowed ClusterIP CIDR block.
+type ServiceClusterIPBlock struct {
+	CIDR string `json:"cidr,omitempty" validate:"omitempty,net"`
+}
+
+// ServiceExternalIPBlock represents a single allowed External IP CIDR block.
+type ServiceExternalIPBlock struct {
+	CIDR string `json:"cidr,omitempty" validate:"omitempty,net"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BGPConfigurationList contains a list of BGPConfiguration resources.
+type BGPConfigurationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []BGPConfiguration `json:"items"`
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	KindIPAMConfig     = "IPAMConfig"
+	KindIPAMConfigList = "IPAMConfigList"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IPAMConfig contains the cluster-wide configuration of Calico IPAM.
+type IPAMConfig struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the IPAMConfig.
+	Spec IPAMConfigSpec `json:"spec,omitempty"`
+}
+
+// IPAMConfigSpec contains the values of the IPAM configuration.
+type IPAMConfigSpec struct {
+	// StrictAffinity, when true, prevents IPs from being borrowed from blocks affine to other nodes. [Default: false]
+	StrictAffinity bool `json:"strictAffinity"`
+
+	// AutoAllocateBlocks sets whether new blocks are allocated to a node when its existing blocks are full. [Default: true]
+	AutoAllocateBlocks bool `json:"autoAllocateBlocks"`
+
+	// MaxBlocksPerHost, if non-zero, is the max number of blocks that can be affine to each host.
+	MaxBlocksPerHost int `json:"maxBlocksPerHost,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IPAMConfigList contains a list of IPAMConfig resources.
+type IPAMConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []IPAMConfig `json:"items"`
+}
@@ -34,12 +34,18 @@ var (
 
 func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
+		&IPAMConfig{},
+		&IPAMConfigList{},
 		&IPPool{},
 		&IPPoolList{},
 		&FelixConfiguration{},
 		&FelixConfigurationList{},
 		&KubeControllersConfiguration{},
 		&KubeControllersConfigurationList{},
+		&BGPConfiguration{},
+		&BGPConfigurationList{},
+		&ClusterInformation{},
+		&ClusterInformationList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -41,6 +41,7 @@ import (
 	"github.com/tigera/operator/pkg/controller/status"
 	"github.com/tigera/operator/pkg/controller/utils"
 	"github.com/tigera/operator/pkg/controller/utils/imageset"
+	"github.com/tigera/operator/pkg/controller/utils/podcidr"
 	"github.com/tigera/operator/pkg/dns"
 	"github.com/tigera/operator/pkg/render"
 	relasticsearch "github.com/tigera/operator/pkg/render/common/elasticsearch"
@@ -570,17 +571,7 @@ func fillDefaults(instance *operator.Installation) error {
 
 	// If not specified by the user, set the flex volume plugin location based on platform.
 	if len(instance.Spec.FlexVolumePath) == 0 {
-		if instance.Spec.KubernetesProvider == operator.ProviderOpenShift {
-			// In OpenShift 4.x, the location for flexvolume plugins has changed.
-			// See: https://bugzilla.redhat.com/show_bug.cgi?id=1667606#c5
-			instance.Spec.FlexVolumePath = "/etc/kubernetes/kubelet-plugins/volume/exec/"
-		} else if instance.Spec.KubernetesProvider == operator.ProviderGKE {
-			instance.Spec.FlexVolumePath = "/home/kubernetes/flexvolume/"
-		} else if instance.Spec.KubernetesProvider == operator.ProviderAKS {
-			instance.Spec.FlexVolumePath = "/etc/kubernetes/volumeplugins/"
-		} else {
-			instance.Spec.FlexVolumePath = "/usr/libexec/kubernetes/kubelet-plugins/volume/exec/"
-		}
+		instance.Spec.FlexVolumePath = render.DefaultFlexVolumePath(instance.Spec.KubernetesProvider)
 	}
 
 	// Default rolling update parameters.
@@ -650,7 +641,7 @@ func (r *ReconcileInstallation) Reconcile(ctx context.Context, request reconcile
 			return reconcile.Result{}, err
 		}
 		if nc {
-			install, err := convert.Convert(ctx, r.client)
+			install, err := convert.Convert(ctx, r.client, r.autoDetectedProvider)
 			if err != nil {
 				if errors.As(err, &convert.ErrIncompatibleCluster{}) {
 					r.SetDegraded("Existing Calico installation can not be managed by Tigera Operator as it is configured in a way that Operator does not currently support. Please update your existing Calico install config", err, reqLogger)
@@ -661,7 +652,14 @@ func (r *ReconcileInstallation) Reconcile(ctx context.Context, request reconcile
 				r.SetDegraded("Error converting existing installation", err, reqLogger)
 				return reconcile.Result{}, err
 			}
-			instance.Spec = overrideInstallationSpec(install.Spec, instance.Spec)
+			// Fill in the fields the user has left unset with the existing install's configuration. Fields
+			// the user has set must match the existing install, since the operator would otherwise change the
+			// running cluster as soon as it takes over.
+			if err := convert.MergeIntoInstallation(install, instance); err != nil {
+				r.SetDegraded("Existing Calico installation conflicts with the Installation resource. Please update the Installation to match the existing install", err, reqLogger)
+				// Requeue for the same reason as a convert problem above.
+				return reconcile.Result{RequeueAfter: 15 * time.Second}, nil
+			}
 		}
 	}
 
@@ -1402,12 +1400,8 @@ func mergePlatformPodCIDRs(i *operator.Installation, platformCIDRs []string) err
 		// Pools are configured on the Installation. Make sure they are compatible with
 		// the configuration set in the underlying Kubernetes platform.
 		for _, pool := range i.Spec.CalicoNetwork.IPPools {
-			within := false
-			for _, c := range platformCIDRs {
-				within = within || cidrWithinCidr(c, pool.CIDR)
-			}
-			if !within {
-				return fmt.Errorf("IPPool %v is not within the platform's configured pod network CIDR(s) %v", pool.CIDR, platformCIDRs)
+			if err := podcidr.CheckPoolWithinCIDRs(pool.CIDR, platformCIDRs); err != nil {
+				return err
 			}
 		}
 	}
@@ -1417,23 +1411,5 @@ func mergePlatformPodCIDRs(i *operator.Installation, platformCIDRs []string) err
 // cidrWithinCidr checks that all IPs in the pool passed in are within the
 // passed in CIDR
 func cidrWithinCidr(cidr, pool string) bool {
-	_, cNet, err := net.ParseCIDR(cidr)
-	if err != nil {
-		return false
-	}
-	_, pNet, err := net.ParseCIDR(pool)
-	if err != nil {
-		return false
-	}
-	ipMin := pNet.IP
-	pOnes, _ := pNet.Mask.Size()
-	cOnes, _ := cNet.Mask.Size()
-
-	// If the cidr contains the network (1st) address of the pool and the
-	// prefix on the pool is larger than or equal to the cidr prefix (the pool size is
-	// smaller than the cidr) then the pool network is within the cidr network.
-	if cNet.Contains(ipMin) && pOnes >= cOnes {
-		return true
-	}
-	return false
+	return podcidr.CIDRWithinCIDR(cidr, pool)
 }
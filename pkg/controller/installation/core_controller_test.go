@@ -17,6 +17,8 @@ package installation
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -38,6 +40,7 @@ import (
 	osconfigv1 "github.com/openshift/api/config/v1"
 	operator "github.com/tigera/operator/api/v1"
 	"github.com/tigera/operator/pkg/apis"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
 	"github.com/tigera/operator/pkg/common"
 	"github.com/tigera/operator/pkg/components"
 	"github.com/tigera/operator/pkg/controller/status"
@@ -105,6 +108,37 @@ var _ = Describe("Testing core-controller installation", func() {
 		table.Entry("IPv6 Pool larger than CIDR should fail", "fd00:1234:5600::/40", "fd00:1234::/32", false),
 		table.Entry("IPv6 Non overlapping CIDR and pool should fail", "fd00:1234::/32", "fd00:5678::/32", false),
 		table.Entry("IPv6 CIDR with smaller pool", "fd00:1234::/32", "fd00:1234:5600::/40", true),
+		table.Entry("IPv4-mapped IPv6 pool within IPv4 CIDR should fail", "192.168.0.0/16", "::ffff:192.168.1.0/120", false),
+		table.Entry("IPv4 pool within IPv4-mapped IPv6 CIDR should fail", "::ffff:192.168.0.0/112", "192.168.1.0/24", false),
+	)
+
+	table.DescribeTable("test mergePlatformPodCIDRs with pools and platform CIDRs of mixed families",
+		func(pools, platformCIDRs []string, expectedErr string) {
+			i := &operator.Installation{Spec: operator.InstallationSpec{CalicoNetwork: &operator.CalicoNetworkSpec{}}}
+			for _, p := range pools {
+				i.Spec.CalicoNetwork.IPPools = append(i.Spec.CalicoNetwork.IPPools, operator.IPPool{CIDR: p})
+			}
+			err := mergePlatformPodCIDRs(i, platformCIDRs)
+			if expectedErr == "" {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expectedErr))
+			}
+		},
+
+		table.Entry("Dual stack pools within dual stack CIDRs",
+			[]string{"192.168.0.0/24", "fd00:1234::/64"}, []string{"fd00:1234::/48", "192.168.0.0/16"}, ""),
+		table.Entry("IPv4 pool with only an IPv6 CIDR",
+			[]string{"192.168.0.0/24"}, []string{"fd00:1234::/48"},
+			"IPPool 192.168.0.0/24 is an IPv4 pool but the platform's configured pod network CIDR(s) [fd00:1234::/48] have no IPv4 CIDR"),
+		table.Entry("IPv6 pool with only an IPv4 CIDR",
+			[]string{"fd00:1234::/64"}, []string{"192.168.0.0/16"},
+			"IPPool fd00:1234::/64 is an IPv6 pool but the platform's configured pod network CIDR(s) [192.168.0.0/16] have no IPv6 CIDR"),
+		table.Entry("IPv4-mapped IPv6 pool with only an IPv4 CIDR",
+			[]string{"::ffff:192.168.1.0/120"}, []string{"192.168.0.0/16"}, "have no IPv6 CIDR"),
+		table.Entry("IPv4 pool outside of the IPv4 CIDR",
+			[]string{"10.0.0.0/24"}, []string{"fd00:1234::/48", "192.168.0.0/16"}, "is not within the platform's configured pod network CIDR(s)"),
 	)
 	var defaultMTU int32 = 1440
 	var twentySix int32 = 26
@@ -717,4 +751,51 @@ var _ = Describe("Testing core-controller installation", func() {
 			test.VerifyCert(internalManagerTLSSecret, render.ManagerInternalSecretKeyName, render.ManagerInternalSecretCertName, dnsNames...)
 		})
 	})
+
+	Context("existing Calico install", func() {
+		var c client.Client
+		var ctx context.Context
+		var r ReconcileInstallation
+		var mockStatus *status.MockStatus
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(apis.AddToScheme(scheme)).NotTo(HaveOccurred())
+			Expect(appsv1.SchemeBuilder.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+			Expect(corev1.SchemeBuilder.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+			Expect(operator.SchemeBuilder.AddToScheme(scheme)).NotTo(HaveOccurred())
+
+			pool := crdv1.NewIPPool()
+			pool.Spec = crdv1.IPPoolSpec{CIDR: "192.168.4.0/24", IPIPMode: crdv1.IPIPModeAlways, NATOutgoing: true}
+			objs := append(calicoManifest(), pool, &crdv1.FelixConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+			c = fake.NewFakeClientWithScheme(scheme, objs...)
+			ctx = context.Background()
+
+			mockStatus = &status.MockStatus{}
+			mockStatus.On("OnCRFound").Return()
+
+			r = ReconcileInstallation{
+				client:               c,
+				scheme:               scheme,
+				autoDetectedProvider: operator.ProviderNone,
+				status:               mockStatus,
+				namespaceMigration:   &fakeNamespaceMigration{},
+			}
+		})
+
+		It("should degrade if the Installation conflicts with the existing install", func() {
+			Expect(c.Create(ctx, &operator.Installation{
+				ObjectMeta: metav1.ObjectMeta{Name: "default"},
+				Spec:       operator.InstallationSpec{FlexVolumePath: "/etc/flex"},
+			})).NotTo(HaveOccurred())
+			mockStatus.On("SetDegraded", mock.Anything, mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, "spec.flexVolumePath")
+			})).Return()
+
+			res, err := r.Reconcile(ctx, reconcile.Request{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(15 * time.Second))
+			mockStatus.AssertExpectations(GinkgoT())
+		})
+	})
 })
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	operator "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/controller/migration/convert"
+	"github.com/tigera/operator/pkg/controller/utils"
+)
+
+// Migrate builds the Installation which the operator would use to take over an existing Calico
+// install that it does not manage. It detects the Kubernetes provider, converts the existing
+// manifests, merges in the provider's configuration, fills in the defaults and validates the result,
+// in the same order and with the same checks as the installation controller. If no existing install is found, a nil Installation is returned.
+// The returned Report is set whenever the manifests were read, including when the conversion failed.
+func Migrate(ctx context.Context, cs kubernetes.Interface, cli client.Client) (*operator.Installation, *convert.Report, error) {
+	provider, err := utils.AutoDiscoverProvider(ctx, cs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	install, report, err := convert.ConvertWithReport(ctx, cli, convert.Options{Provider: provider})
+	if err != nil || install == nil {
+		return nil, report, err
+	}
+	// the operator only reads the Installation of this name.
+	install.Name = utils.DefaultInstanceKey.Name
+
+	var detected []operator.IPPool
+	if install.Spec.CalicoNetwork != nil {
+		detected = append(detected, install.Spec.CalicoNetwork.IPPools...)
+	}
+
+	if err := updateInstallationWithDefaults(ctx, cli, install, provider); err != nil {
+		return nil, report, err
+	}
+	warnDefaultedNATOutgoing(detected, install, report)
+
+	if err := validateCustomResource(install); err != nil {
+		return nil, report, fmt.Errorf("migrated Installation is invalid: %s", err.Error())
+	}
+	return install, report, nil
+}
+
+// PrintMigrationOptions configures PrintMigration.
+type PrintMigrationOptions struct {
+	// Format is the format of the printed result, it defaults to text.
+	Format convert.ResultFormat
+
+	// KustomizeDir is the directory to which the migrated resources are written as a
+	// kustomization if the migration succeeds. Nothing is written if it is empty.
+	KustomizeDir string
+}
+
+// PrintMigration runs Migrate and writes its result to w. It is used by the operator's
+// --print-migration flag to preview a takeover. The error from the migration is returned once the
+// result has been written, including when no existing install was found.
+func PrintMigration(ctx context.Context, cs kubernetes.Interface, cli client.Client, w io.Writer, opts PrintMigrationOptions) error {
+	install, report, err := Migrate(ctx, cs, cli)
+	if err == nil && install == nil {
+		err = fmt.Errorf("no existing Calico install was found to migrate")
+	}
+	if err == nil && opts.KustomizeDir != "" {
+		err = writeMigrationKustomization(ctx, cli, install, opts.KustomizeDir)
+	}
+	if werr := convert.WriteResult(w, opts.Format, convert.NewResult(install, report, err)); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// writeMigrationKustomization writes the migrated resources to dir as a kustomization.
+func writeMigrationKustomization(ctx context.Context, cli client.Client, install *operator.Installation, dir string) error {
+	objs, err := convert.MigratedResources(ctx, cli, install)
+	if err != nil {
+		return err
+	}
+	if err := convert.WriteKustomization(dir, cli.Scheme(), objs...); err != nil {
+		return fmt.Errorf("failed to write the kustomization to %s: %v", dir, err)
+	}
+	return nil
+}
+
+// warnDefaultedNATOutgoing records a warning for each converted pool whose NATOutgoing was left unset,
+// and so was filled in by the defaulting. The conversion always sets NATOutgoing from the existing
+// pool, so an unset value points to a gap in the detection which the default may silently paper over.
+func warnDefaultedNATOutgoing(detected []operator.IPPool, install *operator.Installation, report *convert.Report) {
+	if install.Spec.CalicoNetwork == nil {
+		return
+	}
+	for _, d := range detected {
+		if d.NATOutgoing != "" {
+			continue
+		}
+		for _, p := range install.Spec.CalicoNetwork.IPPools {
+			if p.CIDR == d.CIDR && p.NATOutgoing != "" {
+				report.Warnings = append(report.Warnings, convert.Warning{
+					Component: convert.ComponentIPPools,
+					Message: fmt.Sprintf("natOutgoing was not detected for IPPool %s and has been defaulted to %s, "+
+						"check that this matches the existing pool", d.CIDR, p.NATOutgoing),
+				})
+			}
+		}
+	}
+}
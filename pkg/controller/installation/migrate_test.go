@@ -0,0 +1,541 @@
+// Copyright (c) 2020 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	operator "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/apis"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+	"github.com/tigera/operator/pkg/controller/migration/convert"
+)
+
+var _ = Describe("Migrate", func() {
+	var (
+		ctx  = context.Background()
+		pool *crdv1.IPPool
+	)
+
+	BeforeEach(func() {
+		Expect(apis.AddToScheme(kscheme.Scheme)).To(Succeed())
+		pool = crdv1.NewIPPool()
+		pool.Spec = crdv1.IPPoolSpec{
+			CIDR:        "192.168.4.0/24",
+			IPIPMode:    crdv1.IPIPModeAlways,
+			NATOutgoing: true,
+		}
+	})
+
+	kubeadmConfig := func(podSubnet string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: kubeadmConfigMap, Namespace: metav1.NamespaceSystem},
+			Data:       map[string]string{"ClusterConfiguration": "networking:\n  podSubnet: " + podSubnet + "\n"},
+		}
+	}
+
+	migrate := func(objs ...runtime.Object) (*operator.Installation, error) {
+		objs = append(objs, pool, &crdv1.FelixConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+		cli := fake.NewFakeClientWithScheme(kscheme.Scheme, objs...)
+		install, _, err := Migrate(ctx, kfake.NewSimpleClientset(), cli)
+		return install, err
+	}
+
+	It("should return nothing if there is no existing install", func() {
+		install, report, err := Migrate(ctx, kfake.NewSimpleClientset(), fake.NewFakeClientWithScheme(kscheme.Scheme))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(install).To(BeNil())
+		Expect(report).To(BeNil())
+	})
+
+	Context("PrintMigration", func() {
+		var objs []runtime.Object
+
+		BeforeEach(func() {
+			objs = append(calicoManifest(), kubeadmConfig("192.168.0.0/16"), pool, &crdv1.FelixConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+		})
+
+		It("should print the result of the migration", func() {
+			var out bytes.Buffer
+			cli := fake.NewFakeClientWithScheme(kscheme.Scheme, objs...)
+			Expect(PrintMigration(ctx, kfake.NewSimpleClientset(), cli, &out, PrintMigrationOptions{})).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("migration succeeded"))
+		})
+
+		It("should print the result as JSON", func() {
+			var out bytes.Buffer
+			cli := fake.NewFakeClientWithScheme(kscheme.Scheme, objs...)
+			Expect(PrintMigration(ctx, kfake.NewSimpleClientset(), cli, &out, PrintMigrationOptions{Format: convert.ResultFormatJSON})).To(Succeed())
+			result := convert.Result{}
+			Expect(json.Unmarshal(out.Bytes(), &result)).To(Succeed())
+			Expect(result.Version).To(Equal(convert.ResultVersion))
+			Expect(result.Installation.Spec.Variant).To(Equal(operator.Calico))
+			Expect(result.Error).To(BeEmpty())
+		})
+
+		It("should write the migrated resources as a kustomization", func() {
+			dir, err := ioutil.TempDir("", "migration")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			var out bytes.Buffer
+			cli := fake.NewFakeClientWithScheme(kscheme.Scheme, objs...)
+			Expect(PrintMigration(ctx, kfake.NewSimpleClientset(), cli, &out, PrintMigrationOptions{KustomizeDir: dir})).To(Succeed())
+			Expect(filepath.Join(dir, "kustomization.yaml")).To(BeAnExistingFile())
+			Expect(filepath.Join(dir, "installation-default.yaml")).To(BeAnExistingFile())
+		})
+
+		It("should print and return an error if there is no existing install", func() {
+			var out bytes.Buffer
+			err := PrintMigration(ctx, kfake.NewSimpleClientset(), fake.NewFakeClientWithScheme(kscheme.Scheme), &out, PrintMigrationOptions{})
+			Expect(err).To(MatchError("no existing Calico install was found to migrate"))
+			Expect(out.String()).To(Equal("error: no existing Calico install was found to migrate\n"))
+		})
+	})
+
+	It("should convert the manifest and fill in defaults", func() {
+		install, err := migrate(append(calicoManifest(), kubeadmConfig("192.168.0.0/16"))...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(install.Spec.Variant).To(Equal(operator.Calico))
+		Expect(install.Spec.CNI.Type).To(Equal(operator.PluginCalico))
+		Expect(install.Spec.CNI.IPAM.Type).To(Equal(operator.IPAMPluginCalico))
+		Expect(*install.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1440))
+		Expect(install.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
+		Expect(install.Spec.CalicoNetwork.IPPools[0].CIDR).To(Equal("192.168.4.0/24"))
+		Expect(install.Spec.CalicoNetwork.IPPools[0].Encapsulation).To(Equal(operator.EncapsulationIPIP))
+		Expect(install.Spec.CalicoNetwork.IPPools[0].BlockSize).ToNot(BeNil())
+		Expect(install.Spec.FlexVolumePath).To(Equal("/usr/libexec/kubernetes/kubelet-plugins/volume/exec/"))
+	})
+
+	It("should error if the pools are outside of the kubeadm pod network", func() {
+		_, err := migrate(append(calicoManifest(), kubeadmConfig("10.0.0.0/16"))...)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("kubeadm pod network CIDR"))
+	})
+
+	Context("with CALICO_IPV4POOL_CIDR", func() {
+		withPoolCIDR := func(cidr string) []runtime.Object {
+			objs := calicoManifest()
+			ds := objs[1].(*appsv1.DaemonSet)
+			ds.Spec.Template.Spec.Containers[0].Env = append(ds.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "CALICO_IPV4POOL_CIDR", Value: cidr})
+			return objs
+		}
+
+		It("should have a single pool when the env var matches the platform CIDR", func() {
+			pool.Spec.CIDR = "192.168.0.0/16"
+			install, err := migrate(append(withPoolCIDR("192.168.0.0/16"), kubeadmConfig("192.168.0.0/16"))...)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(install.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
+			Expect(install.Spec.CalicoNetwork.IPPools[0].CIDR).To(Equal("192.168.0.0/16"))
+		})
+
+		It("should use the existing pool when the env var is within the platform CIDR", func() {
+			install, err := migrate(append(withPoolCIDR("192.168.4.0/24"), kubeadmConfig("192.168.0.0/16"))...)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(install.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
+			Expect(install.Spec.CalicoNetwork.IPPools[0].CIDR).To(Equal("192.168.4.0/24"))
+		})
+
+		It("should error when the env var differs from the platform CIDR", func() {
+			_, err := migrate(append(withPoolCIDR("10.0.0.0/16"), kubeadmConfig("192.168.0.0/16"))...)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("CALICO_IPV4POOL_CIDR=10.0.0.0/16 is not within the kubeadm pod network CIDR(s) [192.168.0.0/16]"))
+		})
+	})
+
+	DescribeTable("should keep a bird pool without encapsulation unencapsulated", func(ipip crdv1.IPIPMode, vxlan crdv1.VXLANMode) {
+		pool.Spec.IPIPMode = ipip
+		pool.Spec.VXLANMode = vxlan
+		install, err := migrate(append(calicoManifest(), kubeadmConfig("192.168.0.0/16"))...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*install.Spec.CalicoNetwork.BGP).To(Equal(operator.BGPEnabled))
+		Expect(install.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
+		Expect(install.Spec.CalicoNetwork.IPPools[0].Encapsulation).To(Equal(operator.EncapsulationNone))
+	},
+		Entry("both disabled", crdv1.IPIPModeNever, crdv1.VXLANModeNever),
+		Entry("both absent", crdv1.IPIPMode(""), crdv1.VXLANMode("")),
+	)
+
+	It("should not warn about defaulted natOutgoing when it was detected", func() {
+		objs := append(calicoManifest(), kubeadmConfig("192.168.0.0/16"), pool,
+			&crdv1.FelixConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+		_, report, err := Migrate(ctx, kfake.NewSimpleClientset(), fake.NewFakeClientWithScheme(kscheme.Scheme, objs...))
+		Expect(err).ToNot(HaveOccurred())
+		for _, w := range report.Warnings {
+			Expect(w.Message).ToNot(ContainSubstring("natOutgoing was not detected"))
+		}
+	})
+
+	It("should warn when the defaults fill in an undetected natOutgoing", func() {
+		// simulate a detection gap by leaving natOutgoing unset on one of the converted pools.
+		detected := []operator.IPPool{
+			{CIDR: "192.168.4.0/24", NATOutgoing: operator.NATOutgoingDisabled},
+			{CIDR: "fd00::/64"},
+		}
+		install := &operator.Installation{Spec: operator.InstallationSpec{CalicoNetwork: &operator.CalicoNetworkSpec{
+			IPPools: []operator.IPPool{
+				{CIDR: "192.168.4.0/24", NATOutgoing: operator.NATOutgoingDisabled},
+				{CIDR: "fd00::/64", NATOutgoing: operator.NATOutgoingDisabled},
+			},
+		}}}
+		report := &convert.Report{}
+		warnDefaultedNATOutgoing(detected, install, report)
+		Expect(report.Warnings).To(ConsistOf(convert.Warning{
+			Component: convert.ComponentIPPools,
+			Message:   "natOutgoing was not detected for IPPool fd00::/64 and has been defaulted to Disabled, check that this matches the existing pool",
+		}))
+	})
+
+	Context("with an OnDelete updateStrategy", func() {
+		onDelete := func() []runtime.Object {
+			objs := append(calicoManifest(), kubeadmConfig("192.168.0.0/16"))
+			ds := objs[1].(*appsv1.DaemonSet)
+			ds.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType}
+			return objs
+		}
+
+		It("should warn and leave the strategy for the user to change", func() {
+			objs := append(onDelete(), pool, &crdv1.FelixConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+			_, report, err := Migrate(ctx, kfake.NewSimpleClientset(), fake.NewFakeClientWithScheme(kscheme.Scheme, objs...))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("NodeUpdateStrategy.type"))
+			Expect(report.Warnings).To(ContainElement(WithTransform(func(w convert.Warning) string { return w.Message },
+				ContainSubstring("updateStrategy is OnDelete"))))
+		})
+	})
+
+	It("should return the report when the conversion fails", func() {
+		objs := calicoManifest()
+		ds := objs[1].(*appsv1.DaemonSet)
+		ds.Spec.Template.Spec.Containers[0].Env = append(ds.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "UNKNOWN", Value: "true"})
+		objs = append(objs, pool, &crdv1.FelixConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+		install, report, err := Migrate(ctx, kfake.NewSimpleClientset(), fake.NewFakeClientWithScheme(kscheme.Scheme, objs...))
+		Expect(err).To(HaveOccurred())
+		Expect(install).To(BeNil())
+		Expect(report).ToNot(BeNil())
+	})
+})
+
+// calicoManifest returns the resources of a Calico v3.15 manifest install.
+func calicoManifest() []runtime.Object {
+	fileOrCreate := corev1.HostPathFileOrCreate
+	directoryOrCreate := corev1.HostPathDirectoryOrCreate
+	isPrivileged := true
+	var terminationGracePeriod int64 = 0
+	maxUnav := intstr.FromInt(1)
+	updateStrat := appsv1.RollingUpdateDaemonSet{MaxUnavailable: &maxUnav}
+	var _1 int32 = 1
+	return []runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "calico-config",
+				Namespace: "kube-system",
+			},
+			Data: map[string]string{
+				"typha_service_name": "none",
+				"calico_backend":     "bird",
+				"veth_mtu":           "1440",
+				"cni_network_config": `{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [
+    {
+      "type": "calico",
+      "log_level": "info",
+      "datastore_type": "kubernetes",
+      "nodename": "__KUBERNETES_NODE_NAME__",
+      "mtu": __CNI_MTU__,
+      "ipam": {
+          "type": "calico-ipam"
+      },
+      "policy": {
+          "type": "k8s"
+      },
+      "kubernetes": {
+          "kubeconfig": "__KUBECONFIG_FILEPATH__"
+      }
+    },
+    {
+      "type": "portmap",
+      "snat": true,
+      "capabilities": {"portMappings": true}
+    },
+    {
+      "type": "bandwidth",
+      "capabilities": {"bandwidth": true}
+    }
+  ]
+}`,
+			},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "calico-node",
+				Namespace: "kube-system",
+				Labels: map[string]string{
+					"k8s-app": "calico-node",
+				},
+			},
+			Spec: appsv1.DaemonSetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "calico-node"}},
+				UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+					Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+					RollingUpdate: &updateStrat,
+				},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							"k8s-app": "calico-node",
+						},
+					},
+					Spec: corev1.PodSpec{
+						NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+						HostNetwork:  true,
+						Tolerations: []corev1.Toleration{
+							{Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+							{Operator: corev1.TolerationOpExists, Key: "CriticalAddonsOnly"},
+							{Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+						},
+						ServiceAccountName:            "calico-node",
+						TerminationGracePeriodSeconds: &terminationGracePeriod,
+						PriorityClassName:             "system-node-critical",
+						InitContainers: []corev1.Container{{
+							Name:    "upgrade-ipam",
+							Image:   "calico/cni:v3.15.1",
+							Command: []string{"/opt/cni/bin/calico-ipam", "-upgrade"},
+							Env: []corev1.EnvVar{
+								{Name: "KUBERNETES_NODE_NAME",
+									ValueFrom: &corev1.EnvVarSource{
+										FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+									},
+								},
+								{Name: "CALICO_NETWORKING_BACKEND",
+									ValueFrom: &corev1.EnvVarSource{
+										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "calico-config"},
+											Key:                  "calico_backend",
+										},
+									},
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{MountPath: "/var/lib/cni/networks", Name: "host-local-net-dir"},
+								{MountPath: "/host/opt/cni/bin", Name: "cni-bin-dir"},
+							},
+							SecurityContext: &corev1.SecurityContext{Privileged: &isPrivileged},
+						}, {
+							Name:    "install-cni",
+							Image:   "calico/cni:v3.15.1",
+							Command: []string{"/install-cni.sh"},
+							Env: []corev1.EnvVar{
+								{Name: "CNI_CONF_NAME", Value: "10-calico.conflist"},
+								{Name: "CNI_NETWORK_CONFIG",
+									ValueFrom: &corev1.EnvVarSource{
+										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "calico-config"},
+											Key:                  "cni_network_config",
+										},
+									},
+								},
+								{Name: "KUBERNETES_NODE_NAME",
+									ValueFrom: &corev1.EnvVarSource{
+										FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+									},
+								},
+								{Name: "CNI_MTU",
+									ValueFrom: &corev1.EnvVarSource{
+										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "calico-config"},
+											Key:                  "veth_mtu",
+										},
+									},
+								},
+								{Name: "SLEEP", Value: "false"},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{MountPath: "/host/opt/cni/bin", Name: "cni-bin-dir"},
+								{MountPath: "/host/etc/cni/net.d", Name: "cni-net-dir"},
+							},
+							SecurityContext: &corev1.SecurityContext{Privileged: &isPrivileged},
+						}, {
+							Name:  "flexvol-driver",
+							Image: "calico/pod2daemon-flexvol:v3.15.1",
+							VolumeMounts: []corev1.VolumeMount{
+								{MountPath: "/host/driver", Name: "flexvol-driver-host"},
+							},
+							SecurityContext: &corev1.SecurityContext{Privileged: &isPrivileged},
+						}},
+						Containers: []corev1.Container{{
+							Name:  "calico-node",
+							Image: "calico/node:v3.15.1",
+							Env: []corev1.EnvVar{
+								{Name: "DATASTORE_TYPE", Value: "kubernetes"},
+								{Name: "WAIT_FOR_DATASTORE", Value: "true"},
+								{
+									Name: "NODENAME",
+									ValueFrom: &corev1.EnvVarSource{
+										FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+									},
+								},
+								{Name: "CALICO_NETWORKING_BACKEND",
+									ValueFrom: &corev1.EnvVarSource{
+										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "calico-config"},
+											Key:                  "calico_backend",
+										},
+									},
+								},
+								{Name: "CLUSTER_TYPE", Value: "k8s,bgp"},
+								{Name: "IP", Value: "autodetect"},
+								{Name: "CALICO_IPV4POOL_IPIP", Value: "Always"},
+								{Name: "CALICO_IPV4POOL_VXLAN", Value: "Never"},
+								{Name: "FELIX_IPINIPMTU",
+									ValueFrom: &corev1.EnvVarSource{
+										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "calico-config"},
+											Key:                  "veth_mtu",
+										},
+									},
+								},
+								{Name: "FELIX_VXLANMTU",
+									ValueFrom: &corev1.EnvVarSource{
+										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "calico-config"},
+											Key:                  "veth_mtu",
+										},
+									},
+								},
+								{Name: "FELIX_WIREGUARDMTU",
+									ValueFrom: &corev1.EnvVarSource{
+										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "calico-config"},
+											Key:                  "veth_mtu",
+										},
+									},
+								},
+								{Name: "CALICO_DISABLE_FILE_LOGGING", Value: "true"},
+								{Name: "FELIX_DEFAULTENDPOINTTOHOSTACTION", Value: "ACCEPT"},
+								{Name: "FELIX_IPV6SUPPORT", Value: "false"},
+								{Name: "FELIX_LOGSEVERITYSCREEN", Value: "info"},
+								{Name: "FELIX_HEALTHENABLED", Value: "true"},
+							},
+							SecurityContext: &corev1.SecurityContext{Privileged: &isPrivileged},
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU: resource.MustParse("250m"),
+								},
+							},
+							LivenessProbe: &corev1.Probe{
+								Handler: corev1.Handler{Exec: &corev1.ExecAction{
+									Command: []string{"/bin/calico-node", "-felix-live", "-bird-live"}}},
+								PeriodSeconds:       10,
+								InitialDelaySeconds: 10,
+								FailureThreshold:    6,
+							},
+							ReadinessProbe: &corev1.Probe{
+								Handler: corev1.Handler{Exec: &corev1.ExecAction{
+									Command: []string{"/bin/calico-node", "-felix-ready", "-bird-ready"}}},
+								PeriodSeconds: 10,
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{MountPath: "/lib/modules", Name: "lib-modules", ReadOnly: true},
+								{MountPath: "/run/xtables.lock", Name: "xtables-lock", ReadOnly: false},
+								{MountPath: "/var/run/calico", Name: "var-run-calico", ReadOnly: false},
+								{MountPath: "/var/lib/calico", Name: "var-lib-calico", ReadOnly: false},
+								{MountPath: "/var/run/nodeagent", Name: "policysync"},
+							},
+						}},
+						Volumes: []corev1.Volume{
+							{Name: "lib-modules", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/lib/modules"}}},
+							{Name: "var-run-calico", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/calico"}}},
+							{Name: "var-lib-calico", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/lib/calico"}}},
+							{Name: "xtables-lock", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/run/xtables.lock", Type: &fileOrCreate}}},
+							{Name: "cni-bin-dir", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/opt/cni/bin"}}},
+							{Name: "cni-net-dir", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/etc/cni/net.d"}}},
+							{Name: "host-local-net-dir", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/lib/cni/networks"}}},
+							{Name: "policysync", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/nodeagent", Type: &directoryOrCreate}}},
+							{Name: "flexvol-driver-host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/usr/libexec/kubernetes/kubelet-plugins/volume/exec/nodeagent~uds", Type: &directoryOrCreate}}},
+						},
+					},
+				},
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "calico-kube-controllers",
+				Namespace: "kube-system",
+				Labels: map[string]string{
+					"k8s-app": "calico-kube-controllers",
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &_1,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "calico-kube-controllers"}},
+				Strategy: appsv1.DeploymentStrategy{
+					Type: appsv1.RecreateDeploymentStrategyType,
+				},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "calico-kube-controllers",
+						Namespace: "kube-system",
+						Labels: map[string]string{
+							"k8s-app": "calico-kue-controllers",
+						},
+					},
+					Spec: corev1.PodSpec{
+						NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+						Tolerations: []corev1.Toleration{
+							{Operator: corev1.TolerationOpExists, Key: "CriticalAddonsOnly"},
+							{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule},
+						},
+						ServiceAccountName: "calico-kube-controllers",
+						PriorityClassName:  "system-node-critical",
+						Containers: []corev1.Container{{
+							Name:  "calico-kube-controllers",
+							Image: "calico/kube-controllers:v3.15.1",
+							Env: []corev1.EnvVar{
+								{Name: "ENABLED_CONTROLLERS", Value: "node"},
+								{Name: "DATASTORE_TYPE", Value: "kubernetes"},
+							},
+							ReadinessProbe: &corev1.Probe{
+								Handler: corev1.Handler{Exec: &corev1.ExecAction{
+									Command: []string{"/usr/bin/check-status", "-r"}}},
+							},
+						}},
+					},
+				},
+			},
+		},
+	}
+}
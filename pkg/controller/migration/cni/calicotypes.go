@@ -1,5 +1,12 @@
 package cni
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // CalicoConf stores the common network config for Calico CNI plugin
 type CalicoConf struct {
 	CNIVersion string `json:"cniVersion,omitempty"`
@@ -21,7 +28,12 @@ type CalicoConf struct {
 	EtcdEndpoints        string            `json:"etcd_endpoints"`
 	EtcdDiscoverySrv     string            `json:"etcd_discovery_srv"`
 	LogLevel             string            `json:"log_level"`
+	LogFileMaxSize       int               `json:"log_file_max_size,omitempty"`
+	LogFileMaxAge        int               `json:"log_file_max_age,omitempty"`
+	LogFileMaxCount      int               `json:"log_file_max_count,omitempty"`
 	FeatureControl       FeatureControl    `json:"feature_control"`
+	Policy               Policy            `json:"policy,omitempty"`
+	Kubernetes           Kubernetes        `json:"kubernetes,omitempty"`
 	EtcdScheme           string            `json:"etcd_scheme"`
 	EtcdKeyFile          string            `json:"etcd_key_file"`
 	EtcdCertFile         string            `json:"etcd_cert_file"`
@@ -30,6 +42,48 @@ type CalicoConf struct {
 	IncludeDefaultRoutes bool              `json:"include_default_routes,omitempty"`
 }
 
+// UnmarshalJSON accepts an mtu given as a string, e.g. "mtu": "1440", as well as a number,
+// since CNI_MTU is substituted into hand-written templates either way.
+func (c *CalicoConf) UnmarshalJSON(b []byte) error {
+	type calicoConf CalicoConf
+	var raw struct {
+		*calicoConf
+		MTU json.RawMessage `json:"mtu"`
+	}
+	raw.calicoConf = (*calicoConf)(c)
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw.MTU) == 0 || string(raw.MTU) == "null" {
+		return nil
+	}
+
+	if err := json.Unmarshal(raw.MTU, &c.MTU); err == nil {
+		return nil
+	}
+	var mtu string
+	if err := json.Unmarshal(raw.MTU, &mtu); err != nil {
+		return fmt.Errorf("mtu %s is not a number or a string", raw.MTU)
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(mtu))
+	if err != nil {
+		return fmt.Errorf("mtu \"%s\" is not a number", mtu)
+	}
+	c.MTU = v
+	return nil
+}
+
+// Policy is a struct to hold policy config.
+type Policy struct {
+	PolicyType string `json:"type"`
+	K8sAPIRoot string `json:"k8s_api_root"`
+}
+
+// Kubernetes is a struct to hold the config the plugin uses to access the Kubernetes API.
+type Kubernetes struct {
+	Kubeconfig string `json:"kubeconfig"`
+}
+
 // ContainerSettings contains configuration options
 // to be configured inside the container namespace.
 type ContainerSettings struct {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"strings"
 
 	"github.com/containernetworking/cni/libcni"
@@ -61,6 +62,8 @@ func Parse(cniConfig string) (NetworkComponents, error) {
 	// convert to a map for simpler checks
 	plugins := map[string]*libcni.NetworkConfig{}
 	for _, plugin := range conflist.Plugins {
+		// the network name is chosen by the author of the config (e.g. k8s-pod-network), and is shared
+		// by every plugin in the conflist, so the calico plugin can only be identified by its type.
 		if plugin.Network.Type == "calico" {
 			if err := json.Unmarshal(plugin.Bytes, &c.CalicoConfig); err != nil {
 				return c, fmt.Errorf("failed to parse calico cni config: %w", err)
@@ -99,16 +102,47 @@ func unmarshalCNIConfList(cniConfig string) (*libcni.NetworkConfigList, error) {
 	// unrendered CNI_NETWORK_CONFIG is often technically invalid json because it uses
 	// __CNI_MTU__ as an integer, e.g. { "mtu": __CNI_MTU__ }
 	// in such cases, replace it with a placeholder, so that we can json load it, and still
-	// know that it should be substituted later during validation.
+	// know that it should be substituted later during validation. install-cni substitutes every
+	// occurrence, so the placeholder may also appear quoted, or in escaped config as \"__CNI_MTU__\",
+	// in which case the quotes are dropped along with it so that it still loads as an integer.
 	if strings.Contains(cniConfig, "__CNI_MTU__") {
-		cniConfig = strings.Replace(cniConfig, "__CNI_MTU__", "-1", -1)
+		cniConfig = strings.NewReplacer(
+			`\"__CNI_MTU__\"`, "-1",
+			`"__CNI_MTU__"`, "-1",
+			"__CNI_MTU__", "-1",
+		).Replace(cniConfig)
+	}
+	cniConfig = unescapeCNIConfig(cniConfig)
+	cniConfig = normalizeCNIConfList(cniConfig)
+
+	// libcni dereferences a nil config when the config or one of its plugins is json null,
+	// so anything which isn't an object is rejected before it is handed over.
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(cniConfig), &raw); err != nil {
+		return nil, fmt.Errorf("CNI config is not a json object: %w", err)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("CNI config is not a json object")
+	}
+	if plugins, ok := raw["plugins"].([]interface{}); ok {
+		for i, p := range plugins {
+			if _, ok := p.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("plugin %d of the CNI config is not a json object", i)
+			}
+		}
 	}
 
-	confList, err := libcni.ConfListFromBytes([]byte(cniConfig))
-	if err == nil {
+	confList, listErr := libcni.ConfListFromBytes([]byte(cniConfig))
+	if listErr == nil {
 		return confList, nil
 	}
 
+	// config with a plugins key can only be a conflist, so the conflist error is
+	// the one which identifies what is wrong with it.
+	if _, ok := raw["plugins"]; ok {
+		return nil, listErr
+	}
+
 	// if an error occured, try parsing it as a single item
 	conf, err := libcni.ConfFromBytes([]byte(cniConfig))
 	if err != nil {
@@ -117,3 +151,86 @@ func unmarshalCNIConfList(cniConfig string) (*libcni.NetworkConfigList, error) {
 
 	return libcni.ConfListFromConf(conf)
 }
+
+// normalizeCNIConfList coerces structural variants of a conflist which libcni rejects into a valid conflist:
+//   - a conflist wrapped in a single top-level key, e.g. {"conflist": {"name": ..., "plugins": [...]}}
+//   - a plugins key holding a single plugin rather than a list of them
+//   - a cniVersion given as a number rather than a string, at the top level or on a plugin
+//
+// Config which is not a json object, or which needs none of these, is returned unchanged.
+func normalizeCNIConfList(cniConfig string) string {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(cniConfig), &raw); err != nil {
+		return cniConfig
+	}
+	changed := false
+
+	if _, ok := raw["plugins"]; !ok && len(raw) == 1 {
+		for _, v := range raw {
+			if inner, ok := v.(map[string]interface{}); ok {
+				if _, ok := inner["plugins"]; ok {
+					raw = inner
+					changed = true
+				}
+			}
+		}
+	}
+
+	if plugin, ok := raw["plugins"].(map[string]interface{}); ok {
+		raw["plugins"] = []interface{}{plugin}
+		changed = true
+	}
+
+	if normalizeCNIVersion(raw) {
+		changed = true
+	}
+	if plugins, ok := raw["plugins"].([]interface{}); ok {
+		for _, p := range plugins {
+			if plugin, ok := p.(map[string]interface{}); ok && normalizeCNIVersion(plugin) {
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return cniConfig
+	}
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return cniConfig
+	}
+	return string(normalized)
+}
+
+// normalizeCNIVersion converts a numeric cniVersion in the given config into a string,
+// returning true if it was changed.
+func normalizeCNIVersion(conf map[string]interface{}) bool {
+	v, ok := conf["cniVersion"].(float64)
+	if !ok {
+		return false
+	}
+	conf["cniVersion"] = strconv.FormatFloat(v, 'f', -1, 64)
+	return true
+}
+
+// unescapeCNIConfig handles CNI config which has been embedded in a manifest as an escaped JSON
+// string, either quoted (e.g. "{\"name\": ...}") or unquoted with literal \n and \" escapes.
+// Config which is not escaped is returned unchanged.
+func unescapeCNIConfig(cniConfig string) string {
+	trimmed := strings.TrimSpace(cniConfig)
+
+	var unescaped string
+	if json.Valid([]byte(trimmed)) {
+		// valid json which is itself a string holds the config.
+		if err := json.Unmarshal([]byte(trimmed), &unescaped); err != nil {
+			return cniConfig
+		}
+	} else if err := json.Unmarshal([]byte(`"`+trimmed+`"`), &unescaped); err != nil {
+		return cniConfig
+	}
+
+	if !json.Valid([]byte(unescaped)) {
+		return cniConfig
+	}
+	return unescaped
+}
@@ -0,0 +1,134 @@
+//go:build go1.18
+// +build go1.18
+
+package cni
+
+import (
+	"testing"
+)
+
+// cniSeedCorpus are CNI configs from the Calico manifests, along with the variants of them
+// which Parse is expected to handle.
+var cniSeedCorpus = []string{
+	// calico.yaml
+	`{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [
+    {
+      "type": "calico",
+      "log_level": "info",
+      "datastore_type": "kubernetes",
+      "nodename": "__KUBERNETES_NODE_NAME__",
+      "mtu": __CNI_MTU__,
+      "ipam": {
+          "type": "calico-ipam"
+      },
+      "policy": {
+          "type": "k8s"
+      },
+      "kubernetes": {
+          "kubeconfig": "__KUBECONFIG_FILEPATH__"
+      }
+    },
+    {
+      "type": "portmap",
+      "snat": true,
+      "capabilities": {"portMappings": true}
+    },
+    {
+      "type": "bandwidth",
+      "capabilities": {"bandwidth": true}
+    }
+  ]
+}`,
+	// calico-typha.yaml with host-local IPAM
+	`{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [
+    {
+      "type": "calico",
+      "log_level": "info",
+      "datastore_type": "kubernetes",
+      "nodename": "__KUBERNETES_NODE_NAME__",
+      "mtu": __CNI_MTU__,
+      "ipam": {
+          "type": "host-local",
+          "subnet": "usePodCidr"
+      },
+      "policy": {
+          "type": "k8s"
+      },
+      "kubernetes": {
+          "kubeconfig": "__KUBECONFIG_FILEPATH__"
+      }
+    },
+    {
+      "type": "portmap",
+      "snat": true,
+      "capabilities": {"portMappings": true}
+    }
+  ]
+}`,
+	// canal.yaml
+	`{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [
+    {
+      "type": "calico",
+      "log_level": "info",
+      "datastore_type": "kubernetes",
+      "nodename": "__KUBERNETES_NODE_NAME__",
+      "mtu": __CNI_MTU__,
+      "ipam": {
+          "type": "host-local",
+          "subnet": "usePodCidr"
+      },
+      "policy": {
+          "type": "k8s"
+      },
+      "kubernetes": {
+          "kubeconfig": "__KUBECONFIG_FILEPATH__"
+      }
+    },
+    {
+      "type": "portmap",
+      "snat": true,
+      "capabilities": {"portMappings": true}
+    }
+  ]
+}`,
+	// a single plugin rather than a conflist
+	`{"name": "k8s-pod-network", "type": "calico", "ipam": {"type": "calico-ipam"}}`,
+	// escaped config
+	`{\n  \"name\": \"k8s-pod-network\",\n  \"plugins\": [{\"type\": \"calico\", \"mtu\": \"__CNI_MTU__\"}]\n}`,
+	// structural variants
+	`{"conflist": {"name": "k8s-pod-network", "plugins": [{"type": "calico"}]}}`,
+	`{"name": "k8s-pod-network", "plugins": {"type": "calico"}}`,
+	`{"name": "k8s-pod-network", "cniVersion": 0.3, "plugins": [{"type": "calico", "cniVersion": 0.3}]}`,
+	// invalid config
+	``,
+	`null`,
+	`"plugins"`,
+	`{"name": "k8s-pod-network", "plugins": "calico"}`,
+	`{"name": "k8s-pod-network", "plugins": [null]}`,
+}
+
+// FuzzParse checks that Parse never panics, and that it either returns an error or
+// a conflist with at least one plugin.
+func FuzzParse(f *testing.F) {
+	for _, seed := range cniSeedCorpus {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, cniConfig string) {
+		nc, err := Parse(cniConfig)
+		if err != nil {
+			return
+		}
+		if nc.CalicoConfig == nil && len(nc.Plugins) == 0 {
+			t.Errorf("parsed CNI config %q without any plugins", cniConfig)
+		}
+	})
+}
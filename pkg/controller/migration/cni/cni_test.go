@@ -45,6 +45,23 @@ var _ = Describe("CNI", func() {
 		Expect(err).ToNot(HaveOccurred())
 	})
 
+	It("should parse cni config with escaped newlines", func() {
+		escaped := `{\n  \"name\": \"k8s-pod-network\",\n  \"cniVersion\": \"0.3.1\",\n  \"plugins\": [\n    {\"type\": \"calico\", \"mtu\": __CNI_MTU__, \"ipam\": {\"type\": \"calico-ipam\"}}\n  ]\n}`
+		c, err := Parse(escaped)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.ConfigName).To(Equal("k8s-pod-network"))
+		Expect(c.CalicoConfig).ToNot(BeNil())
+		Expect(c.CalicoConfig.IPAM.Type).To(Equal("calico-ipam"))
+		Expect(c.CalicoConfig.MTU).To(Equal(-1))
+	})
+
+	It("should parse cni config embedded as a quoted json string", func() {
+		c, err := Parse(`"{\"name\": \"k8s-pod-network\", \"cniVersion\": \"0.3.1\", \"plugins\": [{\"type\": \"calico\", \"ipam\": {\"type\": \"calico-ipam\"}}]}"`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.CalicoConfig).ToNot(BeNil())
+		Expect(c.CalicoConfig.IPAM.Type).To(Equal("calico-ipam"))
+	})
+
 	It("should parse basic calico cni", func() {
 		c, err := Parse(defaultCNI)
 		Expect(err).ToNot(HaveOccurred())
@@ -82,4 +99,54 @@ var _ = Describe("CNI", func() {
         }`))
 		Expect(err).To(HaveOccurred())
 	})
+
+	Context("identifying the calico plugin", func() {
+		It("should find the calico plugin by type when it is not the first plugin", func() {
+			c, err := Parse(`{
+				"name": "k8s-pod-network",
+				"cniVersion": "0.3.1",
+				"plugins": [
+					{"type": "portmap", "snat": true, "capabilities": {"portMappings": true}},
+					{"type": "calico", "ipam": {"type": "calico-ipam"}}
+				]
+			}`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.ConfigName).To(Equal("k8s-pod-network"))
+			Expect(c.CalicoConfig).ToNot(BeNil())
+			Expect(c.CalicoConfig.IPAM.Type).To(Equal("calico-ipam"))
+			Expect(c.Plugins).To(HaveLen(1))
+			Expect(c.Plugins).To(HaveKey("portmap"))
+		})
+
+		It("should find the calico plugin in a single conf named k8s-pod-network", func() {
+			c, err := Parse(`{"name": "k8s-pod-network", "cniVersion": "0.3.1", "type": "calico", "ipam": {"type": "calico-ipam"}}`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.ConfigName).To(Equal("k8s-pod-network"))
+			Expect(c.CalicoConfig).ToNot(BeNil())
+			Expect(c.Plugins).To(BeEmpty())
+		})
+
+		It("should find the calico plugin regardless of the network name", func() {
+			c, err := Parse(`{
+				"name": "calico",
+				"cniVersion": "0.3.1",
+				"plugins": [{"type": "calico", "ipam": {"type": "host-local", "subnet": "usePodCidr"}}]
+			}`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.ConfigName).To(Equal("calico"))
+			Expect(c.CalicoConfig).ToNot(BeNil())
+			Expect(c.HostLocalIPAMConfig).ToNot(BeNil())
+		})
+
+		It("should not treat a non-calico plugin named calico as the calico plugin", func() {
+			c, err := Parse(`{
+				"name": "calico",
+				"cniVersion": "0.3.1",
+				"plugins": [{"type": "flannel", "delegate": {"isDefaultGateway": true}}]
+			}`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.CalicoConfig).To(BeNil())
+			Expect(c.Plugins).To(HaveKey("flannel"))
+		})
+	})
 })
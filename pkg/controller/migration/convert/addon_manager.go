@@ -1,8 +1,12 @@
 package convert
 
-import operatorv1 "github.com/tigera/operator/api/v1"
+import (
+	"context"
 
-func handleAddonManager(c *components, install *operatorv1.Installation) error {
+	operatorv1 "github.com/tigera/operator/api/v1"
+)
+
+func handleAddonManager(_ context.Context, c *components, install *operatorv1.Installation) error {
 	if _, ok := c.node.Labels["addonmanager.kubernetes.io/mode"]; ok {
 		return ErrIncompatibleCluster{
 			component: ComponentCalicoNode,
@@ -12,24 +12,24 @@ var _ = Describe("addon manager", func() {
 	It("should succeed if addon manager isn't denoted", func() {
 		comps := emptyComponents()
 		i := v1.Installation{}
-		Expect(handleAddonManager(&comps, &i)).ToNot(HaveOccurred())
+		Expect(handleAddonManager(ctx, &comps, &i)).ToNot(HaveOccurred())
 	})
 	It("should fail if calico-node is managed by addon-manager", func() {
 		comps := emptyComponents()
 		comps.node.Labels = addonMgrLabel
 		i := v1.Installation{}
-		Expect(handleAddonManager(&comps, &i)).To(HaveOccurred())
+		Expect(handleAddonManager(ctx, &comps, &i)).To(HaveOccurred())
 	})
 	It("should fail if kube-controllers is managed by addon-manager", func() {
 		comps := emptyComponents()
 		comps.kubeControllers.Labels = addonMgrLabel
 		i := v1.Installation{}
-		Expect(handleAddonManager(&comps, &i)).To(HaveOccurred())
+		Expect(handleAddonManager(ctx, &comps, &i)).To(HaveOccurred())
 	})
 	It("should fail if typha is managed by addon-manager", func() {
 		comps := emptyComponents()
 		comps.typha.Labels = addonMgrLabel
 		i := v1.Installation{}
-		Expect(handleAddonManager(&comps, &i)).To(HaveOccurred())
+		Expect(handleAddonManager(ctx, &comps, &i)).To(HaveOccurred())
 	})
 })
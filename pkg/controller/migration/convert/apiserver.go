@@ -0,0 +1,46 @@
+package convert
+
+import (
+	"context"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// handleAPIServer detects the calico-apiserver deployment. On Enterprise, the operator manages the API server
+// with an APIServer resource rather than the Installation, so when it is found an APIServer is added to the
+// report for the user to create alongside the Installation. The operator does not manage the API server of
+// Calico, so it is left running unmanaged.
+func handleAPIServer(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	d := appsv1.Deployment{}
+	if err := c.client.Get(ctx, types.NamespacedName{
+		Name:      "calico-apiserver",
+		Namespace: "calico-apiserver",
+	}, &d); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if c.variant != operatorv1.TigeraSecureEnterprise {
+		c.warn(ComponentAPIServer, "the API server is not managed by the operator for the %s variant, so calico-apiserver "+
+			"will remain unmanaged and must be kept up to date or removed manually", c.variant)
+		return nil
+	}
+
+	c.report.APIServer = &operatorv1.APIServer{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: operatorv1.GroupVersion.String(),
+			Kind:       "APIServer",
+		},
+		// the operator only reconciles the APIServer with this name.
+		ObjectMeta: metav1.ObjectMeta{Name: "tigera-secure"},
+	}
+	c.warn(ComponentAPIServer, "the API server is not configured by the Installation, create the APIServer in this report "+
+		"so that the operator continues to run it")
+	return nil
+}
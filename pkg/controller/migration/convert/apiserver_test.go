@@ -0,0 +1,64 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("calico-apiserver", func() {
+	var (
+		comps = emptyComponents()
+		i     = &operatorv1.Installation{}
+	)
+
+	BeforeEach(func() {
+		comps = emptyComponents()
+		i = &operatorv1.Installation{}
+	})
+
+	It("should not report an APIServer if there is no calico-apiserver deployment", func() {
+		comps.client = fake.NewFakeClient()
+		Expect(handleAPIServer(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.APIServer).To(BeNil())
+		Expect(comps.report.Warnings).To(BeEmpty())
+	})
+
+	It("should report an APIServer if there is a calico-apiserver deployment on Enterprise", func() {
+		comps.variant = operatorv1.TigeraSecureEnterprise
+		comps.client = fake.NewFakeClient(&appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "calico-apiserver",
+				Namespace: "calico-apiserver",
+			},
+		})
+		Expect(handleAPIServer(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.APIServer).ToNot(BeNil())
+		Expect(comps.report.APIServer.Name).To(Equal("tigera-secure"))
+		Expect(comps.report.APIServer.Kind).To(Equal("APIServer"))
+		Expect(comps.report.Warnings).To(HaveLen(1))
+		Expect(comps.report.Warnings[0].Component).To(Equal(ComponentAPIServer))
+	})
+
+	It("should only warn if there is a calico-apiserver deployment on Calico", func() {
+		comps.variant = operatorv1.Calico
+		comps.client = fake.NewFakeClient(&appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "calico-apiserver",
+				Namespace: "calico-apiserver",
+			},
+		})
+		Expect(handleAPIServer(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.APIServer).To(BeNil())
+		Expect(comps.report.Warnings).To(ConsistOf(Warning{
+			Component: ComponentAPIServer,
+			Message: "the API server is not managed by the operator for the Calico variant, so calico-apiserver " +
+				"will remain unmanaged and must be kept up to date or removed manually",
+		}))
+	})
+})
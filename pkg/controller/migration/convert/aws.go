@@ -0,0 +1,71 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const containerAWSNode = "aws-node"
+
+// handleAWSNode is a migration handler which checks the settings of the Amazon VPC CNI plugin's aws-node
+// daemonset which interact with Calico policy. The operator does not manage aws-node, so its settings
+// are left as they are, but combinations which Calico can't work with are reported.
+// It must run after the CNI plugin has been detected.
+func handleAWSNode(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	if install.Spec.CNI == nil || install.Spec.CNI.Type != operatorv1.PluginAmazonVPC {
+		return nil
+	}
+
+	ds := appsv1.DaemonSet{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: "aws-node", Namespace: metav1.NamespaceSystem}, &ds); err != nil {
+		if kerrors.IsNotFound(err) {
+			c.warn(ComponentAWSNode, "could not find the aws-node daemonset, its settings were not checked")
+			return nil
+		}
+		return fmt.Errorf("failed to get aws-node daemonset: %v", err)
+	}
+	spec := ds.Spec.Template.Spec
+	if getContainer(spec, containerAWSNode) == nil {
+		return nil
+	}
+
+	// felix identifies pod interfaces by the veth prefix, which the operator always sets to 'eni'.
+	vethPrefix, err := getEnv(ctx, c.client, spec, ComponentAWSNode, containerAWSNode, "AWS_VPC_K8S_CNI_VETHPREFIX")
+	if err != nil {
+		return err
+	}
+	if vethPrefix != nil && *vethPrefix != "eni" {
+		if err := c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("AWS_VPC_K8S_CNI_VETHPREFIX=%s is not supported, Calico expects pod interfaces to be prefixed with 'eni'", *vethPrefix),
+			component: ComponentAWSNode,
+			fix:       "remove the AWS_VPC_K8S_CNI_VETHPREFIX env var or set it to 'eni'",
+		}); err != nil {
+			return err
+		}
+	}
+
+	externalSNAT, err := getEnv(ctx, c.client, spec, ComponentAWSNode, containerAWSNode, "AWS_VPC_K8S_CNI_EXTERNALSNAT")
+	if err != nil {
+		return err
+	}
+	if externalSNAT != nil && strings.ToLower(*externalSNAT) == "true" {
+		c.warn(ComponentAWSNode, "AWS_VPC_K8S_CNI_EXTERNALSNAT=true, pod traffic leaving the VPC is not SNATed on the node, so destinations outside the VPC will see pod IPs rather than node IPs")
+	}
+
+	podENI, err := getEnv(ctx, c.client, spec, ComponentAWSNode, containerAWSNode, "ENABLE_POD_ENI")
+	if err != nil {
+		return err
+	}
+	if podENI != nil && strings.ToLower(*podENI) == "true" {
+		c.warn(ComponentAWSNode, "ENABLE_POD_ENI=true, Calico policy is not enforced for pods which use security groups for pods")
+	}
+
+	return nil
+}
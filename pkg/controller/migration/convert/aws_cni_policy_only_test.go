@@ -8,6 +8,27 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// awsNodeDaemonSet returns the Amazon VPC CNI plugin's aws-node daemonset with the given env vars.
+func awsNodeDaemonSet(env ...corev1.EnvVar) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws-node",
+			Namespace: "kube-system",
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "aws-node",
+						Image: "602401143452.dkr.ecr.us-west-2.amazonaws.com/amazon-k8s-cni:v1.7.5",
+						Env:   append([]corev1.EnvVar{{Name: "AWS_VPC_K8S_CNI_LOGLEVEL", Value: "DEBUG"}}, env...),
+					}},
+				},
+			},
+		},
+	}
+}
+
 func awsCNIPolicyOnlyConfig() []runtime.Object {
 	fileOrCreate := corev1.HostPathFileOrCreate
 	isPrivileged := true
@@ -0,0 +1,132 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleBGP checks the default BGPConfiguration in the datastore.
+// The operator does not manage the BGPConfiguration, so the AS number and any service
+// advertisement remain in effect after migration without needing to be carried over. However,
+// service advertisement is performed by BIRD, so it can only continue to work if BGP is enabled.
+// Cluster IPs advertised with CALICO_ADVERTISE_CLUSTER_IPS are moved onto the BGPConfiguration.
+func handleBGP(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	if err := handleAdvertiseClusterIPs(ctx, c, install); err != nil {
+		return err
+	}
+
+	bgpConfig := crdv1.BGPConfiguration{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: "default"}, &bgpConfig); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get BGPConfiguration: %v", err)
+	}
+
+	if install.Spec.CalicoNetwork == nil || install.Spec.CalicoNetwork.BGP == nil ||
+		*install.Spec.CalicoNetwork.BGP == operatorv1.BGPEnabled {
+		return nil
+	}
+
+	advertised := []string{}
+	if len(bgpConfig.Spec.ServiceClusterIPs) != 0 {
+		advertised = append(advertised, "serviceClusterIPs")
+	}
+	if len(bgpConfig.Spec.ServiceExternalIPs) != 0 {
+		advertised = append(advertised, "serviceExternalIPs")
+	}
+	if len(advertised) != 0 {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("BGPConfiguration advertises %v but BGP is disabled", advertised),
+			component: ComponentCalicoNode,
+			fix:       "remove the service advertisement from the default BGPConfiguration or enable BGP by setting CALICO_NETWORKING_BACKEND to bird",
+		})
+	}
+	return nil
+}
+
+// handleAdvertiseClusterIPs carries CALICO_ADVERTISE_CLUSTER_IPS, which predates service advertisement in
+// the BGPConfiguration, onto the serviceClusterIPs of the default BGPConfiguration since the operator does
+// not set it on calico-node. The BGPConfiguration is written by applyBGPConfiguration once the migration
+// succeeds. serviceClusterIPs already set on the BGPConfiguration take precedence.
+func handleAdvertiseClusterIPs(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	val, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "CALICO_ADVERTISE_CLUSTER_IPS")
+	if err != nil {
+		return err
+	}
+	if val == nil || strings.TrimSpace(*val) == "" {
+		return nil
+	}
+
+	blocks := []crdv1.ServiceClusterIPBlock{}
+	for _, cidr := range strings.Split(*val, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return c.incompatible(ErrIncompatibleCluster{
+				err:       fmt.Sprintf("CALICO_ADVERTISE_CLUSTER_IPS contains an invalid CIDR '%s'", cidr),
+				component: ComponentCalicoNode,
+				fix:       "correct or remove CALICO_ADVERTISE_CLUSTER_IPS",
+			})
+		}
+		blocks = append(blocks, crdv1.ServiceClusterIPBlock{CIDR: cidr})
+	}
+
+	if install.Spec.CalicoNetwork != nil && install.Spec.CalicoNetwork.BGP != nil &&
+		*install.Spec.CalicoNetwork.BGP != operatorv1.BGPEnabled {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("CALICO_ADVERTISE_CLUSTER_IPS=%s advertises cluster IPs but BGP is disabled", *val),
+			component: ComponentCalicoNode,
+			fix:       "remove CALICO_ADVERTISE_CLUSTER_IPS or enable BGP by setting CALICO_NETWORKING_BACKEND to bird",
+		})
+	}
+
+	bgpConfig := &crdv1.BGPConfiguration{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: "default"}, bgpConfig); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get BGPConfiguration: %v", err)
+		}
+		bgpConfig = &crdv1.BGPConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	} else if len(bgpConfig.Spec.ServiceClusterIPs) != 0 {
+		if !reflect.DeepEqual(bgpConfig.Spec.ServiceClusterIPs, blocks) {
+			c.warn(ComponentCalicoNode, "CALICO_ADVERTISE_CLUSTER_IPS=%s is overridden by the serviceClusterIPs of the default BGPConfiguration, "+
+				"so it will not be migrated", *val)
+		}
+		return nil
+	}
+	bgpConfig.Spec.ServiceClusterIPs = blocks
+	c.bgpConfig = bgpConfig
+	c.note(ComponentCalicoNode, "CALICO_ADVERTISE_CLUSTER_IPS is not set by the operator, so its cluster IPs are moved onto the "+
+		"serviceClusterIPs of the default BGPConfiguration")
+	return nil
+}
+
+// applyBGPConfiguration writes the default BGPConfiguration built by the handlers, creating it if it
+// does not exist. It does nothing if no handler carried settings onto it.
+func (c *components) applyBGPConfiguration(ctx context.Context, cli client.Client) error {
+	if c.bgpConfig == nil {
+		return nil
+	}
+	if err := cli.Get(ctx, types.NamespacedName{Name: c.bgpConfig.Name}, &crdv1.BGPConfiguration{}); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get BGPConfiguration: %v", err)
+		}
+		if err := cli.Create(ctx, c.bgpConfig); err != nil {
+			return fmt.Errorf("failed to create BGPConfiguration: %v", err)
+		}
+		return nil
+	}
+	if err := cli.Update(ctx, c.bgpConfig); err != nil {
+		return fmt.Errorf("failed to update BGPConfiguration: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/apis"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("BGPConfiguration", func() {
+	var (
+		comps  = emptyComponents()
+		i      = &operatorv1.Installation{}
+		scheme *runtime.Scheme
+	)
+
+	BeforeEach(func() {
+		comps = emptyComponents()
+		i = &operatorv1.Installation{
+			Spec: operatorv1.InstallationSpec{
+				CalicoNetwork: &operatorv1.CalicoNetworkSpec{},
+			},
+		}
+		scheme = kscheme.Scheme
+		Expect(apis.AddToScheme(scheme)).ToNot(HaveOccurred())
+		comps.client = fake.NewFakeClientWithScheme(scheme)
+	})
+
+	advertisingBGPConfig := func() *crdv1.BGPConfiguration {
+		asNumber := uint32(64513)
+		return &crdv1.BGPConfiguration{
+			ObjectMeta: v1.ObjectMeta{Name: "default"},
+			Spec: crdv1.BGPConfigurationSpec{
+				ASNumber:           &asNumber,
+				ServiceClusterIPs:  []crdv1.ServiceClusterIPBlock{{CIDR: "10.96.0.0/12"}},
+				ServiceExternalIPs: []crdv1.ServiceExternalIPBlock{{CIDR: "172.16.0.0/16"}},
+			},
+		}
+	}
+
+	It("should not error if there is no BGPConfiguration", func() {
+		i.Spec.CalicoNetwork.BGP = operatorv1.BGPOptionPtr(operatorv1.BGPDisabled)
+		Expect(handleBGP(ctx, &comps, i)).ToNot(HaveOccurred())
+	})
+
+	It("should allow service advertisement when BGP is enabled", func() {
+		comps.client = fake.NewFakeClientWithScheme(scheme, advertisingBGPConfig())
+		i.Spec.CalicoNetwork.BGP = operatorv1.BGPOptionPtr(operatorv1.BGPEnabled)
+		Expect(handleBGP(ctx, &comps, i)).ToNot(HaveOccurred())
+	})
+
+	It("should allow a BGPConfiguration without service advertisement when BGP is disabled", func() {
+		bgpConfig := advertisingBGPConfig()
+		bgpConfig.Spec.ServiceClusterIPs = nil
+		bgpConfig.Spec.ServiceExternalIPs = nil
+		comps.client = fake.NewFakeClientWithScheme(scheme, bgpConfig)
+		i.Spec.CalicoNetwork.BGP = operatorv1.BGPOptionPtr(operatorv1.BGPDisabled)
+		Expect(handleBGP(ctx, &comps, i)).ToNot(HaveOccurred())
+	})
+
+	It("should error on service advertisement when BGP is disabled", func() {
+		comps.client = fake.NewFakeClientWithScheme(scheme, advertisingBGPConfig())
+		i.Spec.CalicoNetwork.BGP = operatorv1.BGPOptionPtr(operatorv1.BGPDisabled)
+		err := handleBGP(ctx, &comps, i)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("[serviceClusterIPs serviceExternalIPs]"))
+	})
+
+	Context("CALICO_ADVERTISE_CLUSTER_IPS", func() {
+		setAdvertiseClusterIPs := func(value string) {
+			comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+				Name:  "CALICO_ADVERTISE_CLUSTER_IPS",
+				Value: value,
+			}}
+		}
+
+		BeforeEach(func() {
+			i.Spec.CalicoNetwork.BGP = operatorv1.BGPOptionPtr(operatorv1.BGPEnabled)
+		})
+
+		It("should build a BGPConfiguration advertising the cluster IPs", func() {
+			setAdvertiseClusterIPs("10.96.0.0/12, fd00:96::/112")
+			Expect(handleBGP(ctx, &comps, i)).To(Succeed())
+			Expect(comps.bgpConfig).ToNot(BeNil())
+			Expect(comps.bgpConfig.Name).To(Equal("default"))
+			Expect(comps.bgpConfig.Spec.ServiceClusterIPs).To(Equal([]crdv1.ServiceClusterIPBlock{
+				{CIDR: "10.96.0.0/12"}, {CIDR: "fd00:96::/112"},
+			}))
+			Expect(comps.node.uncheckedVars()).ToNot(ContainElement("calico-node/CALICO_ADVERTISE_CLUSTER_IPS"))
+		})
+
+		It("should add the cluster IPs to the existing BGPConfiguration", func() {
+			bgpConfig := advertisingBGPConfig()
+			bgpConfig.Spec.ServiceClusterIPs = nil
+			comps.client = fake.NewFakeClientWithScheme(scheme, bgpConfig)
+			setAdvertiseClusterIPs("10.96.0.0/12")
+			Expect(handleBGP(ctx, &comps, i)).To(Succeed())
+			Expect(comps.bgpConfig).ToNot(BeNil())
+			Expect(comps.bgpConfig.Spec.ServiceClusterIPs).To(Equal([]crdv1.ServiceClusterIPBlock{{CIDR: "10.96.0.0/12"}}))
+			Expect(*comps.bgpConfig.Spec.ASNumber).To(BeEquivalentTo(64513))
+
+			Expect(comps.applyBGPConfiguration(ctx, comps.client)).To(Succeed())
+			written := &crdv1.BGPConfiguration{}
+			Expect(comps.client.Get(ctx, types.NamespacedName{Name: "default"}, written)).To(Succeed())
+			Expect(written.Spec.ServiceClusterIPs).To(Equal([]crdv1.ServiceClusterIPBlock{{CIDR: "10.96.0.0/12"}}))
+			Expect(*written.Spec.ASNumber).To(BeEquivalentTo(64513))
+		})
+
+		It("should warn if the BGPConfiguration advertises other cluster IPs", func() {
+			comps.client = fake.NewFakeClientWithScheme(scheme, advertisingBGPConfig())
+			setAdvertiseClusterIPs("10.0.0.0/16")
+			Expect(handleBGP(ctx, &comps, i)).To(Succeed())
+			Expect(comps.bgpConfig).To(BeNil())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("CALICO_ADVERTISE_CLUSTER_IPS=10.0.0.0/16 is overridden"))
+		})
+
+		It("should write the BGPConfiguration once the migration succeeds", func() {
+			node := emptyNodeSpec()
+			node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+				{Name: "CALICO_ADVERTISE_CLUSTER_IPS", Value: "10.96.0.0/12"},
+			}
+			pool := crdv1.NewIPPool()
+			pool.Spec = crdv1.IPPoolSpec{CIDR: "192.168.4.0/24", IPIPMode: crdv1.IPIPModeAlways, NATOutgoing: true}
+			cli := fake.NewFakeClientWithScheme(scheme, node, pool, emptyFelixConfig())
+			_, _, err := ConvertWithReport(ctx, cli, Options{})
+			Expect(err).ToNot(HaveOccurred())
+			written := &crdv1.BGPConfiguration{}
+			Expect(cli.Get(ctx, types.NamespacedName{Name: "default"}, written)).To(Succeed())
+			Expect(written.Spec.ServiceClusterIPs).To(Equal([]crdv1.ServiceClusterIPBlock{{CIDR: "10.96.0.0/12"}}))
+		})
+
+		It("should not write the BGPConfiguration if the migration fails", func() {
+			node := emptyNodeSpec()
+			node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+				{Name: "CALICO_ADVERTISE_CLUSTER_IPS", Value: "10.96.0.0/12"},
+				{Name: "UNSUPPORTED_VAR", Value: "true"},
+			}
+			pool := crdv1.NewIPPool()
+			pool.Spec = crdv1.IPPoolSpec{CIDR: "192.168.4.0/24", IPIPMode: crdv1.IPIPModeAlways, NATOutgoing: true}
+			cli := fake.NewFakeClientWithScheme(scheme, node, pool, emptyFelixConfig())
+			_, _, err := ConvertWithReport(ctx, cli, Options{})
+			Expect(err).To(HaveOccurred())
+			err = cli.Get(ctx, types.NamespacedName{Name: "default"}, &crdv1.BGPConfiguration{})
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should error on an invalid CIDR", func() {
+			setAdvertiseClusterIPs("10.96.0.0")
+			err := handleBGP(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid CIDR '10.96.0.0'"))
+		})
+
+		It("should error if BGP is disabled", func() {
+			i.Spec.CalicoNetwork.BGP = operatorv1.BGPOptionPtr(operatorv1.BGPDisabled)
+			setAdvertiseClusterIPs("10.96.0.0/12")
+			err := handleBGP(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("advertises cluster IPs but BGP is disabled"))
+		})
+	})
+})
@@ -0,0 +1,122 @@
+package convert
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// calicoConfigName is the ConfigMap which classic Calico manifests reference from many env vars.
+const calicoConfigName = "calico-config"
+
+// configMapCache wraps a client so that each ConfigMap is only fetched once. Env vars of classic
+// manifests are resolved from keys of the same calico-config ConfigMap, so without it every env var
+// lookup would fetch it again.
+type configMapCache struct {
+	client.Client
+
+	configMaps map[types.NamespacedName]*corev1.ConfigMap
+}
+
+func newConfigMapCache(c client.Client) *configMapCache {
+	return &configMapCache{
+		Client:     c,
+		configMaps: map[types.NamespacedName]*corev1.ConfigMap{},
+	}
+}
+
+// Get returns ConfigMaps from the cache, fetching them on first use. Any other object is fetched
+// with the wrapped client. ConfigMaps which don't exist are cached as well.
+func (c *configMapCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return c.Client.Get(ctx, key, obj)
+	}
+
+	cached, ok := c.configMaps[key]
+	if !ok {
+		cached = &corev1.ConfigMap{}
+		if err := c.Client.Get(ctx, key, cached); err != nil {
+			if !kerrors.IsNotFound(err) {
+				return err
+			}
+			cached = nil
+		}
+		c.configMaps[key] = cached
+	}
+	if cached == nil {
+		return kerrors.NewNotFound(corev1.Resource("configmaps"), key.Name)
+	}
+	cached.DeepCopyInto(cm)
+	return nil
+}
+
+// handleCalicoConfig is a migration handler which reports, in a single warning, the keys of the
+// calico-config ConfigMap that no env var of calico-node, kube-controllers or typha references,
+// either by key or through envFrom.
+// They have no effect on the cluster, so are not migrated, but may indicate configuration the
+// user expected to be in use.
+func handleCalicoConfig(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	cm := corev1.ConfigMap{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: calicoConfigName, Namespace: metav1.NamespaceSystem}, &cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	specs := []corev1.PodSpec{c.node.Spec.Template.Spec}
+	if c.kubeControllers != nil {
+		specs = append(specs, c.kubeControllers.Spec.Template.Spec)
+	}
+	if c.typha != nil {
+		specs = append(specs, c.typha.Spec.Template.Spec)
+	}
+
+	referenced := map[string]bool{}
+	for _, spec := range specs {
+		for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+			for _, container := range containers {
+				for _, e := range container.Env {
+					if e.ValueFrom != nil && e.ValueFrom.ConfigMapKeyRef != nil && e.ValueFrom.ConfigMapKeyRef.Name == calicoConfigName {
+						referenced[e.ValueFrom.ConfigMapKeyRef.Key] = true
+					}
+				}
+				// envFrom sets an env var for every key of the ConfigMap, so all of them are referenced.
+				for _, e := range container.EnvFrom {
+					if e.ConfigMapRef != nil && e.ConfigMapRef.Name == calicoConfigName {
+						for key := range cm.Data {
+							referenced[key] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// without install-cni, the CNI config was read from the ConfigMap by loadCNIConfigMap.
+	if getContainer(c.node.Spec.Template.Spec, containerInstallCNI) == nil {
+		referenced[cniNetworkConfigKey] = true
+	}
+
+	var unreferenced []string
+	for key := range cm.Data {
+		if !referenced[key] {
+			unreferenced = append(unreferenced, key)
+		}
+	}
+	if len(unreferenced) == 0 {
+		return nil
+	}
+	sort.Strings(unreferenced)
+	c.warn(ComponentCalicoNode, "ConfigMap %s/%s has keys which are not referenced by any env var and will not be migrated: %s",
+		metav1.NamespaceSystem, calicoConfigName, strings.Join(unreferenced, ", "))
+	return nil
+}
@@ -0,0 +1,172 @@
+package convert
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tigera/operator/pkg/apis"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+)
+
+// countingClient counts the number of times each ConfigMap is fetched.
+type countingClient struct {
+	client.Client
+	configMapGets map[types.NamespacedName]int
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if _, ok := obj.(*corev1.ConfigMap); ok {
+		c.configMapGets[key]++
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+var _ = Describe("calico-config", func() {
+	var (
+		scheme *runtime.Scheme
+		key    = types.NamespacedName{Name: "calico-config", Namespace: "kube-system"}
+	)
+
+	BeforeEach(func() {
+		scheme = kscheme.Scheme
+		Expect(apis.AddToScheme(scheme)).To(Succeed())
+	})
+
+	countObjects := func(objs ...runtime.Object) *countingClient {
+		return &countingClient{
+			Client:        fake.NewFakeClientWithScheme(scheme, objs...),
+			configMapGets: map[types.NamespacedName]int{},
+		}
+	}
+
+	It("should only fetch a ConfigMap once", func() {
+		cli := countObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string]string{"veth_mtu": "1440"},
+		})
+		cache := newConfigMapCache(cli)
+		for i := 0; i < 3; i++ {
+			cm := corev1.ConfigMap{}
+			Expect(cache.Get(ctx, key, &cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("veth_mtu", "1440"))
+		}
+		Expect(cli.configMapGets[key]).To(Equal(1))
+	})
+
+	It("should only fetch a missing ConfigMap once", func() {
+		cli := countObjects()
+		cache := newConfigMapCache(cli)
+		for i := 0; i < 2; i++ {
+			err := cache.Get(ctx, key, &corev1.ConfigMap{})
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		}
+		Expect(cli.configMapGets[key]).To(Equal(1))
+	})
+
+	It("should not share the cached ConfigMap", func() {
+		cache := newConfigMapCache(countObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string]string{"veth_mtu": "1440"},
+		}))
+		cm := corev1.ConfigMap{}
+		Expect(cache.Get(ctx, key, &cm)).To(Succeed())
+		cm.Data["veth_mtu"] = "9000"
+
+		cm = corev1.ConfigMap{}
+		Expect(cache.Get(ctx, key, &cm)).To(Succeed())
+		Expect(cm.Data).To(HaveKeyWithValue("veth_mtu", "1440"))
+	})
+
+	It("should fetch calico-config once when migrating a classic manifest", func() {
+		pool := crdv1.NewIPPool()
+		pool.Spec = crdv1.IPPoolSpec{CIDR: "192.168.4.0/24", IPIPMode: crdv1.IPIPModeAlways, NATOutgoing: true}
+		cli := countObjects(append([]runtime.Object{pool, emptyFelixConfig()}, calicoDefaultConfig()...)...)
+
+		cfg, report, err := ConvertWithReport(ctx, cli, Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*cfg.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1440))
+		Expect(cli.configMapGets[key]).To(Equal(1))
+		// the fixture does not reference typha_service_name from FELIX_TYPHAK8SSERVICENAME.
+		Expect(report.Warnings).To(ContainElement(Warning{
+			Component: ComponentCalicoNode,
+			Message:   "ConfigMap kube-system/calico-config has keys which are not referenced by any env var and will not be migrated: typha_service_name",
+		}))
+	})
+
+	It("should list an extra unused key in the migration report", func() {
+		pool := crdv1.NewIPPool()
+		pool.Spec = crdv1.IPPoolSpec{CIDR: "192.168.4.0/24", IPIPMode: crdv1.IPIPModeAlways, NATOutgoing: true}
+		objs := calicoDefaultConfig()
+		objs[0].(*corev1.ConfigMap).Data["etcd_endpoints"] = "http://10.96.232.136:6666"
+		cli := countObjects(append([]runtime.Object{pool, emptyFelixConfig()}, objs...)...)
+
+		_, report, err := ConvertWithReport(ctx, cli, Options{})
+		Expect(err).ToNot(HaveOccurred())
+		var configWarnings []string
+		for _, w := range report.Warnings {
+			if strings.Contains(w.Message, "ConfigMap kube-system/calico-config") {
+				configWarnings = append(configWarnings, w.Message)
+			}
+		}
+		Expect(configWarnings).To(ConsistOf(HaveSuffix("will not be migrated: etcd_endpoints, typha_service_name")))
+	})
+
+	It("should warn about keys which aren't referenced", func() {
+		comps := emptyComponents()
+		comps.client = fake.NewFakeClientWithScheme(scheme, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string]string{"veth_mtu": "1440", "calico_backend": "bird"},
+		})
+		comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+			Name: "CALICO_NETWORKING_BACKEND",
+			ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "calico-config"},
+				Key:                  "calico_backend",
+			}},
+		}}
+		Expect(handleCalicoConfig(ctx, &comps, nil)).To(Succeed())
+		Expect(comps.report.Warnings).To(ConsistOf(Warning{
+			Component: ComponentCalicoNode,
+			Message:   "ConfigMap kube-system/calico-config has keys which are not referenced by any env var and will not be migrated: veth_mtu",
+		}))
+	})
+
+	It("should treat every key as referenced by envFrom", func() {
+		comps := emptyComponents()
+		comps.client = fake.NewFakeClientWithScheme(scheme, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string]string{"veth_mtu": "1440", "calico_backend": "bird"},
+		})
+		comps.node.Spec.Template.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{{
+			Prefix:       "CALICO_",
+			ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "calico-config"}},
+		}}
+		Expect(handleCalicoConfig(ctx, &comps, nil)).To(Succeed())
+		Expect(comps.report.Warnings).To(BeEmpty())
+	})
+
+	It("should not treat keys as referenced by envFrom of another ConfigMap", func() {
+		comps := emptyComponents()
+		comps.client = fake.NewFakeClientWithScheme(scheme, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string]string{"veth_mtu": "1440"},
+		})
+		comps.node.Spec.Template.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "other-config"}},
+		}}
+		Expect(handleCalicoConfig(ctx, &comps, nil)).To(Succeed())
+		Expect(comps.report.Warnings).To(HaveLen(1))
+	})
+})
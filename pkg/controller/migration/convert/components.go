@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 
+	operatorv1 "github.com/tigera/operator/api/v1"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
 	"github.com/tigera/operator/pkg/controller/migration/cni"
 
+	gv "github.com/hashicorp/go-version"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -22,6 +26,46 @@ type components struct {
 	client client.Client
 
 	cni cni.NetworkComponents
+
+	// report collects findings which are not represented on the Installation.
+	report Report
+
+	// mode determines whether unsupported configuration fails the migration.
+	mode Mode
+
+	// provider is the auto-detected provider of the cluster.
+	provider operatorv1.Provider
+
+	// containerNames maps the expected name of any calico-node container which was found under a
+	// different name to the name it was found under.
+	containerNames map[string]string
+
+	// droppedVolumes are calico-node volumes which are no longer needed after migration, such as the
+	// emptyDir holding the CNI config template, so are not reported as unexpected.
+	droppedVolumes map[string]bool
+
+	// felixConfig accumulates the settings which handlers carry onto the default FelixConfiguration.
+	felixConfig felixConfigBuilder
+
+	// bgpConfig is the default BGPConfiguration with the settings which handlers carry onto it, or nil
+	// if there are none. It is written once the migration succeeds.
+	bgpConfig *crdv1.BGPConfiguration
+
+	// dualStack is set by handleDualStack if the install uses both IPv4 and IPv6, and is the
+	// single source of truth for handlers which need to know.
+	dualStack bool
+
+	// calicoVersion is the version of Calico detected from ClusterInformation or the calico-node image,
+	// or nil if it could not be detected.
+	calicoVersion *gv.Version
+
+	// variant is the product detected by handleVariant.
+	variant operatorv1.ProductVariant
+
+	// clusterInfo is the default ClusterInformation, or nil if there is none. It is fetched on
+	// first use by getClusterInformation, which sets clusterInfoFetched.
+	clusterInfo        *crdv1.ClusterInformation
+	clusterInfoFetched bool
 }
 
 // getComponents loads the main calico components into structs for later parsing.
@@ -45,6 +89,7 @@ func getComponents(ctx context.Context, client client.Client) (*components, erro
 		if errors.IsNotFound(err) {
 			return nil, nil
 		}
+		return nil, fmt.Errorf("failed to get calico-node daemonset: %v", err)
 	}
 
 	var kc = new(appsv1.Deployment)
@@ -72,41 +117,145 @@ func getComponents(ctx context.Context, client client.Client) (*components, erro
 		t = nil
 	}
 
+	// hash the spec as it was read, before the containers are renamed to their expected names.
+	nodeSpecHash, err := hashPodSpec(ds.Spec.Template.Spec)
+	if err != nil {
+		return nil, err
+	}
+	containerNames := resolveContainerNames(&ds.Spec.Template.Spec)
+	if getContainer(ds.Spec.Template.Spec, containerCalicoNode) == nil {
+		return nil, ErrIncompatibleCluster{
+			err:       fmt.Sprintf("couldn't find a container named %s or running the %s image", containerCalicoNode, containerImages[containerCalicoNode]),
+			component: ComponentCalicoNode,
+		}
+	}
+
+	// env vars are resolved through the components' client, so cache the ConfigMaps they reference.
+	cached := newConfigMapCache(client)
+
 	comps := &components{
-		client: client,
+		client: cached,
 		node: CheckedDaemonSet{
 			ds,
 			map[string]checkedFields{},
 		},
 		kubeControllers: kc,
 		typha:           t,
+		containerNames:  containerNames,
+	}
+	comps.report.NodeSpecHash = nodeSpecHash
+	for _, name := range []string{containerCalicoNode, containerInstallCNI} {
+		if found, ok := containerNames[name]; ok {
+			comps.warn(ComponentCalicoNode, "container %s was identified as %s by its image, it will be named %s after migration", found, name, name)
+		}
 	}
 
 	// do some upfront processing of CNI by loading it into comps
-	var err error
-	comps.cni, err = loadCNI(comps)
+	comps.cni, err = loadCNI(ctx, comps)
 
 	return comps, err
 }
 
 // loadCNI pulls the CNI network config from it's env var source within components
 // and then returns the parsed data.
-func loadCNI(comps *components) (nc cni.NetworkComponents, err error) {
-	// do some upfront processing of CNI by loading it into comps
-	c := getContainer(comps.node.Spec.Template.Spec, containerInstallCNI)
-	if c == nil {
+func loadCNI(ctx context.Context, comps *components) (nc cni.NetworkComponents, err error) {
+	cniConfig, err := loadCNIConfig(ctx, comps)
+	if err != nil || cniConfig == nil {
+		return nc, err
+	}
+	return cni.Parse(*cniConfig)
+}
+
+// loadCNIConfig returns the CNI config template from wherever the install keeps it: CNI_NETWORK_CONFIG on
+// install-cni, the file named by CNI_NETWORK_CONFIG_FILE, or the calico-config ConfigMap if there is no
+// install-cni container. nil is returned if there is no CNI config to be found.
+func loadCNIConfig(ctx context.Context, comps *components) (*string, error) {
+	if getContainer(comps.node.Spec.Template.Spec, containerInstallCNI) == nil {
 		log.V(5).Info("no install-cni container found on calico-node")
-		return
+		return loadCNIConfigMap(ctx, comps)
 	}
 
 	cniConfig, err := comps.node.getEnv(ctx, comps.client, containerInstallCNI, "CNI_NETWORK_CONFIG")
-	if err != nil {
-		return nc, err
+	if err != nil || cniConfig != nil {
+		return cniConfig, err
 	}
-	if cniConfig != nil {
-		log.V(5).Info("no env var CNI_NETWORK_CONFIG found on calico-node")
-		nc, err = cni.Parse(*cniConfig)
+	log.V(5).Info("no env var CNI_NETWORK_CONFIG found on calico-node")
+	return loadCNIConfigFile(ctx, comps)
+}
+
+// cniNetworkConfigKey is the key of the calico-config ConfigMap which holds the CNI config template.
+const cniNetworkConfigKey = "cni_network_config"
+
+// loadCNIConfigMap handles installs where the CNI config is written by an installer other than
+// calico-node's install-cni container, such as a separate job. The config on the host can't be
+// inspected, so the template is taken from the calico-config ConfigMap which such installers share
+// with the manifests. nil is returned if it isn't there.
+func loadCNIConfigMap(ctx context.Context, comps *components) (*string, error) {
+	cm := corev1.ConfigMap{}
+	if err := comps.client.Get(ctx, types.NamespacedName{Name: calicoConfigName, Namespace: metav1.NamespaceSystem}, &cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cniConfig, ok := cm.Data[cniNetworkConfigKey]
+	if !ok {
+		return nil, nil
+	}
+	comps.warn(ComponentCalicoNode, "calico-node has no install-cni container, so the CNI config was read from ConfigMap %s/%s. "+
+		"The operator will install the CNI config with install-cni after migration, so the external CNI installer should be removed",
+		metav1.NamespaceSystem, calicoConfigName)
+	return &cniConfig, nil
+}
+
+// loadCNIConfigFile handles install-cni reading the CNI config template from CNI_NETWORK_CONFIG_FILE
+// instead of CNI_NETWORK_CONFIG. The file itself can't be inspected, but if it is on an emptyDir which
+// another init container populates, the template is taken from that container's CNI_NETWORK_CONFIG.
+func loadCNIConfigFile(ctx context.Context, comps *components) (*string, error) {
+	path, err := comps.node.getEnv(ctx, comps.client, containerInstallCNI, "CNI_NETWORK_CONFIG_FILE")
+	if err != nil || path == nil {
+		return nil, err
 	}
 
-	return nc, err
+	incompatible := func(reason string) error {
+		return ErrIncompatibleCluster{
+			err:       fmt.Sprintf("install-cni reads the CNI config from CNI_NETWORK_CONFIG_FILE=%s, %s", *path, reason),
+			component: ComponentCNIConfig,
+			fix:       "set CNI_NETWORK_CONFIG on install-cni to the CNI config template instead",
+		}
+	}
+
+	spec := comps.node.Spec.Template.Spec
+	mount := getVolumeMountForPath(*getContainer(spec, containerInstallCNI), *path)
+	if mount == nil {
+		return nil, incompatible("which is not on a volume so the CNI config can't be read")
+	}
+	if vol := getVolume(spec, mount.Name); vol == nil || vol.EmptyDir == nil {
+		return nil, incompatible(fmt.Sprintf("which is on volume %s that isn't an emptyDir populated by an init container, so the CNI config can't be read", mount.Name))
+	}
+
+	for _, ic := range spec.InitContainers {
+		if ic.Name == containerInstallCNI || getVolumeMount(ic, mount.Name) == nil {
+			continue
+		}
+		cniConfig, err := comps.node.getEnv(ctx, comps.client, ic.Name, "CNI_NETWORK_CONFIG")
+		if err != nil {
+			return nil, err
+		}
+		if cniConfig != nil {
+			comps.dropVolume(mount.Name)
+			comps.warn(ComponentCalicoNode, "init container %s writes the CNI config read by install-cni, it will be removed after migration and install-cni will be configured with its CNI_NETWORK_CONFIG", ic.Name)
+			return cniConfig, nil
+		}
+	}
+
+	return nil, incompatible(fmt.Sprintf("which is on emptyDir volume %s that is populated when calico-node starts, so the CNI config can't be read", mount.Name))
+}
+
+// dropVolume records that the named calico-node volume is no longer needed after migration.
+func (c *components) dropVolume(name string) {
+	if c.droppedVolumes == nil {
+		c.droppedVolumes = map[string]bool{}
+	}
+	c.droppedVolumes[name] = true
 }
@@ -15,8 +15,6 @@ import (
 
 var log = logf.Log.WithName("migration_convert")
 
-var ctx = context.Background()
-
 // NeedsConversion checks if an existing installation of Calico exists which
 // is not managed by the Operator.
 func NeedsConversion(ctx context.Context, client client.Client) (bool, error) {
@@ -28,39 +26,104 @@ func NeedsConversion(ctx context.Context, client client.Client) (bool, error) {
 }
 
 // Convert updates an Installation resource based on an existing Calico install (i.e.
-// one that is not managed by operator). If the existing installation cannot be represented by an Installation
-// resource, an ErrIncompatibleCluster is returned.
-func Convert(ctx context.Context, client client.Client) (*operatorv1.Installation, error) {
+// one that is not managed by operator) on a cluster of the given provider. If the existing installation
+// cannot be represented by an Installation resource, an ErrIncompatibleCluster is returned.
+// Any notes and warnings raised during the conversion are logged.
+func Convert(ctx context.Context, client client.Client, provider operatorv1.Provider) (*operatorv1.Installation, error) {
+	install, report, err := ConvertWithReport(ctx, client, Options{Provider: provider})
+	if report != nil {
+		for _, n := range report.Notes {
+			log.Info("note during migration: " + n.String())
+		}
+		for _, w := range report.Warnings {
+			log.Info("warning during migration: " + w.String())
+		}
+	}
+	return install, err
+}
+
+// Mode controls how the migration treats configuration which the operator does not support.
+type Mode int
+
+const (
+	// ModeStrict fails the migration on any unsupported configuration.
+	ModeStrict Mode = iota
+	// ModeLenient records unsupported configuration as warnings in the report where possible,
+	// producing a best-effort Installation. Settings which the Installation can't stand in for,
+	// such as a non-kubernetes datastore, an overridden node name or an unrecognized IPAM plugin,
+	// still fail the migration.
+	ModeLenient
+)
+
+// Options configures a migration.
+type Options struct {
+	// Mode defaults to ModeStrict.
+	Mode Mode
+
+	// Provider is the auto-detected provider of the cluster, which determines the defaults the
+	// existing install is compared against.
+	Provider operatorv1.Provider
+}
+
+// ConvertWithReport behaves the same as Convert, but additionally returns a Report
+// of the findings that could not be represented by the Installation.
+func ConvertWithReport(ctx context.Context, client client.Client, opts Options) (*operatorv1.Installation, *Report, error) {
 	comps, err := getComponents(ctx, client)
 	if err != nil {
 		if kerrors.IsNotFound(err) {
 			log.Error(err, "no existing install found: %v", err)
-			return nil, nil
+			return nil, nil, nil
 		}
-		return nil, err
+		return nil, nil, err
+	}
+	if comps == nil {
+		// no calico-node daemonset, so there is nothing to convert.
+		return nil, nil, nil
 	}
+	comps.mode = opts.Mode
+	comps.provider = opts.Provider
 
 	install := &operatorv1.Installation{}
 	for _, hdlr := range handlers {
-		if err := hdlr(comps, install); err != nil {
-			return nil, err
+		name := handlerName(hdlr)
+		if present, ok := handlerPrerequisites[name]; ok && !present(comps) {
+			comps.recordHandler(name, HandlerSkipped)
+			continue
 		}
+		if err := hdlr(ctx, comps, install); err != nil {
+			comps.recordHandler(name, HandlerFailed)
+			return nil, &comps.report, err
+		}
+		comps.recordHandler(name, HandlerRan)
 	}
 
 	// Handle the remaining FelixVars last because we only want to take env vars which weren't accounted
 	// for by the other handlers
-	if err := handleFelixVars(comps); err != nil {
-		return nil, err
+	if err := handleFelixVars(ctx, comps); err != nil {
+		comps.recordHandler("handleFelixVars", HandlerFailed)
+		return nil, &comps.report, err
 	}
+	comps.recordHandler("handleFelixVars", HandlerRan)
 
-	// check for unchecked env vars
+	// check for unchecked env vars. In lenient mode these are only reported, as they will be dropped
+	// from the calico-node daemonset which the operator renders.
 	if uncheckedVars := comps.node.uncheckedVars(); len(uncheckedVars) != 0 {
-		return nil, ErrIncompatibleCluster{
+		if err := comps.incompatible(ErrIncompatibleCluster{
 			err:       fmt.Sprintf("unexpected env vars: %s", uncheckedVars),
 			component: ComponentCalicoNode,
 			fix:       "remove these environment variables from the calico-node daemonest",
+		}); err != nil {
+			return nil, &comps.report, err
 		}
 	}
 
-	return install, nil
+	// only write the FelixConfiguration and BGPConfiguration once nothing else can fail the migration.
+	if err := comps.felixConfig.apply(ctx, client); err != nil {
+		return nil, &comps.report, err
+	}
+	if err := comps.applyBGPConfiguration(ctx, client); err != nil {
+		return nil, &comps.report, err
+	}
+
+	return install, &comps.report, nil
 }
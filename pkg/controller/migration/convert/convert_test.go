@@ -5,8 +5,10 @@ import (
 	"fmt"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
+	operatorv1 "github.com/tigera/operator/api/v1"
 	"github.com/tigera/operator/pkg/apis"
 	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
 
@@ -14,6 +16,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	kscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -41,13 +44,13 @@ var _ = Describe("Parser", func() {
 
 	It("should detect an installation if one exists", func() {
 		c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
-		_, err := Convert(ctx, c)
+		_, err := Convert(ctx, c, operatorv1.ProviderNone)
 		Expect(err).ToNot(HaveOccurred())
 	})
 
 	It("should detect a valid installation", func() {
 		c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
-		_, err := Convert(ctx, c)
+		_, err := Convert(ctx, c, operatorv1.ProviderNone)
 		Expect(err).ToNot(HaveOccurred())
 	})
 
@@ -58,7 +61,7 @@ var _ = Describe("Parser", func() {
 				Namespace: "kube-system",
 			},
 		}, pool, emptyFelixConfig())
-		_, err := Convert(ctx, c)
+		_, err := Convert(ctx, c, operatorv1.ProviderNone)
 		Expect(err).To(HaveOccurred())
 	})
 
@@ -69,8 +72,147 @@ var _ = Describe("Parser", func() {
 			Value: "bar",
 		}}
 		c := fake.NewFakeClientWithScheme(scheme, node, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-		_, err := Convert(ctx, c)
+		_, err := Convert(ctx, c, operatorv1.ProviderNone)
 		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unexpected env vars: [calico-node/FOO]"))
+	})
+
+	It("should only warn about unchecked env vars when lenient", func() {
+		node := emptyNodeSpec()
+		node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+			Name:  "FOO",
+			Value: "bar",
+		}}
+		node.Spec.Template.Spec.InitContainers[0].Env = append(node.Spec.Template.Spec.InitContainers[0].Env, corev1.EnvVar{
+			Name:  "BAR",
+			Value: "baz",
+		})
+		c := fake.NewFakeClientWithScheme(scheme, node, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+		cfg, report, err := ConvertWithReport(ctx, c, Options{Mode: ModeLenient})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg).ToNot(BeNil())
+		Expect(report.Warnings).To(ContainElement(Warning{
+			Component: ComponentCalicoNode,
+			Message: "unexpected env vars: [calico-node/FOO install-cni/BAR]. " +
+				"To fix it, remove these environment variables from the calico-node daemonest",
+		}))
+	})
+
+	It("should list the manual steps for each unsupported setting in lenient mode", func() {
+		node := emptyNodeSpec()
+		node.Spec.Template.Spec.Containers[0].Env = append(node.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+			Name:  "FOO",
+			Value: "bar",
+		})
+		node.Spec.Template.Spec.InitContainers = append(node.Spec.Template.Spec.InitContainers, corev1.Container{
+			Name: "upgrade-ipam",
+			Env:  []corev1.EnvVar{{Name: "DATASTORE_TYPE", Value: "etcdv3"}},
+		})
+		kc := emptyKubeControllerSpec()
+		kc.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "HEALTH_ENABLED", Value: "false"}}
+
+		c := fake.NewFakeClientWithScheme(scheme, node, kc, pool, emptyFelixConfig())
+		_, report, err := ConvertWithReport(ctx, c, Options{Mode: ModeLenient})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.ManualSteps).To(Equal([]ManualStep{
+			{
+				Component: ComponentCalicoNode,
+				Issue:     "the upgrade-ipam init container uses DATASTORE_TYPE=etcdv3, only DATASTORE_TYPE=kubernetes is supported",
+				Step:      "complete the migration to the kubernetes datastore, then remove the upgrade-ipam init container",
+			},
+			{
+				Component: ComponentKubeControllers,
+				Issue:     "HEALTH_ENABLED=false is not supported, the operator checks the readiness of kube-controllers using its health reports",
+				Step:      "remove the HEALTH_ENABLED env var or set it to 'true'",
+			},
+			{
+				Component: ComponentCalicoNode,
+				Issue:     "unexpected env vars: [calico-node/FOO]",
+				Step:      "remove these environment variables from the calico-node daemonest",
+			},
+		}))
+	})
+
+	It("should carry on past unsupported settings which have a fix in lenient mode", func() {
+		node := emptyNodeSpec()
+		node.Spec.Template.Spec.NodeSelector = map[string]string{"calico": "true"}
+		node.Spec.Template.Spec.InitContainers = append(node.Spec.Template.Spec.InitContainers, corev1.Container{Name: "flexvol-driver"})
+		node.Spec.Template.Spec.Containers[0].Env = append(node.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+			Name:  "FELIX_BPFENABLED",
+			Value: "maybe",
+		})
+
+		c := fake.NewFakeClientWithScheme(scheme, node, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+		cfg, report, err := ConvertWithReport(ctx, c, Options{Mode: ModeLenient})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Spec.FlexVolumePath).To(Equal("None"))
+		Expect(report.ManualSteps).To(ConsistOf(
+			ManualStep{
+				Component: ComponentCalicoNode,
+				Issue:     "detected 'flexvol-driver' init container but no 'flexvol-driver-host' volume",
+				Step:      "restore the 'flexvol-driver-host' volume or remove the 'flexvol-driver' init container",
+			},
+			ManualStep{
+				Component: ComponentCalicoNode,
+				Issue:     "unsupported nodeSelector for calico-node daemonset: map[calico:true]",
+				Step:      "remove the nodeSelector",
+			},
+			ManualStep{
+				Component: ComponentCalicoNode,
+				Issue:     "FELIX_BPFENABLED is not valid: strconv.ParseBool: parsing \"maybe\": invalid syntax",
+				Step:      "correct or remove FELIX_BPFENABLED",
+			},
+		))
+
+		f := crdv1.FelixConfiguration{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+		Expect(f.Spec.BPFEnabled).To(BeNil())
+	})
+
+	It("should not list manual steps in strict mode", func() {
+		kc := emptyKubeControllerSpec()
+		kc.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "HEALTH_ENABLED", Value: "false"}}
+
+		c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), kc, pool, emptyFelixConfig())
+		_, report, err := ConvertWithReport(ctx, c, Options{})
+		Expect(err).To(HaveOccurred())
+		Expect(report.ManualSteps).To(BeEmpty())
+	})
+
+	It("should record the handlers which ran and were skipped for a node-only cluster", func() {
+		c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), pool, emptyFelixConfig())
+		_, report, err := ConvertWithReport(ctx, c, Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Handlers).To(HaveLen(len(handlers) + 1))
+		Expect(report.Handlers).To(ContainElement(HandlerRun{Name: "handleNetwork", Status: HandlerRan}))
+		Expect(report.Handlers).To(ContainElement(HandlerRun{Name: "handleCore", Status: HandlerRan}))
+		Expect(report.Handlers).To(ContainElement(HandlerRun{Name: "handleTyphaMetrics", Status: HandlerSkipped}))
+		Expect(report.Handlers).To(ContainElement(HandlerRun{Name: "handleTyphaScaling", Status: HandlerSkipped}))
+		Expect(report.Handlers).To(ContainElement(HandlerRun{Name: "handleKubeControllersHealth", Status: HandlerSkipped}))
+		Expect(report.Handlers[len(report.Handlers)-1]).To(Equal(HandlerRun{Name: "handleFelixVars", Status: HandlerRan}))
+	})
+
+	It("should record the node spec hash", func() {
+		node := emptyNodeSpec()
+		expected, err := hashPodSpec(node.Spec.Template.Spec)
+		Expect(err).ToNot(HaveOccurred())
+
+		c := fake.NewFakeClientWithScheme(scheme, node, pool, emptyFelixConfig())
+		_, report, err := ConvertWithReport(ctx, c, Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.NodeSpecHash).To(Equal(expected))
+	})
+
+	It("should record the handler which failed", func() {
+		node := emptyNodeSpec()
+		node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+			Name:  "FELIX_DEFAULTENDPOINTTOHOSTACTION",
+			Value: "Drop",
+		}}
+		c := fake.NewFakeClientWithScheme(scheme, node, pool, emptyFelixConfig())
+		_, report, err := ConvertWithReport(ctx, c, Options{})
+		Expect(err).To(HaveOccurred())
+		Expect(report.Handlers[len(report.Handlers)-1]).To(Equal(HandlerRun{Name: "handleNetwork", Status: HandlerFailed}))
 	})
 
 	It("should detect an MTU via substitution", func() {
@@ -87,7 +229,7 @@ var _ = Describe("Parser", func() {
 		}
 
 		c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-		cfg, err := Convert(ctx, c)
+		cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(cfg).ToNot(BeNil())
 		exp := int32(24)
@@ -102,10 +244,27 @@ var _ = Describe("Parser", func() {
 		}}
 
 		c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-		_, err := Convert(ctx, c)
+		_, err := Convert(ctx, c, operatorv1.ProviderNone)
 		Expect(err).To(HaveOccurred())
 	})
 
+	DescribeTable("should fail on cni which is not a json object", func(cniConfig string) {
+		ds := emptyNodeSpec()
+		ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+			Name:  "CNI_NETWORK_CONFIG",
+			Value: cniConfig,
+		}}
+
+		c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+		_, err := Convert(ctx, c, operatorv1.ProviderNone)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("is not a json object"))
+	},
+		Entry("null", "null"),
+		Entry("null plugin", `{"name": "k8s-pod-network", "plugins": [null]}`),
+		Entry("string plugin", `{"name": "k8s-pod-network", "plugins": ["calico"]}`),
+	)
+
 	Context("CNI", func() {
 		var _ = Describe("CNI", func() {
 			It("should load cni from correct fields on calico-node", func() {
@@ -145,11 +304,272 @@ var _ = Describe("Parser", func() {
 					client: cli,
 				}
 
-				nc, err := loadCNI(&c)
+				nc, err := loadCNI(ctx, &c)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(nc.CalicoConfig).ToNot(BeNil())
 				Expect(nc.CalicoConfig.IPAM.Type).To(Equal("calico-ipam"), fmt.Sprintf("Got %+v", c.cni.CalicoConfig))
 			})
+
+			It("should load cni from install-cni running as an init container", func() {
+				ds := emptyNodeSpec()
+				Expect(isInitContainer(ds.Spec.Template.Spec, containerInstallCNI)).To(BeTrue())
+				c := components{
+					node: CheckedDaemonSet{
+						DaemonSet:   *ds,
+						checkedVars: map[string]checkedFields{},
+					},
+					client: fake.NewFakeClient(ds, emptyKubeControllerSpec()),
+				}
+
+				nc, err := loadCNI(ctx, &c)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(nc.CalicoConfig).ToNot(BeNil())
+				Expect(nc.CalicoConfig.IPAM.Type).To(Equal("calico-ipam"))
+			})
+
+			It("should load cni from install-cni running as a sidecar", func() {
+				ds := nodeSpecWithCNISidecar()
+				Expect(isInitContainer(ds.Spec.Template.Spec, containerInstallCNI)).To(BeFalse())
+				c := components{
+					node: CheckedDaemonSet{
+						DaemonSet:   *ds,
+						checkedVars: map[string]checkedFields{},
+					},
+					client: fake.NewFakeClient(ds, emptyKubeControllerSpec()),
+				}
+
+				nc, err := loadCNI(ctx, &c)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(nc.CalicoConfig).ToNot(BeNil())
+				Expect(nc.CalicoConfig.IPAM.Type).To(Equal("calico-ipam"))
+			})
+
+			It("should not warn when install-cni runs as an init container", func() {
+				c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, report, err := ConvertWithReport(ctx, c, Options{})
+				Expect(err).ToNot(HaveOccurred())
+				for _, w := range report.Warnings {
+					Expect(w.Message).ToNot(ContainSubstring("install-cni"))
+				}
+			})
+
+			It("should warn when install-cni runs as a sidecar", func() {
+				c := fake.NewFakeClientWithScheme(scheme, nodeSpecWithCNISidecar(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				cfg, report, err := ConvertWithReport(ctx, c, Options{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
+				Expect(report.Warnings).To(ContainElement(Warning{
+					Component: ComponentCalicoNode,
+					Message:   "install-cni runs as a sidecar container, it will be run as an init container after migration so the CNI config will only be written when calico-node starts",
+				}))
+			})
+		})
+	})
+
+	Context("CNI_NETWORK_CONFIG_FILE", func() {
+		cniConfigFileNodeSpec := func(volume corev1.VolumeSource, writerEnv ...corev1.EnvVar) *appsv1.DaemonSet {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Volumes = append(ds.Spec.Template.Spec.Volumes, corev1.Volume{
+				Name:         "cni-config-template",
+				VolumeSource: volume,
+			})
+			mount := corev1.VolumeMount{Name: "cni-config-template", MountPath: "/config"}
+			ds.Spec.Template.Spec.InitContainers = []corev1.Container{
+				{
+					Name:         "render-cni-config",
+					Image:        "example.com/render-cni-config:v1",
+					Env:          writerEnv,
+					VolumeMounts: []corev1.VolumeMount{mount},
+				},
+				{
+					Name:         "install-cni",
+					Env:          []corev1.EnvVar{{Name: "CNI_NETWORK_CONFIG_FILE", Value: "/config/10-calico.conflist"}},
+					VolumeMounts: []corev1.VolumeMount{mount},
+				},
+			}
+			return ds
+		}
+		emptyDir := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+
+		It("should read the template from the init container which writes it", func() {
+			ds := cniConfigFileNodeSpec(emptyDir, corev1.EnvVar{
+				Name:  "CNI_NETWORK_CONFIG",
+				Value: `{"type": "calico", "name": "k8s-pod-network", "ipam": {"type": "calico-ipam"}}`,
+			})
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			cfg, report, err := ConvertWithReport(ctx, c, Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
+			Expect(report.Warnings).To(ContainElement(Warning{
+				Component: ComponentCalicoNode,
+				Message:   "init container render-cni-config writes the CNI config read by install-cni, it will be removed after migration and install-cni will be configured with its CNI_NETWORK_CONFIG",
+			}))
+		})
+
+		It("should error if the template is generated at runtime", func() {
+			ds := cniConfigFileNodeSpec(emptyDir)
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("which is on emptyDir volume cni-config-template that is populated when calico-node starts"))
+		})
+
+		It("should error if the template is not on an emptyDir", func() {
+			ds := cniConfigFileNodeSpec(corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/etc/cni-template"}})
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("isn't an emptyDir populated by an init container"))
+		})
+	})
+
+	Context("without install-cni", func() {
+		externalCNINodeSpec := func() *appsv1.DaemonSet {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.InitContainers = nil
+			return ds
+		}
+		calicoConfig := func(data map[string]string) *corev1.ConfigMap {
+			return &corev1.ConfigMap{
+				ObjectMeta: v1.ObjectMeta{Name: "calico-config", Namespace: "kube-system"},
+				Data:       data,
+			}
+		}
+
+		It("should read the CNI config from the calico-config ConfigMap", func() {
+			cm := calicoConfig(map[string]string{"cni_network_config": `{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [{"type": "calico", "mtu": __CNI_MTU__, "ipam": {"type": "calico-ipam"}}]
+}`})
+			c := fake.NewFakeClientWithScheme(scheme, externalCNINodeSpec(), cm, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			cfg, report, err := ConvertWithReport(ctx, c, Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
+			Expect(cfg.Spec.CNI.IPAM.Type).To(Equal(operatorv1.IPAMPluginCalico))
+			Expect(*cfg.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1500))
+			Expect(report.Warnings).To(ContainElement(Warning{
+				Component: ComponentCalicoNode,
+				Message: "calico-node has no install-cni container, so the CNI config was read from ConfigMap kube-system/calico-config. " +
+					"The operator will install the CNI config with install-cni after migration, so the external CNI installer should be removed",
+			}))
+		})
+
+		It("should error if the CNI config can't be found", func() {
+			c := fake.NewFakeClientWithScheme(scheme, externalCNINodeSpec(), calicoConfig(map[string]string{"calico_backend": "bird"}),
+				emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("calico-node has no install-cni container and ConfigMap kube-system/calico-config has no cni_network_config"))
+			Expect(err.Error()).ToNot(ContainSubstring("couldn't find"))
+		})
+
+		It("should convert a non-Calico CNI", func() {
+			ds := externalCNINodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+				{Name: "FELIX_INTERFACEPREFIX", Value: "eni"},
+				{Name: "CALICO_NETWORKING_BACKEND", Value: "none"},
+				{Name: "FELIX_IPTABLESMANGLEALLOWACTION", Value: "Return"},
+			}
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginAmazonVPC))
+		})
+	})
+
+	Context("renamed containers", func() {
+		renamedNodeSpec := func() *appsv1.DaemonSet {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Name = "node"
+			ds.Spec.Template.Spec.Containers[0].Image = "registry.local:5000/calico/node:v3.16.0"
+			ds.Spec.Template.Spec.InitContainers[0].Name = "cni"
+			ds.Spec.Template.Spec.InitContainers[0].Image = "calico/cni:v3.16.0"
+			return ds
+		}
+
+		It("should find containers by their image", func() {
+			c := fake.NewFakeClientWithScheme(scheme, renamedNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			comps, err := getComponents(ctx, c)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comps.containerNames).To(Equal(map[string]string{
+				containerCalicoNode: "node",
+				containerInstallCNI: "cni",
+			}))
+			Expect(getContainer(comps.node.Spec.Template.Spec, containerCalicoNode)).ToNot(BeNil())
+			Expect(comps.cni.CalicoConfig).ToNot(BeNil())
+		})
+
+		It("should convert and warn about renamed containers", func() {
+			c := fake.NewFakeClientWithScheme(scheme, renamedNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			cfg, report, err := ConvertWithReport(ctx, c, Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
+			Expect(report.Warnings).To(ContainElement(Warning{
+				Component: ComponentCalicoNode,
+				Message:   "container node was identified as calico-node by its image, it will be named calico-node after migration",
+			}))
+		})
+
+		It("should hash the node spec as it was before the containers were renamed", func() {
+			ds := renamedNodeSpec()
+			expected, err := hashPodSpec(ds.Spec.Template.Spec)
+			Expect(err).ToNot(HaveOccurred())
+
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			_, report, err := ConvertWithReport(ctx, c, Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.NodeSpecHash).To(Equal(expected))
+		})
+
+		It("should not treat upgrade-ipam as install-cni", func() {
+			ds := renamedNodeSpec()
+			ds.Spec.Template.Spec.InitContainers[0].Name = "upgrade-ipam"
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			comps, err := getComponents(ctx, c)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comps.containerNames).ToNot(HaveKey(containerInstallCNI))
+		})
+
+		It("should convert with the upgrade-ipam and flexvol-driver init containers", func() {
+			ds := emptyNodeSpec()
+			hostPathDirectoryOrCreate := corev1.HostPathDirectoryOrCreate
+			ds.Spec.Template.Spec.Volumes = append(ds.Spec.Template.Spec.Volumes, corev1.Volume{
+				Name: "flexvol-driver-host",
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{
+						Path: "/usr/libexec/kubernetes/kubelet-plugins/volume/exec/nodeagent~uds",
+						Type: &hostPathDirectoryOrCreate,
+					},
+				},
+			})
+			ds.Spec.Template.Spec.InitContainers = append(ds.Spec.Template.Spec.InitContainers,
+				corev1.Container{
+					Name:  "upgrade-ipam",
+					Image: "calico/cni:v3.16.0",
+					Env: []corev1.EnvVar{
+						{Name: "KUBERNETES_NODE_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
+						{Name: "CALICO_NETWORKING_BACKEND", Value: "bird"},
+					},
+				},
+				corev1.Container{
+					Name:  "flexvol-driver",
+					Image: "calico/pod2daemon-flexvol:v3.16.0",
+				},
+			)
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg).ToNot(BeNil())
+		})
+
+		It("should error if no container runs calico/node", func() {
+			ds := renamedNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Image = "example.com/node:v1"
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("couldn't find a container named calico-node or running the calico/node image"))
 		})
 	})
 })
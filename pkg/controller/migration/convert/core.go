@@ -1,16 +1,21 @@
 package convert
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 
 	operatorv1 "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/render"
+	rmeta "github.com/tigera/operator/pkg/render/common/meta"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-func handleCore(c *components, install *operatorv1.Installation) error {
+func handleCore(ctx context.Context, c *components, install *operatorv1.Installation) error {
 	dsType, err := c.node.getEnv(ctx, c.client, "calico-node", "DATASTORE_TYPE")
 	if err != nil {
 		return err
@@ -61,36 +66,57 @@ func handleCore(c *components, install *operatorv1.Installation) error {
 	}
 
 	// node update-strategy
-	install.Spec.NodeUpdateStrategy = c.node.Spec.UpdateStrategy
+	updateStrategy, err := getNodeUpdateStrategy(c)
+	if err != nil {
+		return err
+	}
+	install.Spec.NodeUpdateStrategy = updateStrategy
+	// the operator only rolls out calico-node with RollingUpdate, so OnDelete is carried forward
+	// with a warning rather than silently changed.
+	if updateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+		c.warn(ComponentCalicoNode, "updateStrategy is OnDelete, which the operator does not support. "+
+			"Set the Installation's nodeUpdateStrategy to RollingUpdate, after which calico-node pods are "+
+			"updated by the operator rather than when they are deleted manually")
+	}
 
 	// alp
 	vol := getVolume(c.node.Spec.Template.Spec, "flexvol-driver-host")
 	if vol != nil {
 		// prefer user-defined flexvolpath over detected value
+		// a flexvol driver which can't be carried forward is dropped when the migration is lenient.
 		if install.Spec.FlexVolumePath == "" {
 			if vol.HostPath == nil {
-				return ErrIncompatibleCluster{
+				if err := c.incompatible(ErrIncompatibleCluster{
 					err:       "volume 'flexvol-driver-host' must be a HostPath",
 					component: ComponentCalicoNode,
 					fix:       "remove the 'flexvol-driver-host' volume or convert it to type hostPath",
+				}); err != nil {
+					return err
 				}
-			}
-			if fv := getContainer(c.node.Spec.Template.Spec, "flexvol-driver"); fv == nil {
-				return ErrIncompatibleCluster{
+				install.Spec.FlexVolumePath = "None"
+			} else if fv := getContainer(c.node.Spec.Template.Spec, "flexvol-driver"); fv == nil {
+				if err := c.incompatible(ErrIncompatibleCluster{
 					err:       "detected 'flexvol-driver-host' volume but no 'flexvol-driver' init container",
 					component: ComponentCalicoNode,
 					fix:       "remove the 'flexvol-driver-host' volume or restore the 'flexvol-driver' init container",
+				}); err != nil {
+					return err
 				}
+				install.Spec.FlexVolumePath = "None"
+			} else {
+				install.Spec.FlexVolumePath = flexVolumePath(vol.HostPath.Path)
+				checkFlexVolumeDriver(c, install, vol.HostPath.Path)
 			}
-			install.Spec.FlexVolumePath = vol.HostPath.Path
 		}
 	} else {
 		// verify that no flexvol container is set
 		if fv := getContainer(c.node.Spec.Template.Spec, "flexvol-driver"); fv != nil {
-			return ErrIncompatibleCluster{
+			if err := c.incompatible(ErrIncompatibleCluster{
 				err:       "detected 'flexvol-driver' init container but no 'flexvol-driver-host' volume",
 				component: ComponentCalicoNode,
 				fix:       "restore the 'flexvol-driver-host' volume or remove the 'flexvol-driver' init container",
+			}); err != nil {
+				return err
 			}
 		}
 		install.Spec.FlexVolumePath = "None"
@@ -109,25 +135,46 @@ func handleCore(c *components, install *operatorv1.Installation) error {
 	if err := checkNodeHostPathVolume(c.node.Spec.Template.Spec, "xtables-lock", "/run/xtables.lock"); err != nil {
 		return err
 	}
-	if c.cni.CalicoConfig != nil {
-		if err := checkNodeHostPathVolume(c.node.Spec.Template.Spec, "cni-bin-dir", "/opt/cni/bin"); err != nil {
+	// without install-cni, the CNI directories were set up by an external installer. The operator
+	// renders its own install-cni and CNI volumes, so there is nothing to compare them with.
+	if c.cni.CalicoConfig != nil && getContainer(c.node.Spec.Template.Spec, containerInstallCNI) != nil {
+		if err := checkCNIDirectories(ctx, c, install); err != nil {
 			return err
 		}
-		if err := checkNodeHostPathVolume(c.node.Spec.Template.Spec, "cni-net-dir", "/etc/cni/net.d"); err != nil {
+	}
+
+	// check that nodename is a ref. calico-node falls back to HOSTNAME if NODENAME is not set,
+	// so it must also be checked in that case.
+	e, err := c.node.getEnvVar("calico-node", "NODENAME")
+	if err != nil {
+		return err
+	}
+	if err := checkNodeNameOverride("calico-node", e); err != nil {
+		return err
+	}
+	hostname, err := c.node.getEnvVar("calico-node", "HOSTNAME")
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		if err := checkNodeNameOverride("calico-node", hostname); err != nil {
 			return err
 		}
 	}
 
-	// check that nodename is a ref
-	e, err := c.node.getEnvVar("calico-node", "NODENAME")
+	// CALICO_K8S_NODE_REF links the calico node to its kubernetes node. The operator does not set it,
+	// which is only equivalent if it references the kubernetes node name.
+	e, err = c.node.getEnvVar("calico-node", "CALICO_K8S_NODE_REF")
 	if err != nil {
 		return err
 	}
 	if e != nil && (e.ValueFrom == nil || e.ValueFrom.FieldRef == nil || e.ValueFrom.FieldRef.FieldPath != "spec.nodeName") {
-		return ErrIncompatibleCluster{
-			err:       "NODENAME on 'calico-node' container must be unset or be a FieldRef to 'spec.nodeName'",
+		if err := c.incompatible(ErrIncompatibleCluster{
+			err:       "CALICO_K8S_NODE_REF on 'calico-node' container must be unset or be a FieldRef to 'spec.nodeName'",
 			component: ComponentCalicoNode,
-			fix:       "remove the NODENAME env var or convert it to a fieldRef with value 'spec.nodeName'",
+			fix:       "remove the CALICO_K8S_NODE_REF env var or convert it to a fieldRef with value 'spec.nodeName'",
+		}); err != nil {
+			return err
 		}
 	}
 
@@ -136,36 +183,172 @@ func handleCore(c *components, install *operatorv1.Installation) error {
 		if err != nil {
 			return err
 		}
-		if e != nil && (e.ValueFrom == nil || e.ValueFrom.FieldRef == nil || e.ValueFrom.FieldRef.FieldPath != "spec.nodeName") {
-			return ErrIncompatibleCluster{
-				err:       "KUBERNETES_NODE_NAME on 'install-cni' container must be unset or be a FieldRef to 'spec.nodeName'",
-				component: ComponentCalicoNode,
-				fix:       "remove the KUBERNETES_NODE_NAME env var or convert it to a fieldRef with value 'spec.nodeName'",
-			}
+		if err := checkNodeNameOverride("install-cni", e); err != nil {
+			return err
 		}
 
-		if err := c.node.assertEnv(ctx, c.client, containerInstallCNI, "CNI_CONF_NAME", "10-calico.conflist"); err != nil {
+		if err := handleCNIConfName(ctx, c); err != nil {
 			return err
 		}
+
+		// older manifests ran install-cni as a sidecar which kept the CNI config up to date while it slept.
+		// the operator runs it as an init container, so the config is only written when calico-node starts.
+		if !isInitContainer(c.node.Spec.Template.Spec, containerInstallCNI) {
+			c.warn(ComponentCalicoNode, "install-cni runs as a sidecar container, it will be run as an init container after migration so the CNI config will only be written when calico-node starts")
+		}
+	}
+
+	if ipam := getContainer(c.node.Spec.Template.Spec, "upgrade-ipam"); ipam != nil {
+		e, err = c.node.getEnvVar("upgrade-ipam", "KUBERNETES_NODE_NAME")
+		if err != nil {
+			return err
+		}
+		if err := checkNodeNameOverride("upgrade-ipam", e); err != nil {
+			return err
+		}
+	}
+
+	if err := handleFileLogging(ctx, c); err != nil {
+		return err
+	}
+
+	if err := handleWaitForDatastore(ctx, c); err != nil {
+		return err
 	}
 
-	c.node.ignoreEnv("calico-node", "WAIT_FOR_DATASTORE")
 	c.node.ignoreEnv("calico-node", "CLUSTER_TYPE")
-	c.node.ignoreEnv("calico-node", "CALICO_DISABLE_FILE_LOGGING")
 	c.node.ignoreEnv("calico-node", "CALICO_IPV4POOL_IPIP")
 	c.node.ignoreEnv("calico-node", "CALICO_IPV4POOL_VXLAN")
 	c.node.ignoreEnv("calico-node", "FELIX_LOGSEVERITYSCREEN")
 	c.node.ignoreEnv("calico-node", "FELIX_HEALTHENABLED")
-	c.node.ignoreEnv("calico-node", "FELIX_USAGEREPORTINGENABLED")
 	c.node.ignoreEnv("calico-node", "FELIX_TYPHAK8SSERVICENAME")
 	c.node.ignoreEnv("calico-node", "FELIX_LOGSEVERITYSYS")
-	c.node.ignoreEnv("upgrade-ipam", "KUBERNETES_NODE_NAME")
-	c.node.ignoreEnv("upgrade-ipam", "CALICO_NETWORKING_BACKEND")
 	c.node.ignoreEnv("install-cni", "SLEEP")
 
 	return nil
 }
 
+// handleFileLogging checks CALICO_DISABLE_FILE_LOGGING on calico-node. The operator always disables
+// file logging and leaves logs to the container runtime, so an unset or true value carries over as is.
+// If file logging was explicitly enabled, a warning is recorded since the logs will no longer be
+// written to /var/log/calico on each host.
+func handleFileLogging(ctx context.Context, c *components) error {
+	val, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "CALICO_DISABLE_FILE_LOGGING")
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return nil
+	}
+	disabled, err := strconv.ParseBool(*val)
+	if err != nil {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("CALICO_DISABLE_FILE_LOGGING=%s is not a valid boolean", *val),
+			component: ComponentCalicoNode,
+			fix:       "set CALICO_DISABLE_FILE_LOGGING to true or false",
+		})
+	}
+	if !disabled {
+		c.warn(ComponentCalicoNode, "CALICO_DISABLE_FILE_LOGGING=%s enables file logging, the operator disables it "+
+			"so calico-node logs will only be available from the container logs after migration", *val)
+	}
+	return nil
+}
+
+// cniConfName is the file name the operator's install-cni writes the CNI config to.
+const cniConfName = "10-calico.conflist"
+
+// handleCNIConfName checks CNI_CONF_NAME on install-cni. The container runtime uses the lexically first
+// config in the CNI net dir, so the name calico's config is written to decides which CNI plugin is in use.
+// The operator always writes cniConfName and does not remove the existing file, so a warning is recorded
+// for any other name describing how the precedence may change after migration.
+func handleCNIConfName(ctx context.Context, c *components) error {
+	name, err := c.node.getEnv(ctx, c.client, containerInstallCNI, "CNI_CONF_NAME")
+	if err != nil {
+		return err
+	}
+	if name == nil || *name == cniConfName {
+		return nil
+	}
+	if *name < cniConfName {
+		c.warn(ComponentCalicoNode, "CNI_CONF_NAME=%s sorts before %s which the operator writes, "+
+			"so the existing %s will still take precedence after migration until it is removed from the CNI net dir", *name, cniConfName, *name)
+	} else {
+		c.warn(ComponentCalicoNode, "CNI_CONF_NAME=%s does not sort first, so a lexically earlier CNI config may take precedence over calico. "+
+			"The operator writes %s, which may change the CNI config in use after migration", *name, cniConfName)
+	}
+	return nil
+}
+
+// handleWaitForDatastore checks WAIT_FOR_DATASTORE on calico-node. A false value means calico-node
+// was expected to start without waiting for the datastore, which the operator's startup does not
+// guarantee, so a warning is recorded.
+func handleWaitForDatastore(ctx context.Context, c *components) error {
+	val, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "WAIT_FOR_DATASTORE")
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return nil
+	}
+	wait, err := strconv.ParseBool(*val)
+	if err != nil {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("WAIT_FOR_DATASTORE=%s is not a valid boolean", *val),
+			component: ComponentCalicoNode,
+			fix:       "set WAIT_FOR_DATASTORE to true or remove it",
+		})
+	}
+	if !wait {
+		c.warn(ComponentCalicoNode, "WAIT_FOR_DATASTORE=%s, calico-node will wait for the datastore to be ready "+
+			"after migration so it may not start while the datastore is unavailable", *val)
+	}
+	return nil
+}
+
+// checkNodeNameOverride returns an error if the given node name env var on the container is set to anything
+// other than a FieldRef to 'spec.nodeName'. The operator always derives the node name from the Kubernetes
+// node, so any override would change the node's identity in Calico after migration.
+func checkNodeNameOverride(container string, e *corev1.EnvVar) error {
+	if e == nil || (e.ValueFrom != nil && e.ValueFrom.FieldRef != nil && e.ValueFrom.FieldRef.FieldPath == "spec.nodeName") {
+		return nil
+	}
+	override := fmt.Sprintf("%s=%s", e.Name, e.Value)
+	if e.ValueFrom != nil && e.ValueFrom.FieldRef != nil {
+		override = fmt.Sprintf("%s from fieldRef '%s'", e.Name, e.ValueFrom.FieldRef.FieldPath)
+	} else if e.ValueFrom != nil {
+		override = fmt.Sprintf("%s from a non-fieldRef source", e.Name)
+	}
+	return ErrIncompatibleCluster{
+		err: fmt.Sprintf("%s on '%s' container overrides the node name, the operator always uses the Kubernetes node name so the node's identity in Calico would change. "+
+			"%s must be unset or be a FieldRef to 'spec.nodeName'", override, container, e.Name),
+		component: ComponentCalicoNode,
+		fix:       fmt.Sprintf("remove the %s env var or convert it to a fieldRef with value 'spec.nodeName'", e.Name),
+	}
+}
+
+// getNodeUpdateStrategy returns the update strategy used by the existing calico-node daemonset,
+// including any custom maxUnavailable. Fields which are not set are left empty so that
+// only they are filled in by the operator's defaults. In lenient mode an invalid maxUnavailable
+// is dropped so that the default is used instead.
+func getNodeUpdateStrategy(c *components) (appsv1.DaemonSetUpdateStrategy, error) {
+	strategy := c.node.Spec.UpdateStrategy.DeepCopy()
+	if strategy.RollingUpdate != nil && strategy.RollingUpdate.MaxUnavailable != nil {
+		maxUnavailable := strategy.RollingUpdate.MaxUnavailable
+		if v, err := intstr.GetValueFromIntOrPercent(maxUnavailable, 100, true); err != nil || v <= 0 {
+			if err := c.incompatible(ErrIncompatibleCluster{
+				err:       fmt.Sprintf("invalid maxUnavailable '%s' in updateStrategy", maxUnavailable.String()),
+				component: ComponentCalicoNode,
+				fix:       "set maxUnavailable to a positive integer or percentage",
+			}); err != nil {
+				return appsv1.DaemonSetUpdateStrategy{}, err
+			}
+			strategy.RollingUpdate.MaxUnavailable = nil
+		}
+	}
+	return *strategy, nil
+}
+
 // checkNodeHostPathVolume returns an error if a hostpath with the passed in name and path does not exist in a given podspec.
 func checkNodeHostPathVolume(spec corev1.PodSpec, name, path string) error {
 	v := getVolume(spec, name)
@@ -179,6 +362,177 @@ func checkNodeHostPathVolume(spec corev1.PodSpec, name, path string) error {
 	return nil
 }
 
+// cniDirectories are the host directories which hold CNI binaries and network config.
+type cniDirectories struct {
+	bin string
+	net string
+}
+
+// expectedCNIDirectories returns the CNI directories the operator renders for the given provider.
+func expectedCNIDirectories(provider operatorv1.Provider) cniDirectories {
+	net, bin := render.CNIDirectories(provider)
+	return cniDirectories{bin: bin, net: net}
+}
+
+// flexVolumeDriverDir is the directory within the flexvolume path which the operator mounts into the
+// flexvol-driver init container.
+const flexVolumeDriverDir = "nodeagent~uds"
+
+// flexVolumePath returns the FlexVolumePath for the path of the flexvol-driver-host volume. The operator
+// mounts the driver directory within FlexVolumePath, so it is trimmed from the volume's path.
+func flexVolumePath(hostPath string) string {
+	return strings.TrimSuffix(hostPath, flexVolumeDriverDir)
+}
+
+// checkFlexVolumeDriver warns when the flexvol-driver init container, its flexvol-driver-host volume and the
+// cluster provider's default flexvolume path are not consistent, as the operator renders them from FlexVolumePath alone.
+func checkFlexVolumeDriver(c *components, install *operatorv1.Installation, hostPath string) {
+	spec := c.node.Spec.Template.Spec
+	fv := getContainer(spec, "flexvol-driver")
+	if fv.Image != "" && !strings.Contains(fv.Image, "pod2daemon-flexvol") {
+		c.warn(ComponentCalicoNode, "the flexvol-driver init container runs image %s, the operator will replace it with its own pod2daemon-flexvol image", fv.Image)
+	}
+
+	mounted := false
+	for _, m := range fv.VolumeMounts {
+		if m.Name == "flexvol-driver-host" {
+			mounted = true
+			break
+		}
+	}
+	if !mounted {
+		c.warn(ComponentCalicoNode, "the flexvol-driver init container does not mount the 'flexvol-driver-host' volume, "+
+			"the operator will install the driver into %s%s", install.Spec.FlexVolumePath, flexVolumeDriverDir)
+	}
+
+	if !strings.HasSuffix(hostPath, flexVolumeDriverDir) {
+		c.warn(ComponentCalicoNode, "the 'flexvol-driver-host' volume path %s does not end in %s, "+
+			"the operator will mount %s%s instead", hostPath, flexVolumeDriverDir, install.Spec.FlexVolumePath, flexVolumeDriverDir)
+	}
+
+	if expected := render.DefaultFlexVolumePath(c.provider); install.Spec.FlexVolumePath != expected {
+		c.warn(ComponentCalicoNode, "the flexvolume path %s differs from the default %s, "+
+			"check that the kubelet's --volume-plugin-dir is set to it", install.Spec.FlexVolumePath, expected)
+	}
+}
+
+// runtimeCNIDirectories are the CNI directories used by container runtimes and distributions which
+// do not follow the default docker / containerd conventions, keyed by a description of the runtime.
+var runtimeCNIDirectories = map[string]cniDirectories{
+	"GKE containerd":      {bin: "/home/kubernetes/bin", net: "/etc/cni/net.d"},
+	"k3s containerd":      {bin: "/var/lib/rancher/k3s/data/current/bin", net: "/var/lib/rancher/k3s/agent/etc/cni/net.d"},
+	"microk8s containerd": {bin: "/var/snap/microk8s/current/opt/cni/bin", net: "/var/snap/microk8s/current/args/cni-network"},
+}
+
+// checkCNIDirectories ensures the cni-bin-dir and cni-net-dir volumes use the directories the
+// operator will render for the cluster's provider. Directories which follow the conventions of a
+// known container runtime are called out so that the user knows why they can't be migrated.
+func checkCNIDirectories(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	expected := expectedCNIDirectories(c.provider)
+
+	spec := c.node.Spec.Template.Spec
+	// in lenient mode, unexpected directories are replaced by the ones the operator renders.
+	checkVolume := func(name, path string) error {
+		if err := checkNodeHostPathVolume(spec, name, path); err != nil {
+			return c.incompatible(err.(ErrIncompatibleCluster))
+		}
+		return nil
+	}
+
+	bin, net := getVolume(spec, "cni-bin-dir"), getVolume(spec, "cni-net-dir")
+	if bin == nil || bin.HostPath == nil || net == nil || net.HostPath == nil {
+		if err := checkVolume("cni-bin-dir", expected.bin); err != nil {
+			return err
+		}
+		return checkVolume("cni-net-dir", expected.net)
+	}
+	found := cniDirectories{bin: bin.HostPath.Path, net: net.HostPath.Path}
+
+	if found != expected {
+		for runtime, dirs := range runtimeCNIDirectories {
+			if found == dirs {
+				return c.incompatible(ErrIncompatibleCluster{
+					err:       fmt.Sprintf("CNI directories '%s' and '%s' follow %s conventions, which are not supported for this cluster", found.bin, found.net, runtime),
+					component: ComponentCalicoNode,
+					fix:       fmt.Sprintf("configure the container runtime to use '%s' and '%s', and update the cni-bin-dir and cni-net-dir volumes to match", expected.bin, expected.net),
+				})
+			}
+		}
+		if err := checkVolume("cni-bin-dir", expected.bin); err != nil {
+			return err
+		}
+		return checkVolume("cni-net-dir", expected.net)
+	}
+
+	// CNI_NET_DIR tells install-cni where the network config lives on the host so that it can
+	// reference the kubeconfig it writes there. The operator always sets it to the cni-net-dir path.
+	netDir, err := c.node.getEnv(ctx, c.client, containerInstallCNI, "CNI_NET_DIR")
+	if err != nil {
+		return err
+	}
+	if netDir != nil && *netDir != found.net {
+		c.warn(ComponentCalicoNode, "CNI_NET_DIR=%s does not match the cni-net-dir volume '%s', it will be set to '%s' after migration", *netDir, found.net, found.net)
+	}
+
+	return nil
+}
+
+// knownNodeVolumes are the volumes which the operator either renders for calico-node itself, or
+// which are validated and carried forward by other handlers.
+var knownNodeVolumes = map[string]bool{
+	"lib-modules":         true,
+	"var-run-calico":      true,
+	"var-lib-calico":      true,
+	"xtables-lock":        true,
+	"policysync":          true,
+	"sysfs":               true,
+	"cni-bin-dir":         true,
+	"cni-net-dir":         true,
+	"cni-log-dir":         true,
+	"host-local-net-dir":  true,
+	"flexvol-driver-host": true,
+	"typha-ca":            true,
+	"felix-certs":         true,
+}
+
+// handleNodeVolumes is a migration handler which ensures calico-node does not have any volumes
+// the operator would not reproduce, such as hostPaths for custom BIRD templates.
+func handleNodeVolumes(_ context.Context, c *components, _ *operatorv1.Installation) error {
+	spec := c.node.Spec.Template.Spec
+
+	known := func(name string) bool {
+		return knownNodeVolumes[name] || c.droppedVolumes[name]
+	}
+
+	var unexpected []string
+	for _, v := range spec.Volumes {
+		if !known(v.Name) {
+			unexpected = append(unexpected, v.Name)
+		}
+	}
+
+	var mounts []string
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			for _, m := range container.VolumeMounts {
+				if !known(m.Name) {
+					mounts = append(mounts, container.Name+":"+m.MountPath)
+				}
+			}
+		}
+	}
+
+	if len(unexpected) != 0 || len(mounts) != 0 {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("unexpected volumes %v mounted at %v", unexpected, mounts),
+			component: ComponentCalicoNode,
+			fix:       "remove the volumes and volumeMounts, and recreate whatever they provided once migration is complete",
+		})
+	}
+
+	return nil
+}
+
 // addResources adds the rescReq resource for the specified component if none was previously set. If installation
 // already had a resource for compName then they are compared and if they are different then an error is returned.
 // If the Resource is added to installation or the existing one matches then nil is returned.
@@ -216,8 +570,13 @@ func addResources(install *operatorv1.Installation, compName operatorv1.Componen
 // handleAnnotations is a migration handler that ensures the components only have expected annotations.
 // since Operator does not support setting custom annotations on components, these annotations
 // would otherwise be dropped.
-func handleAnnotations(c *components, _ *operatorv1.Installation) error {
-	if a := removeExpectedAnnotations(c.node.Annotations, map[string]string{}); len(a) != 0 {
+func handleAnnotations(_ context.Context, c *components, _ *operatorv1.Installation) error {
+	// the auto-detected mtu is carried forward by handleMTU.
+	ignore := map[string]string{}
+	if v, ok := c.node.Annotations[mtuAnnotation]; ok {
+		ignore[mtuAnnotation] = v
+	}
+	if a := removeExpectedAnnotations(c.node.Annotations, ignore); len(a) != 0 {
 		return ErrIncompatibleAnnotation(a, ComponentCalicoNode)
 	}
 
@@ -278,7 +637,7 @@ func removeExpectedAnnotations(existing, ignore map[string]string) map[string]st
 // In general, setting custom nodeSelectors and nodeAffinity for components is not supported.
 // The exception to this is the calico-node nodeSelector, which is migrated into the
 // ControlPlaneNodeSelector field.
-func handleNodeSelectors(c *components, install *operatorv1.Installation) error {
+func handleNodeSelectors(_ context.Context, c *components, install *operatorv1.Installation) error {
 	// check calico-node nodeSelectors
 	if c.node.Spec.Template.Spec.Affinity != nil {
 		if install.Spec.KubernetesProvider != operatorv1.ProviderAKS || !reflect.DeepEqual(c.node.Spec.Template.Spec.Affinity, &corev1.Affinity{
@@ -294,10 +653,12 @@ func handleNodeSelectors(c *components, install *operatorv1.Installation) error
 				},
 			},
 		}) {
-			return ErrIncompatibleCluster{
+			if err := c.incompatible(ErrIncompatibleCluster{
 				err:       "node affinity not supported for calico-node daemonset",
 				component: ComponentCalicoNode,
 				fix:       "remove the affinity",
+			}); err != nil {
+				return err
 			}
 		}
 	}
@@ -307,12 +668,13 @@ func handleNodeSelectors(c *components, install *operatorv1.Installation) error
 	}
 	if len(nodeSel) > 0 {
 		// raise error unless the only nodeSelector is the  calico-node migration nodeSelector
-		return ErrIncompatibleCluster{
+		if err := c.incompatible(ErrIncompatibleCluster{
 			err:       fmt.Sprintf("unsupported nodeSelector for calico-node daemonset: %v", nodeSel),
 			component: ComponentCalicoNode,
 			fix:       "remove the nodeSelector",
+		}); err != nil {
+			return err
 		}
-
 	}
 
 	// check typha nodeSelectors
@@ -320,18 +682,22 @@ func handleNodeSelectors(c *components, install *operatorv1.Installation) error
 		// we can migrate typha affinities provided they are a NodeAffinity for Preferred.
 		if aff := c.typha.Spec.Template.Spec.Affinity; aff != nil {
 			if aff.PodAffinity != nil || aff.PodAntiAffinity != nil {
-				return ErrIncompatibleCluster{
+				if err := c.incompatible(ErrIncompatibleCluster{
 					err:       "pod affinity and antiAffinity not supported for typha deployment",
 					component: ComponentTypha,
 					fix:       "remove the affinity",
+				}); err != nil {
+					return err
 				}
 			}
 			if aff.NodeAffinity != nil {
 				if aff.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
-					return ErrIncompatibleCluster{
+					if err := c.incompatible(ErrIncompatibleCluster{
 						err:       "nodeAffinity 'RequiredDuringSchedulingIgnoredDuringExecution' not supported on Typha.",
 						component: ComponentTypha,
 						fix:       "remove the affinity",
+					}); err != nil {
+						return err
 					}
 				}
 				install.Spec.TyphaAffinity = &operatorv1.TyphaAffinity{
@@ -342,10 +708,12 @@ func handleNodeSelectors(c *components, install *operatorv1.Installation) error
 			}
 		}
 		if nodeSel := removeOSNodeSelectors(c.typha.Spec.Template.Spec.NodeSelector); len(nodeSel) != 0 {
-			return ErrIncompatibleCluster{
+			if err := c.incompatible(ErrIncompatibleCluster{
 				err:       fmt.Sprintf("invalid nodeSelector for typha deployment: %v", nodeSel),
 				component: ComponentTypha,
 				fix:       "remove the nodeSelector",
+			}); err != nil {
+				return err
 			}
 		}
 	}
@@ -353,10 +721,12 @@ func handleNodeSelectors(c *components, install *operatorv1.Installation) error
 	// check kube-controllers nodeSelectors
 	if c.kubeControllers != nil {
 		if c.kubeControllers.Spec.Template.Spec.Affinity != nil {
-			return ErrIncompatibleCluster{
+			if err := c.incompatible(ErrIncompatibleCluster{
 				err:       "node affinity not supported for kube-controller deployment",
 				component: ComponentKubeControllers,
 				fix:       "remove the affinity",
+			}); err != nil {
+				return err
 			}
 		}
 
@@ -366,11 +736,103 @@ func handleNodeSelectors(c *components, install *operatorv1.Installation) error
 		if sels := removeOSNodeSelectors(c.kubeControllers.Spec.Template.Spec.NodeSelector); len(sels) != 0 {
 			install.Spec.ControlPlaneNodeSelector = sels
 		}
+
+		// similarly, the operator always adds the master and CriticalAddonsOnly tolerations to kube-controllers,
+		// so only carry forward any others.
+		if tols := removeDefaultControlPlaneTolerations(c.kubeControllers.Spec.Template.Spec.Tolerations); len(tols) != 0 {
+			install.Spec.ControlPlaneTolerations = tols
+		}
 	}
 
 	return nil
 }
 
+// handleUpgradeIPAM is a migration handler which notes the upgrade-ipam init container. It is left behind
+// by a past upgrade of the cluster's datastore and IPAM, so it must have completed against the kubernetes
+// datastore for the cluster to be adopted by the operator.
+func handleUpgradeIPAM(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	if !isInitContainer(c.node.Spec.Template.Spec, "upgrade-ipam") {
+		return nil
+	}
+	c.note(ComponentCalicoNode, "the upgrade-ipam init container is present, which indicates the cluster was upgraded "+
+		"from a previous datastore or IPAM. The operator does not render it")
+
+	dsType, err := c.node.getEnv(ctx, c.client, "upgrade-ipam", "DATASTORE_TYPE")
+	if err != nil {
+		return err
+	}
+	if dsType != nil && *dsType != "kubernetes" {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("the upgrade-ipam init container uses DATASTORE_TYPE=%s, only DATASTORE_TYPE=kubernetes is supported", *dsType),
+			component: ComponentCalicoNode,
+			fix:       "complete the migration to the kubernetes datastore, then remove the upgrade-ipam init container",
+		})
+	}
+	return nil
+}
+
+// auxiliaryInitContainers are the calico-node init containers other than install-cni from the manifests.
+// The operator renders flexvol-driver itself, and upgrade-ipam is only needed to upgrade from host-local IPAM,
+// so the env of either is not carried forward.
+var auxiliaryInitContainers = []string{"upgrade-ipam", "flexvol-driver"}
+
+// handleAuxiliaryInitContainers is a migration handler which marks the env of the auxiliary init containers
+// as checked, so that it doesn't trip the unchecked env var check. Any env which matters is checked by the
+// handler responsible for it, e.g. KUBERNETES_NODE_NAME on upgrade-ipam.
+func handleAuxiliaryInitContainers(_ context.Context, c *components, _ *operatorv1.Installation) error {
+	spec := c.node.Spec.Template.Spec
+	for _, name := range auxiliaryInitContainers {
+		if !isInitContainer(spec, name) {
+			continue
+		}
+		for _, e := range getContainer(spec, name).Env {
+			c.node.ignoreEnv(name, e.Name)
+		}
+	}
+	return nil
+}
+
+// handleSchedulingConstraints is a migration handler which warns about topologySpreadConstraints and
+// readinessGates on the components. The operator manages the scheduling of the components itself, so
+// these are not carried forward.
+func handleSchedulingConstraints(_ context.Context, c *components, _ *operatorv1.Installation) error {
+	type componentSpec struct {
+		component string
+		spec      corev1.PodSpec
+	}
+	specs := []componentSpec{{ComponentCalicoNode, c.node.Spec.Template.Spec}}
+	if c.typha != nil {
+		specs = append(specs, componentSpec{ComponentTypha, c.typha.Spec.Template.Spec})
+	}
+	if c.kubeControllers != nil {
+		specs = append(specs, componentSpec{ComponentKubeControllers, c.kubeControllers.Spec.Template.Spec})
+	}
+
+	for _, s := range specs {
+		for _, tsc := range s.spec.TopologySpreadConstraints {
+			c.warn(s.component, "topologySpreadConstraint on %s will not be carried forward as the operator manages scheduling", tsc.TopologyKey)
+		}
+		for _, gate := range s.spec.ReadinessGates {
+			c.warn(s.component, "readinessGate %s will not be carried forward as the operator manages scheduling", gate.ConditionType)
+		}
+	}
+	return nil
+}
+
+// removeDefaultControlPlaneTolerations returns the given tolerations with the tolerations the operator
+// sets on kube-controllers removed.
+func removeDefaultControlPlaneTolerations(existing []corev1.Toleration) []corev1.Toleration {
+	var tols []corev1.Toleration
+	for _, t := range existing {
+		if t.MatchToleration(&rmeta.TolerateMaster) || t.MatchToleration(&rmeta.TolerateCriticalAddonsOnly) {
+			continue
+		}
+		tols = append(tols, t)
+	}
+
+	return tols
+}
+
 // removeOSNodeSelectors returns the given nodeSelectors with [beta.]kubernetes.io/os=linux nodeSelectors removed.
 func removeOSNodeSelectors(existing map[string]string) map[string]string {
 	var nodeSel = map[string]string{}
@@ -386,7 +848,7 @@ func removeOSNodeSelectors(existing map[string]string) map[string]string {
 
 // handleFelixNodeMetrics is a migration handler which detects custom prometheus settings for felix and
 // caries those options forward via the NodeMetricsPort field.
-func handleFelixNodeMetrics(c *components, install *operatorv1.Installation) error {
+func handleFelixNodeMetrics(ctx context.Context, c *components, install *operatorv1.Installation) error {
 	metricsEnabled, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "FELIX_PROMETHEUSMETRICSENABLED")
 	if err != nil {
 		return err
@@ -399,16 +861,19 @@ func handleFelixNodeMetrics(c *components, install *operatorv1.Installation) err
 			return err
 		}
 		if port != nil {
-			p, err := strconv.ParseInt(*port, 10, 32)
-			if err != nil || p <= 0 || p > 65535 {
-				return ErrIncompatibleCluster{
+			// an invalid port is left at the default when the migration is lenient.
+			if p, err := strconv.ParseInt(*port, 10, 32); err != nil || p <= 0 || p > 65535 {
+				if err := c.incompatible(ErrIncompatibleCluster{
 					err:       fmt.Sprintf("invalid port defined in FELIX_PROMETHEUSMETRICSPORT=%s", *port),
 					component: ComponentCalicoNode,
 					fix:       "adjust it to be within the range of 1-65535 or remove the env var",
+				}); err != nil {
+					return err
 				}
+			} else {
+				i := int32(p)
+				install.Spec.NodeMetricsPort = &i
 			}
-			i := int32(p)
-			install.Spec.NodeMetricsPort = &i
 		}
 	} else {
 		// Ignore the metrics port if metrics is disabled.
@@ -417,3 +882,104 @@ func handleFelixNodeMetrics(c *components, install *operatorv1.Installation) err
 
 	return nil
 }
+
+// handleNodeServiceAccount is a migration handler which records the serviceAccount used by calico-node.
+// The operator always runs calico-node as its own calico-node serviceAccount, so any extra RBAC
+// bound to a differently named serviceAccount will no longer apply after migration.
+func handleNodeServiceAccount(_ context.Context, c *components, _ *operatorv1.Installation) error {
+	sa := c.node.Spec.Template.Spec.ServiceAccountName
+	if sa != "" && sa != "calico-node" {
+		c.warn(ComponentCalicoNode, "calico-node uses serviceAccount '%s' but will use 'calico-node' once managed by the operator, "+
+			"any RBAC bound to '%s' which is not also granted by the operator will be lost", sa, sa)
+	}
+	return nil
+}
+
+// handleNodeSecurityContext is a migration handler which records where the securityContext of the
+// calico-node container diverges from the operator's, which always runs calico-node privileged
+// and does not set any other securityContext fields.
+func handleNodeSecurityContext(_ context.Context, c *components, _ *operatorv1.Installation) error {
+	node := getContainer(c.node.Spec.Template.Spec, containerCalicoNode)
+	if node == nil {
+		return nil
+	}
+	sc := node.SecurityContext
+
+	if sc == nil || sc.Privileged == nil || !*sc.Privileged {
+		c.warn(ComponentCalicoNode, "calico-node container is not privileged, but it will be once managed by the operator")
+	}
+	if sc == nil {
+		return nil
+	}
+
+	if sc.Capabilities != nil {
+		if len(sc.Capabilities.Add) != 0 {
+			c.warn(ComponentCalicoNode, "added capabilities %v on calico-node container will not be carried forward", sc.Capabilities.Add)
+		}
+		if len(sc.Capabilities.Drop) != 0 {
+			c.warn(ComponentCalicoNode, "dropped capabilities %v on calico-node container will not be carried forward", sc.Capabilities.Drop)
+		}
+	}
+
+	var ignored []string
+	if sc.RunAsUser != nil {
+		ignored = append(ignored, "runAsUser")
+	}
+	if sc.RunAsGroup != nil {
+		ignored = append(ignored, "runAsGroup")
+	}
+	if sc.RunAsNonRoot != nil {
+		ignored = append(ignored, "runAsNonRoot")
+	}
+	if sc.ReadOnlyRootFilesystem != nil {
+		ignored = append(ignored, "readOnlyRootFilesystem")
+	}
+	if sc.AllowPrivilegeEscalation != nil {
+		ignored = append(ignored, "allowPrivilegeEscalation")
+	}
+	if sc.SELinuxOptions != nil {
+		ignored = append(ignored, "seLinuxOptions")
+	}
+	if sc.SeccompProfile != nil {
+		ignored = append(ignored, "seccompProfile")
+	}
+	if sc.ProcMount != nil {
+		ignored = append(ignored, "procMount")
+	}
+	if len(ignored) != 0 {
+		c.warn(ComponentCalicoNode, "securityContext fields %v on calico-node container will not be carried forward", ignored)
+	}
+
+	return nil
+}
+
+// handleNodePriorityClass is a migration handler which records a custom priorityClassName on calico-node.
+// The operator always schedules calico-node with its own calico-priority class, so any other class
+// is not preserved. The built-in critical classes used by the Calico manifests are not worth a warning.
+func handleNodePriorityClass(_ context.Context, c *components, _ *operatorv1.Installation) error {
+	switch pc := c.node.Spec.Template.Spec.PriorityClassName; pc {
+	case "", "system-node-critical", "system-cluster-critical", render.PriorityClassName:
+	default:
+		c.warn(ComponentCalicoNode, "calico-node uses priorityClass '%s' but will use '%s' once managed by the operator, "+
+			"so its scheduling priority will not be preserved", pc, render.PriorityClassName)
+	}
+	return nil
+}
+
+// handleNodeDNS is a migration handler which records custom DNS settings on calico-node. The operator
+// does not set a dnsPolicy or dnsConfig on calico-node, so a policy which isn't one of the cluster DNS
+// policies the manifests and the API server default to, or any dnsConfig, is not preserved.
+func handleNodeDNS(_ context.Context, c *components, _ *operatorv1.Installation) error {
+	spec := c.node.Spec.Template.Spec
+	switch spec.DNSPolicy {
+	case "", corev1.DNSClusterFirst, corev1.DNSClusterFirstWithHostNet:
+	default:
+		c.warn(ComponentCalicoNode, "calico-node uses dnsPolicy '%s' which will not be carried forward, "+
+			"so it will resolve names with the cluster's default DNS settings once managed by the operator", spec.DNSPolicy)
+	}
+	if spec.DNSConfig != nil {
+		c.warn(ComponentCalicoNode, "calico-node sets a dnsConfig which will not be carried forward, "+
+			"so its custom nameservers, searches and options will be lost")
+	}
+	return nil
+}
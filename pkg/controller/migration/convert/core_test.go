@@ -1,6 +1,8 @@
 package convert
 
 import (
+	"strings"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -25,7 +27,7 @@ var _ = Describe("core handler", func() {
 	})
 	Context("resource migration", func() {
 		It("should not migrate resource requirements if none are set", func() {
-			err := handleCore(&comps, i)
+			err := handleCore(ctx, &comps, i)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(i.Spec.ComponentResources).To(BeEmpty())
 		})
@@ -43,7 +45,7 @@ var _ = Describe("core handler", func() {
 
 		It("should migrate resources from calico-node if they are set", func() {
 			comps.node.Spec.Template.Spec.Containers[0].Resources = rqs
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.ComponentResources).To(ConsistOf(operatorv1.ComponentResource{
 				ComponentName:        operatorv1.ComponentNameNode,
 				ResourceRequirements: &rqs,
@@ -52,7 +54,7 @@ var _ = Describe("core handler", func() {
 
 		It("should migrate resources from kube-controllers if they are set", func() {
 			comps.kubeControllers.Spec.Template.Spec.Containers[0].Resources = rqs
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.ComponentResources).To(ConsistOf(operatorv1.ComponentResource{
 				ComponentName:        operatorv1.ComponentNameKubeControllers,
 				ResourceRequirements: &rqs,
@@ -61,7 +63,7 @@ var _ = Describe("core handler", func() {
 
 		It("should migrate resources from typha if they are set", func() {
 			comps.typha.Spec.Template.Spec.Containers[0].Resources = rqs
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.ComponentResources).To(ConsistOf(operatorv1.ComponentResource{
 				ComponentName:        operatorv1.ComponentNameTypha,
 				ResourceRequirements: &rqs,
@@ -90,7 +92,7 @@ var _ = Describe("core handler", func() {
 				ResourceRequirements: rqs.DeepCopy(),
 			})
 			comps.typha.Spec.Template.Spec.Containers[0].Resources = *rqs.DeepCopy()
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.ComponentResources).To(ConsistOf(expectedCompRsrc))
 		})
 
@@ -100,7 +102,7 @@ var _ = Describe("core handler", func() {
 				ComponentName:        operatorv1.ComponentNameNode,
 				ResourceRequirements: &rqs,
 			})
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.ComponentResources).To(HaveLen(1))
 		})
 	})
@@ -109,30 +111,30 @@ var _ = Describe("core handler", func() {
 		TestNodeSelectors := func(f func(map[string]string)) {
 			It("should error for unexpected nodeSelectors", func() {
 				f(map[string]string{"foo": "bar"})
-				Expect(handleNodeSelectors(&comps, i)).To(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).To(HaveOccurred())
 			})
 			It("should not error for beta.kubernetes.io/os=linux nodeSelector", func() {
 				f(map[string]string{"beta.kubernetes.io/os": "linux"})
-				Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 			It("should not error for kubernetes.io/os=linux", func() {
 				f(map[string]string{"kubernetes.io/os": "linux"})
-				Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 			It("should error for other kubernetes.io/os nodeSelectors", func() {
 				f(map[string]string{"kubernetes.io/os": "windows"})
-				Expect(handleNodeSelectors(&comps, i)).To(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).To(HaveOccurred())
 			})
 			It("should still error even if a valid and invalid nodeselector are set", func() {
 				f(map[string]string{
 					"kubernetes.io/os": "linux",
 					"foo":              "bar",
 				})
-				Expect(handleNodeSelectors(&comps, i)).To(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).To(HaveOccurred())
 			})
 			It("should not panic for nil nodeselectors", func() {
 				f(nil)
-				Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 		}
 		Describe("calico-node", func() {
@@ -144,18 +146,18 @@ var _ = Describe("core handler", func() {
 				comps.node.Spec.Template.Spec.NodeSelector = map[string]string{
 					"projectcalico.org/operator-node-migration": "pre-operator",
 				}
-				Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 			It("should error if a nodeSelector is set alongside the migration nodeSelector", func() {
 				comps.node.Spec.Template.Spec.NodeSelector = map[string]string{
 					"foo": "bar",
 					"projectcalico.org/operator-node-migration": "pre-operator",
 				}
-				Expect(handleNodeSelectors(&comps, i)).To(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).To(HaveOccurred())
 			})
 			It("should error for unexpected affinities", func() {
 				comps.node.Spec.Template.Spec.Affinity = &v1.Affinity{}
-				Expect(handleNodeSelectors(&comps, i)).To(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).To(HaveOccurred())
 			})
 			It("shouldn't error for aks affinity on aks", func() {
 				comps.node.Spec.Template.Spec.Affinity = &v1.Affinity{
@@ -172,7 +174,7 @@ var _ = Describe("core handler", func() {
 					},
 				}
 				i.Spec.KubernetesProvider = operatorv1.ProviderAKS
-				Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 			It("should error for other affinities on aks", func() {
 				comps.node.Spec.Template.Spec.Affinity = &v1.Affinity{
@@ -188,7 +190,7 @@ var _ = Describe("core handler", func() {
 					},
 				}
 				i.Spec.KubernetesProvider = operatorv1.ProviderAKS
-				Expect(handleNodeSelectors(&comps, i)).To(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).To(HaveOccurred())
 			})
 		})
 		Describe("typha", func() {
@@ -198,7 +200,7 @@ var _ = Describe("core handler", func() {
 
 			Context("affinities", func() {
 				It("should not error if no affinity is set", func() {
-					Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+					Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 				})
 				It("should migrate a Preferred nodeAffinity", func() {
 					terms := []v1.PreferredSchedulingTerm{{
@@ -216,7 +218,7 @@ var _ = Describe("core handler", func() {
 							PreferredDuringSchedulingIgnoredDuringExecution: terms,
 						},
 					}
-					Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+					Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 					Expect(i.Spec.TyphaAffinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution).To(Equal(terms))
 				})
 				It("should error for a Required nodeAffinity", func() {
@@ -231,7 +233,7 @@ var _ = Describe("core handler", func() {
 							},
 						},
 					}
-					Expect(handleNodeSelectors(&comps, i)).To(HaveOccurred())
+					Expect(handleNodeSelectors(ctx, &comps, i)).To(HaveOccurred())
 				})
 				It("should error if podAffinity is set", func() {
 					comps.typha.Spec.Template.Spec.Affinity = &v1.Affinity{
@@ -241,7 +243,7 @@ var _ = Describe("core handler", func() {
 							}},
 						},
 					}
-					Expect(handleNodeSelectors(&comps, i)).To(HaveOccurred())
+					Expect(handleNodeSelectors(ctx, &comps, i)).To(HaveOccurred())
 				})
 				It("should error if podAntiAffinity is set", func() {
 					comps.typha.Spec.Template.Spec.Affinity = &v1.Affinity{
@@ -251,7 +253,7 @@ var _ = Describe("core handler", func() {
 							}},
 						},
 					}
-					Expect(handleNodeSelectors(&comps, i)).To(HaveOccurred())
+					Expect(handleNodeSelectors(ctx, &comps, i)).To(HaveOccurred())
 				})
 			})
 		})
@@ -264,7 +266,7 @@ var _ = Describe("core handler", func() {
 					"kubernetes.io/os": "linux",
 					"foo":              "bar",
 				}
-				Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 				Expect(i.Spec.ControlPlaneNodeSelector).To(Equal(map[string]string{"foo": "bar"}))
 			})
 
@@ -273,19 +275,57 @@ var _ = Describe("core handler", func() {
 					"kubernetes.io/os": "windows",
 				}
 				// we don't expect an error to occur here, because the final validation handler should catch this.
-				Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 				Expect(i.Spec.ControlPlaneNodeSelector).To(Equal(map[string]string{"kubernetes.io/os": "windows"}))
 			})
 			It("should not set nodeSelector if none is set", func() {
-				Expect(handleNodeSelectors(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
 				Expect(i.Spec.ControlPlaneNodeSelector).To(BeNil())
 			})
+			It("should carry forward custom tolerations on kube-controllers, but drop the default tolerations", func() {
+				custom := v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "calico", Effect: v1.TaintEffectNoSchedule}
+				comps.kubeControllers.Spec.Template.Spec.Tolerations = append(comps.kubeControllers.Spec.Template.Spec.Tolerations, custom)
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(i.Spec.ControlPlaneTolerations).To(ConsistOf(custom))
+			})
+			It("should not set tolerations if only the default tolerations are set", func() {
+				Expect(comps.kubeControllers.Spec.Template.Spec.Tolerations).ToNot(BeEmpty())
+				Expect(handleNodeSelectors(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(i.Spec.ControlPlaneTolerations).To(BeNil())
+			})
+		})
+	})
+
+	Context("scheduling constraints", func() {
+		It("should not warn if there are none", func() {
+			Expect(handleSchedulingConstraints(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+		It("should warn about a topologySpreadConstraint on calico-node", func() {
+			comps.node.Spec.Template.Spec.TopologySpreadConstraints = []v1.TopologySpreadConstraint{{
+				MaxSkew:           1,
+				TopologyKey:       "topology.kubernetes.io/zone",
+				WhenUnsatisfiable: v1.DoNotSchedule,
+			}}
+			Expect(handleSchedulingConstraints(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message:   "topologySpreadConstraint on topology.kubernetes.io/zone will not be carried forward as the operator manages scheduling",
+			}))
+		})
+		It("should warn about a readinessGate on typha", func() {
+			comps.typha.Spec.Template.Spec.ReadinessGates = []v1.PodReadinessGate{{ConditionType: "example.com/ready"}}
+			Expect(handleSchedulingConstraints(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentTypha,
+				Message:   "readinessGate example.com/ready will not be carried forward as the operator manages scheduling",
+			}))
 		})
 	})
 
 	Context("node update strategy", func() {
 		It("should not set updateStrategy if none is set", func() {
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.NodeUpdateStrategy).To(Equal(appsv1.DaemonSetUpdateStrategy{}))
 		})
 		It("should carry forward updateStrategy", func() {
@@ -297,14 +337,318 @@ var _ = Describe("core handler", func() {
 				},
 			}
 			comps.node.Spec.UpdateStrategy = updateStrategy
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.NodeUpdateStrategy).To(Equal(updateStrategy))
+		})
+		It("should warn about an OnDelete updateStrategy", func() {
+			comps.node.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.OnDeleteDaemonSetStrategyType,
+			}
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.NodeUpdateStrategy.Type).To(Equal(appsv1.OnDeleteDaemonSetStrategyType))
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("OnDelete"))
+		})
+		It("should not record a manual step for an OnDelete updateStrategy in lenient mode", func() {
+			comps.mode = ModeLenient
+			comps.node.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.OnDeleteDaemonSetStrategyType,
+			}
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.NodeUpdateStrategy.Type).To(Equal(appsv1.OnDeleteDaemonSetStrategyType))
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.ManualSteps).To(BeEmpty())
+		})
+		It("should not warn about a RollingUpdate updateStrategy", func() {
+			comps.node.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+			}
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+		It("should carry forward a custom maxUnavailable", func() {
+			tenPercent := intstr.FromString("10%")
+			updateStrategy := appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+					MaxUnavailable: &tenPercent,
+				},
+			}
+			comps.node.Spec.UpdateStrategy = updateStrategy
+			detected, err := getNodeUpdateStrategy(&comps)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(detected).To(Equal(updateStrategy))
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.NodeUpdateStrategy).To(Equal(updateStrategy))
 		})
+		It("should leave maxUnavailable unset for the defaults to fill in", func() {
+			comps.node.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+			}
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.NodeUpdateStrategy.RollingUpdate).To(BeNil())
+		})
+		It("should error on an invalid maxUnavailable", func() {
+			invalid := intstr.FromString("ten")
+			comps.node.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+					MaxUnavailable: &invalid,
+				},
+			}
+			Expect(handleCore(ctx, &comps, i)).To(HaveOccurred())
+		})
+		It("should error on a zero maxUnavailable", func() {
+			zero := intstr.FromInt(0)
+			comps.node.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+					MaxUnavailable: &zero,
+				},
+			}
+			Expect(handleCore(ctx, &comps, i)).To(HaveOccurred())
+		})
+		It("should fall back to the default maxUnavailable in lenient mode", func() {
+			comps.mode = ModeLenient
+			zero := intstr.FromInt(0)
+			comps.node.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+					MaxUnavailable: &zero,
+				},
+			}
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.NodeUpdateStrategy.RollingUpdate.MaxUnavailable).To(BeNil())
+			Expect(comps.report.ManualSteps).To(HaveLen(1))
+			Expect(comps.report.ManualSteps[0].Issue).To(ContainSubstring("invalid maxUnavailable '0'"))
+		})
+	})
+
+	Context("serviceAccount", func() {
+		It("should not warn for the calico-node serviceAccount", func() {
+			comps.node.Spec.Template.Spec.ServiceAccountName = "calico-node"
+			Expect(handleNodeServiceAccount(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+		It("should warn for a custom serviceAccount", func() {
+			comps.node.Spec.Template.Spec.ServiceAccountName = "my-calico"
+			Expect(handleNodeServiceAccount(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Component).To(Equal(ComponentCalicoNode))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("any RBAC bound to 'my-calico'"))
+		})
+	})
+
+	Context("priorityClassName", func() {
+		It("should not warn for the priorityClass used by the Calico manifests", func() {
+			comps.node.Spec.Template.Spec.PriorityClassName = "system-node-critical"
+			Expect(handleNodePriorityClass(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+		It("should warn for a custom priorityClass", func() {
+			comps.node.Spec.Template.Spec.PriorityClassName = "calico-node-critical"
+			Expect(handleNodePriorityClass(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message: "calico-node uses priorityClass 'calico-node-critical' but will use 'calico-priority' once managed by the operator, " +
+					"so its scheduling priority will not be preserved",
+			}))
+		})
+	})
+
+	Context("dns", func() {
+		for _, policy := range []v1.DNSPolicy{"", v1.DNSClusterFirst, v1.DNSClusterFirstWithHostNet} {
+			policy := policy
+			It("should not warn for dnsPolicy '"+string(policy)+"'", func() {
+				comps.node.Spec.Template.Spec.DNSPolicy = policy
+				Expect(handleNodeDNS(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(comps.report.Warnings).To(BeEmpty())
+			})
+		}
+		It("should warn for a custom dnsPolicy", func() {
+			comps.node.Spec.Template.Spec.DNSPolicy = v1.DNSDefault
+			Expect(handleNodeDNS(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message: "calico-node uses dnsPolicy 'Default' which will not be carried forward, " +
+					"so it will resolve names with the cluster's default DNS settings once managed by the operator",
+			}))
+		})
+		It("should warn for a custom dnsConfig", func() {
+			ndots := "2"
+			comps.node.Spec.Template.Spec.DNSPolicy = v1.DNSNone
+			comps.node.Spec.Template.Spec.DNSConfig = &v1.PodDNSConfig{
+				Nameservers: []string{"10.0.0.10"},
+				Searches:    []string{"corp.example.com"},
+				Options:     []v1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}},
+			}
+			Expect(handleNodeDNS(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(
+				Warning{
+					Component: ComponentCalicoNode,
+					Message: "calico-node uses dnsPolicy 'None' which will not be carried forward, " +
+						"so it will resolve names with the cluster's default DNS settings once managed by the operator",
+				},
+				Warning{
+					Component: ComponentCalicoNode,
+					Message: "calico-node sets a dnsConfig which will not be carried forward, " +
+						"so its custom nameservers, searches and options will be lost",
+				},
+			))
+		})
+	})
+
+	Context("securityContext", func() {
+		It("should not warn for a privileged calico-node", func() {
+			Expect(handleNodeSecurityContext(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+		It("should warn if calico-node is not privileged", func() {
+			comps.node.Spec.Template.Spec.Containers[0].SecurityContext = nil
+			Expect(handleNodeSecurityContext(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("not privileged"))
+		})
+		It("should warn about modified capabilities and other fields", func() {
+			f := false
+			comps.node.Spec.Template.Spec.Containers[0].SecurityContext = &v1.SecurityContext{
+				Privileged: &f,
+				Capabilities: &v1.Capabilities{
+					Add:  []v1.Capability{"NET_ADMIN", "SYS_ADMIN"},
+					Drop: []v1.Capability{"ALL"},
+				},
+				ReadOnlyRootFilesystem: &f,
+			}
+			Expect(handleNodeSecurityContext(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(
+				Warning{Component: ComponentCalicoNode, Message: "calico-node container is not privileged, but it will be once managed by the operator"},
+				Warning{Component: ComponentCalicoNode, Message: "added capabilities [NET_ADMIN SYS_ADMIN] on calico-node container will not be carried forward"},
+				Warning{Component: ComponentCalicoNode, Message: "dropped capabilities [ALL] on calico-node container will not be carried forward"},
+				Warning{Component: ComponentCalicoNode, Message: "securityContext fields [readOnlyRootFilesystem] on calico-node container will not be carried forward"},
+			))
+		})
+	})
+
+	Context("volumes", func() {
+		It("should not error for the expected volumes", func() {
+			Expect(handleNodeVolumes(ctx, &comps, i)).ToNot(HaveOccurred())
+		})
+		It("should error for an extra hostPath volume", func() {
+			comps.node.Spec.Template.Spec.Volumes = append(comps.node.Spec.Template.Spec.Volumes, v1.Volume{
+				Name: "bird-templates",
+				VolumeSource: v1.VolumeSource{
+					HostPath: &v1.HostPathVolumeSource{Path: "/etc/calico/templates"},
+				},
+			})
+			comps.node.Spec.Template.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{
+				{Name: "var-run-calico", MountPath: "/var/run/calico"},
+				{Name: "bird-templates", MountPath: "/etc/calico/confd/templates"},
+			}
+			err := handleNodeVolumes(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unexpected volumes [bird-templates] mounted at [calico-node:/etc/calico/confd/templates]"))
+		})
+		It("should record a manual step for an extra volume in lenient mode", func() {
+			comps.mode = ModeLenient
+			comps.node.Spec.Template.Spec.Volumes = append(comps.node.Spec.Template.Spec.Volumes, v1.Volume{
+				Name: "bird-templates",
+				VolumeSource: v1.VolumeSource{
+					HostPath: &v1.HostPathVolumeSource{Path: "/etc/calico/templates"},
+				},
+			})
+			Expect(handleNodeVolumes(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.ManualSteps).To(HaveLen(1))
+			Expect(comps.report.ManualSteps[0].Issue).To(ContainSubstring("unexpected volumes [bird-templates]"))
+		})
+	})
+
+	Context("cni directories", func() {
+		setCNIDirs := func(bin, net string) {
+			for idx, v := range comps.node.Spec.Template.Spec.Volumes {
+				switch v.Name {
+				case "cni-bin-dir":
+					comps.node.Spec.Template.Spec.Volumes[idx].HostPath.Path = bin
+				case "cni-net-dir":
+					comps.node.Spec.Template.Spec.Volumes[idx].HostPath.Path = net
+				}
+			}
+		}
+
+		It("should not error for the default directories", func() {
+			Expect(checkCNIDirectories(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+
+		It("should error for k3s containerd directories", func() {
+			setCNIDirs("/var/lib/rancher/k3s/data/current/bin", "/var/lib/rancher/k3s/agent/etc/cni/net.d")
+			err := checkCNIDirectories(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("follow k3s containerd conventions"))
+		})
+
+		It("should only record a manual step for k3s containerd directories when lenient", func() {
+			comps.mode = ModeLenient
+			setCNIDirs("/var/lib/rancher/k3s/data/current/bin", "/var/lib/rancher/k3s/agent/etc/cni/net.d")
+			Expect(checkCNIDirectories(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.ManualSteps).To(HaveLen(1))
+			Expect(comps.report.ManualSteps[0].Issue).To(ContainSubstring("follow k3s containerd conventions"))
+		})
+
+		It("should only record a manual step for a nonstandard combination of directories when lenient", func() {
+			comps.mode = ModeLenient
+			setCNIDirs("/opt/cni/bin", "/var/lib/rancher/k3s/agent/etc/cni/net.d")
+			Expect(checkCNIDirectories(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.ManualSteps).To(HaveLen(1))
+			Expect(comps.report.ManualSteps[0].Issue).To(Equal("missing expected volume 'cni-net-dir' with hostPath '/etc/cni/net.d'"))
+		})
+
+		It("should accept GKE containerd directories on GKE", func() {
+			comps.provider = operatorv1.ProviderGKE
+			setCNIDirs("/home/kubernetes/bin", "/etc/cni/net.d")
+			Expect(checkCNIDirectories(ctx, &comps, i)).ToNot(HaveOccurred())
+		})
+
+		It("should error for GKE containerd directories on other providers", func() {
+			setCNIDirs("/home/kubernetes/bin", "/etc/cni/net.d")
+			err := checkCNIDirectories(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("follow GKE containerd conventions"))
+		})
+
+		It("should error for a nonstandard combination of directories", func() {
+			setCNIDirs("/opt/cni/bin", "/var/lib/rancher/k3s/agent/etc/cni/net.d")
+			err := checkCNIDirectories(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("missing expected volume 'cni-net-dir' with hostPath '/etc/cni/net.d'"))
+		})
+
+		It("should compare against the OpenShift directories on OpenShift", func() {
+			comps.provider = operatorv1.ProviderOpenShift
+			setCNIDirs("/var/lib/cni/bin", "/var/run/multus/cni/net.d")
+			Expect(checkCNIDirectories(ctx, &comps, i)).ToNot(HaveOccurred())
+		})
+
+		It("should warn when CNI_NET_DIR does not match the cni-net-dir volume", func() {
+			comps.node.Spec.Template.Spec.InitContainers[0].Env = append(comps.node.Spec.Template.Spec.InitContainers[0].Env,
+				v1.EnvVar{Name: "CNI_NET_DIR", Value: "/etc/kubernetes/cni/net.d"})
+			Expect(checkCNIDirectories(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message:   "CNI_NET_DIR=/etc/kubernetes/cni/net.d does not match the cni-net-dir volume '/etc/cni/net.d', it will be set to '/etc/cni/net.d' after migration",
+			}))
+		})
+
+		It("should not warn when CNI_NET_DIR matches the cni-net-dir volume", func() {
+			comps.node.Spec.Template.Spec.InitContainers[0].Env = append(comps.node.Spec.Template.Spec.InitContainers[0].Env,
+				v1.EnvVar{Name: "CNI_NET_DIR", Value: "/etc/cni/net.d"})
+			Expect(checkCNIDirectories(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
 	})
 
 	Context("flexvol", func() {
 		It("should not be set by default", func() {
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.FlexVolumePath).To(Equal("None"))
 		})
 		It("should carry forward flexvolumepath", func() {
@@ -323,9 +667,167 @@ var _ = Describe("core handler", func() {
 				Name: "flexvol-driver",
 			})
 
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.FlexVolumePath).To(Equal(path))
 		})
+
+		Context("consistency", func() {
+			addFlexVol := func(path string, driver v1.Container) {
+				hostPathDirectoryOrCreate := v1.HostPathDirectoryOrCreate
+				comps.node.Spec.Template.Spec.Volumes = append(comps.node.Spec.Template.Spec.Volumes, v1.Volume{
+					Name: "flexvol-driver-host",
+					VolumeSource: v1.VolumeSource{
+						HostPath: &v1.HostPathVolumeSource{
+							Path: path,
+							Type: &hostPathDirectoryOrCreate,
+						},
+					},
+				})
+				comps.node.Spec.Template.Spec.InitContainers = append(comps.node.Spec.Template.Spec.InitContainers, driver)
+			}
+			driver := v1.Container{
+				Name:         "flexvol-driver",
+				Image:        "calico/pod2daemon-flexvol:v3.16.0",
+				VolumeMounts: []v1.VolumeMount{{Name: "flexvol-driver-host", MountPath: "/host/driver"}},
+			}
+			flexVolWarnings := func() []string {
+				var msgs []string
+				for _, w := range comps.report.Warnings {
+					if strings.Contains(w.Message, "flexvol") {
+						msgs = append(msgs, w.Message)
+					}
+				}
+				return msgs
+			}
+
+			It("should not warn for the default path", func() {
+				addFlexVol("/usr/libexec/kubernetes/kubelet-plugins/volume/exec/nodeagent~uds", driver)
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(i.Spec.FlexVolumePath).To(Equal("/usr/libexec/kubernetes/kubelet-plugins/volume/exec/"))
+				Expect(flexVolWarnings()).To(BeEmpty())
+			})
+
+			It("should not warn for the provider's default path", func() {
+				comps.provider = operatorv1.ProviderGKE
+				addFlexVol("/home/kubernetes/flexvolume/nodeagent~uds", driver)
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(i.Spec.FlexVolumePath).To(Equal("/home/kubernetes/flexvolume/"))
+				Expect(flexVolWarnings()).To(BeEmpty())
+			})
+
+			It("should warn for another provider's default path", func() {
+				comps.provider = operatorv1.ProviderOpenShift
+				addFlexVol("/home/kubernetes/flexvolume/nodeagent~uds", driver)
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(flexVolWarnings()).To(ConsistOf("the flexvolume path /home/kubernetes/flexvolume/ differs from the default " +
+					"/etc/kubernetes/kubelet-plugins/volume/exec/, check that the kubelet's --volume-plugin-dir is set to it"))
+			})
+
+			It("should warn for a nonstandard path", func() {
+				addFlexVol("/opt/volume-plugins/nodeagent~uds", driver)
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(i.Spec.FlexVolumePath).To(Equal("/opt/volume-plugins/"))
+				Expect(flexVolWarnings()).To(ConsistOf("the flexvolume path /opt/volume-plugins/ differs from the default " +
+					"/usr/libexec/kubernetes/kubelet-plugins/volume/exec/, check that the kubelet's --volume-plugin-dir is set to it"))
+			})
+
+			It("should warn if the volume path is not the driver directory", func() {
+				addFlexVol("/usr/libexec/kubernetes/kubelet-plugins/volume/exec/", driver)
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(flexVolWarnings()).To(ConsistOf("the 'flexvol-driver-host' volume path /usr/libexec/kubernetes/kubelet-plugins/volume/exec/ " +
+					"does not end in nodeagent~uds, the operator will mount /usr/libexec/kubernetes/kubelet-plugins/volume/exec/nodeagent~uds instead"))
+			})
+
+			It("should warn if the driver does not mount the volume", func() {
+				d := driver
+				d.VolumeMounts = nil
+				addFlexVol("/usr/libexec/kubernetes/kubelet-plugins/volume/exec/nodeagent~uds", d)
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(flexVolWarnings()).To(ConsistOf("the flexvol-driver init container does not mount the 'flexvol-driver-host' volume, " +
+					"the operator will install the driver into /usr/libexec/kubernetes/kubelet-plugins/volume/exec/nodeagent~uds"))
+			})
+
+			It("should warn on a custom driver image", func() {
+				d := driver
+				d.Image = "example.com/flexvol:latest"
+				addFlexVol("/usr/libexec/kubernetes/kubelet-plugins/volume/exec/nodeagent~uds", d)
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(flexVolWarnings()).To(ConsistOf("the flexvol-driver init container runs image example.com/flexvol:latest, " +
+					"the operator will replace it with its own pod2daemon-flexvol image"))
+			})
+		})
+	})
+
+	Context("upgrade-ipam", func() {
+		addUpgradeIPAM := func(env ...v1.EnvVar) {
+			comps.node.Spec.Template.Spec.InitContainers = append(comps.node.Spec.Template.Spec.InitContainers, v1.Container{
+				Name:  "upgrade-ipam",
+				Image: "calico/cni:v3.16.0",
+				Env:   env,
+			})
+		}
+
+		It("should not note anything without the init container", func() {
+			Expect(handleUpgradeIPAM(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Notes).To(BeEmpty())
+		})
+
+		It("should note the init container", func() {
+			addUpgradeIPAM(v1.EnvVar{Name: "DATASTORE_TYPE", Value: "kubernetes"})
+			Expect(handleUpgradeIPAM(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Notes).To(ConsistOf(Note{
+				Component: ComponentCalicoNode,
+				Message: "the upgrade-ipam init container is present, which indicates the cluster was upgraded " +
+					"from a previous datastore or IPAM. The operator does not render it",
+			}))
+			Expect(comps.report.Warnings).To(BeEmpty())
+			Expect(comps.node.uncheckedVars()).ToNot(ContainElement("upgrade-ipam/DATASTORE_TYPE"))
+		})
+
+		It("should error if the datastore is not kubernetes", func() {
+			addUpgradeIPAM(v1.EnvVar{Name: "DATASTORE_TYPE", Value: "etcdv3"})
+			err := handleUpgradeIPAM(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("the upgrade-ipam init container uses DATASTORE_TYPE=etcdv3"))
+		})
+
+		It("should warn if the datastore is not kubernetes in lenient mode", func() {
+			comps.mode = ModeLenient
+			addUpgradeIPAM(v1.EnvVar{Name: "DATASTORE_TYPE", Value: "etcdv3"})
+			Expect(handleUpgradeIPAM(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Notes).To(HaveLen(1))
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("only DATASTORE_TYPE=kubernetes is supported"))
+		})
+	})
+
+	Context("auxiliary init containers", func() {
+		It("should mark the env of upgrade-ipam and flexvol-driver as checked", func() {
+			comps.node.Spec.Template.Spec.InitContainers = append(comps.node.Spec.Template.Spec.InitContainers,
+				v1.Container{
+					Name: "upgrade-ipam",
+					Env: []v1.EnvVar{
+						{Name: "KUBERNETES_NODE_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
+						{Name: "CALICO_NETWORKING_BACKEND", Value: "bird"},
+					},
+				},
+				v1.Container{
+					Name: "flexvol-driver",
+					Env:  []v1.EnvVar{{Name: "FLEXVOL_DEBUG", Value: "true"}},
+				},
+			)
+			Expect(handleAuxiliaryInitContainers(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.node.uncheckedVars()).ToNot(ContainElement(HavePrefix("upgrade-ipam/")))
+			Expect(comps.node.uncheckedVars()).ToNot(ContainElement(HavePrefix("flexvol-driver/")))
+		})
+		It("should not mark the env of other init containers as checked", func() {
+			comps.node.Spec.Template.Spec.InitContainers = append(comps.node.Spec.Template.Spec.InitContainers, v1.Container{
+				Name: "custom-init",
+				Env:  []v1.EnvVar{{Name: "FOO", Value: "bar"}},
+			})
+			Expect(handleAuxiliaryInitContainers(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.node.uncheckedVars()).To(ContainElement("custom-init/FOO"))
+		})
 	})
 
 	Context("nodename", func() {
@@ -344,7 +846,7 @@ var _ = Describe("core handler", func() {
 						},
 					},
 				}})
-				Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 			It("should throw an error if set to a different fieldPath", func() {
 				setEnvVars([]v1.EnvVar{{
@@ -355,19 +857,19 @@ var _ = Describe("core handler", func() {
 						},
 					},
 				}})
-				Expect(handleCore(&comps, i)).To(HaveOccurred())
+				Expect(handleCore(ctx, &comps, i)).To(HaveOccurred())
 			})
 			It("should throw an error if hardcoded to a value", func() {
 				setEnvVars([]v1.EnvVar{{
 					Name:  nodeNameVarName,
 					Value: "foobar",
 				}})
-				Expect(handleCore(&comps, i)).To(HaveOccurred())
+				Expect(handleCore(ctx, &comps, i)).To(HaveOccurred())
 			})
 		}
 
 		It("should not throw an error if no nodenames are set", func() {
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 		})
 
 		It("should not throw an error if both are set correctly", func() {
@@ -387,7 +889,7 @@ var _ = Describe("core handler", func() {
 					},
 				},
 			}}
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 		})
 
 		Context("on the calico/node container", func() {
@@ -395,11 +897,64 @@ var _ = Describe("core handler", func() {
 				comps.node.Spec.Template.Spec.Containers[0].Env = envVars
 			})
 		})
+		Context("node ref on the calico/node container", func() {
+			AssertNodeName("CALICO_K8S_NODE_REF", func(envVars []v1.EnvVar) {
+				comps.node.Spec.Template.Spec.Containers[0].Env = envVars
+			})
+			It("should only record a manual step if hardcoded to a value when lenient", func() {
+				comps.mode = ModeLenient
+				comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "CALICO_K8S_NODE_REF", Value: "foobar"}}
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+				Expect(comps.report.ManualSteps).To(ContainElement(ManualStep{
+					Component: ComponentCalicoNode,
+					Issue:     "CALICO_K8S_NODE_REF on 'calico-node' container must be unset or be a FieldRef to 'spec.nodeName'",
+					Step:      "remove the CALICO_K8S_NODE_REF env var or convert it to a fieldRef with value 'spec.nodeName'",
+				}))
+			})
+		})
 		Context("on the install-cni container", func() {
 			AssertNodeName("KUBERNETES_NODE_NAME", func(envVars []v1.EnvVar) {
 				comps.node.Spec.Template.Spec.InitContainers[0].Env = envVars
 			})
 		})
+		Context("hostname on the calico/node container", func() {
+			AssertNodeName("HOSTNAME", func(envVars []v1.EnvVar) {
+				comps.node.Spec.Template.Spec.Containers[0].Env = envVars
+			})
+		})
+		Context("on the upgrade-ipam container", func() {
+			AssertNodeName("KUBERNETES_NODE_NAME", func(envVars []v1.EnvVar) {
+				comps.node.Spec.Template.Spec.InitContainers = append(comps.node.Spec.Template.Spec.InitContainers, v1.Container{
+					Name: "upgrade-ipam",
+					Env:  envVars,
+				})
+			})
+		})
+
+		It("should describe an overridden node name", func() {
+			comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "NODENAME",
+				Value: "node1.example.com",
+			}}
+			err := handleCore(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("NODENAME=node1.example.com on 'calico-node' container overrides the node name"))
+			Expect(err.Error()).To(ContainSubstring("the node's identity in Calico would change"))
+		})
+
+		It("should ignore HOSTNAME when NODENAME is a ref to the node name", func() {
+			comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{
+					Name: "NODENAME",
+					ValueFrom: &v1.EnvVarSource{
+						FieldRef: &v1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+					},
+				},
+				{Name: "HOSTNAME", Value: "node1.example.com"},
+			}
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.node.uncheckedVars()).ToNot(ContainElement("calico-node/HOSTNAME"))
+		})
 
 		Context("tolerations", func() {
 			// TestTolerations parameterizes the tests for tolerations to that they can be run
@@ -409,22 +964,22 @@ var _ = Describe("core handler", func() {
 			// the second parameter is a function which updates the tolerations of the desired component.
 			TestTolerations := func(existingTolerations []v1.Toleration, setTolerations func([]v1.Toleration)) {
 				It("should not error if only expected tolerations are set", func() {
-					Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+					Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 				})
 				It("should not error if no tolerations set", func() {
 					setTolerations([]v1.Toleration{})
-					Expect(handleCore(&comps, i)).NotTo(HaveOccurred())
+					Expect(handleCore(ctx, &comps, i)).NotTo(HaveOccurred())
 				})
 				It("should not error if missing just one toleration", func() {
 					setTolerations(existingTolerations[0 : len(existingTolerations)-1])
-					Expect(handleCore(&comps, i)).NotTo(HaveOccurred())
+					Expect(handleCore(ctx, &comps, i)).NotTo(HaveOccurred())
 				})
 				It("should not error if additional toleration exists", func() {
 					setTolerations(append(existingTolerations, v1.Toleration{
 						Key:    "foo",
 						Effect: "bar",
 					}))
-					Expect(handleCore(&comps, i)).NotTo(HaveOccurred())
+					Expect(handleCore(ctx, &comps, i)).NotTo(HaveOccurred())
 				})
 			}
 			Describe("calico-node", func() {
@@ -448,19 +1003,19 @@ var _ = Describe("core handler", func() {
 	Context("annotations", func() {
 		ExpectAnnotations := func(updateAnnotations func(map[string]string)) {
 			It("should not error for no annotations", func() {
-				Expect(handleAnnotations(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleAnnotations(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 			It("should error for unexpected annotations", func() {
 				updateAnnotations(map[string]string{"foo": "bar"})
-				Expect(handleAnnotations(&comps, i)).To(HaveOccurred())
+				Expect(handleAnnotations(ctx, &comps, i)).To(HaveOccurred())
 			})
 			It("should not error for acceptable annotations", func() {
 				updateAnnotations(map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"})
-				Expect(handleAnnotations(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleAnnotations(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 			It("should not panic for nil annotations", func() {
 				updateAnnotations(nil)
-				Expect(handleAnnotations(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleAnnotations(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 		}
 		Context("calico-node", func() {
@@ -490,7 +1045,7 @@ var _ = Describe("core handler", func() {
 				comps.typha.Spec.Template.Annotations = map[string]string{
 					"cluster-autoscaler.kubernetes.io/safe-to-evict": "true",
 				}
-				Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 		})
 	})
@@ -501,14 +1056,87 @@ var _ = Describe("core handler", func() {
 				Name:  "CNI_CONF_NAME",
 				Value: "10-calico.conflist",
 			}}
-			Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+		It("should warn if CNI_CONF_NAME wouldn't sort first", func() {
+			comps.node.Spec.Template.Spec.InitContainers[0].Env = []v1.EnvVar{{
+				Name:  "CNI_CONF_NAME",
+				Value: "20-calico.conflist",
+			}}
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message: "CNI_CONF_NAME=20-calico.conflist does not sort first, so a lexically earlier CNI config may take precedence over calico. " +
+					"The operator writes 10-calico.conflist, which may change the CNI config in use after migration",
+			}))
 		})
-		It("should raise error if CNI_CONF_NAME isn't 10-calico.conflist", func() {
+		It("should warn if CNI_CONF_NAME sorts before 10-calico.conflist", func() {
 			comps.node.Spec.Template.Spec.InitContainers[0].Env = []v1.EnvVar{{
 				Name:  "CNI_CONF_NAME",
-				Value: "2-calico.conflist",
+				Value: "05-calico.conflist",
+			}}
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("the existing 05-calico.conflist will still take precedence"))
+		})
+	})
+	Context("CALICO_DISABLE_FILE_LOGGING", func() {
+		setFileLogging := func(value string) {
+			comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "CALICO_DISABLE_FILE_LOGGING",
+				Value: value,
+			}}
+		}
+		It("should not warn if unset", func() {
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+		It("should not warn if true", func() {
+			setFileLogging("true")
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+			Expect(comps.node.checkedVars[containerCalicoNode].envVars).To(HaveKey("CALICO_DISABLE_FILE_LOGGING"))
+		})
+		It("should warn if false", func() {
+			setFileLogging("false")
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message: "CALICO_DISABLE_FILE_LOGGING=false enables file logging, the operator disables it " +
+					"so calico-node logs will only be available from the container logs after migration",
+			}))
+		})
+		It("should error if not a boolean", func() {
+			setFileLogging("yes")
+			Expect(handleCore(ctx, &comps, i)).To(HaveOccurred())
+		})
+	})
+	Context("WAIT_FOR_DATASTORE", func() {
+		setWaitForDatastore := func(value string) {
+			comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "WAIT_FOR_DATASTORE",
+				Value: value,
 			}}
-			Expect(handleCore(&comps, i)).To(HaveOccurred())
+		}
+		It("should not warn if true", func() {
+			setWaitForDatastore("true")
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+			Expect(comps.node.checkedVars[containerCalicoNode].envVars).To(HaveKey("WAIT_FOR_DATASTORE"))
+		})
+		It("should warn if false", func() {
+			setWaitForDatastore("false")
+			Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message: "WAIT_FOR_DATASTORE=false, calico-node will wait for the datastore to be ready " +
+					"after migration so it may not start while the datastore is unavailable",
+			}))
+		})
+		It("should error if not a boolean", func() {
+			setWaitForDatastore("sometimes")
+			Expect(handleCore(ctx, &comps, i)).To(HaveOccurred())
 		})
 	})
 	Context("kube-controllers", func() {
@@ -518,14 +1146,14 @@ var _ = Describe("core handler", func() {
 					Name:  "ENABLED_CONTROLLERS",
 					Value: "node",
 				}}
-				Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 			It("should error if ENABLED_CONTROLLERS is not expected value", func() {
 				comps.kubeControllers.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
 					Name:  "ENABLED_CONTROLLERS",
 					Value: "hep",
 				}}
-				Expect(handleCore(&comps, i)).To(HaveOccurred())
+				Expect(handleCore(ctx, &comps, i)).To(HaveOccurred())
 			})
 		})
 		Context("AUTO_HOST_ENDPOINTS", func() {
@@ -534,14 +1162,14 @@ var _ = Describe("core handler", func() {
 					Name:  "AUTO_HOST_ENDPOINTS",
 					Value: "disabled",
 				}}
-				Expect(handleCore(&comps, i)).ToNot(HaveOccurred())
+				Expect(handleCore(ctx, &comps, i)).ToNot(HaveOccurred())
 			})
 			It("should error if AUTO_HOST_ENDPOINTS is not expected value", func() {
 				comps.kubeControllers.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
 					Name:  "AUTO_HOST_ENDPOINTS",
 					Value: "enabled",
 				}}
-				Expect(handleCore(&comps, i)).To(HaveOccurred())
+				Expect(handleCore(ctx, &comps, i)).To(HaveOccurred())
 			})
 		})
 	})
@@ -551,12 +1179,12 @@ var _ = Describe("core handler", func() {
 				Name:  "FELIX_PROMETHEUSMETRICSENABLED",
 				Value: "true",
 			}}
-			Expect(handleFelixNodeMetrics(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleFelixNodeMetrics(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(*i.Spec.NodeMetricsPort).To(Equal(int32(9091)))
 		})
 		It("defaults prometheus off when no prometheus environment variables set", func() {
 
-			Expect(handleFelixNodeMetrics(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleFelixNodeMetrics(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.NodeMetricsPort).To(BeNil())
 		})
 		It("with metrics enabled the default port is used", func() {
@@ -565,7 +1193,7 @@ var _ = Describe("core handler", func() {
 				Value: "true",
 			}}
 
-			Expect(handleFelixNodeMetrics(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleFelixNodeMetrics(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(*i.Spec.NodeMetricsPort).To(Equal(int32(9091)))
 		})
 		It("with metrics port env var only, metrics are still disabled", func() {
@@ -574,7 +1202,7 @@ var _ = Describe("core handler", func() {
 				Value: "5555",
 			}}
 
-			Expect(handleFelixNodeMetrics(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleFelixNodeMetrics(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.NodeMetricsPort).To(BeNil())
 		})
 		It("with metrics port and enabled is reflected in installation", func() {
@@ -586,7 +1214,7 @@ var _ = Describe("core handler", func() {
 				Value: "7777",
 			}}
 
-			Expect(handleFelixNodeMetrics(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleFelixNodeMetrics(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(*i.Spec.NodeMetricsPort).To(Equal(int32(7777)))
 		})
 	})
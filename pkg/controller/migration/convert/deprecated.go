@@ -0,0 +1,33 @@
+package convert
+
+import (
+	"context"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// deprecatedEnvVars maps env vars which are still accepted on calico-node but are
+// no longer needed to advice explaining why. Vars which another handler reports on, such as
+// WAIT_FOR_DATASTORE or CALICO_IPV4POOL_CIDR, do not belong here.
+var deprecatedEnvVars = map[string]string{
+	"FELIX_IPINIPENABLED": "IP-in-IP is enabled automatically based on the encapsulation of the IP pools, " +
+		"the value is still carried onto the default FelixConfiguration",
+}
+
+// handleDeprecatedEnvVars is a migration handler which records a warning for each deprecated
+// env var found on calico-node. It does not mark any vars as checked, that is left to the handler
+// which carries each var forward.
+func handleDeprecatedEnvVars(_ context.Context, c *components, _ *operatorv1.Installation) error {
+	spec := c.node.Spec.Template.Spec
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			for _, env := range container.Env {
+				if advice, ok := deprecatedEnvVars[env.Name]; ok {
+					c.warn(ComponentCalicoNode, "%s/%s is deprecated: %s", container.Name, env.Name, advice)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package convert
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/apis"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("deprecated env vars", func() {
+	var (
+		comps = emptyComponents()
+		i     = &operatorv1.Installation{}
+	)
+
+	BeforeEach(func() {
+		comps = emptyComponents()
+		i = &operatorv1.Installation{}
+	})
+
+	It("should not warn if no deprecated vars are set", func() {
+		Expect(handleDeprecatedEnvVars(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(BeEmpty())
+	})
+
+	It("should warn for a deprecated var", func() {
+		comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+			Name:  "FELIX_IPINIPENABLED",
+			Value: "true",
+		}}
+		Expect(handleDeprecatedEnvVars(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(ConsistOf(Warning{
+			Component: ComponentCalicoNode,
+			Message:   "calico-node/FELIX_IPINIPENABLED is deprecated: " + deprecatedEnvVars["FELIX_IPINIPENABLED"],
+		}))
+	})
+
+	It("should not warn for vars which are handled elsewhere", func() {
+		comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+			{Name: "WAIT_FOR_DATASTORE", Value: "false"},
+			{Name: "CALICO_IPV4POOL_CIDR", Value: "192.168.0.0/16"},
+			{Name: "CALICO_IPV6POOL_CIDR", Value: "fd00::/48"},
+		}
+		Expect(handleDeprecatedEnvVars(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(BeEmpty())
+	})
+
+	It("should not mark deprecated vars as checked", func() {
+		comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+			Name:  "FELIX_IPINIPENABLED",
+			Value: "true",
+		}}
+		Expect(handleDeprecatedEnvVars(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.node.uncheckedVars()).To(ContainElement("calico-node/FELIX_IPINIPENABLED"))
+	})
+
+	It("should include the warning in the migration report", func() {
+		scheme := kscheme.Scheme
+		Expect(apis.AddToScheme(scheme)).ToNot(HaveOccurred())
+		pool := crdv1.NewIPPool()
+		pool.Spec = crdv1.IPPoolSpec{
+			CIDR:        "192.168.4.0/24",
+			IPIPMode:    crdv1.IPIPModeAlways,
+			NATOutgoing: true,
+		}
+		ds := emptyNodeSpec()
+		ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+			Name:  "FELIX_IPINIPENABLED",
+			Value: "false",
+		}}
+		c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+		_, report, err := ConvertWithReport(context.Background(), c, Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Warnings).To(ContainElement(Warning{
+			Component: ComponentCalicoNode,
+			Message:   "calico-node/FELIX_IPINIPENABLED is deprecated: " + deprecatedEnvVars["FELIX_IPINIPENABLED"],
+		}))
+
+		// the value is still carried forward.
+		f := crdv1.FelixConfiguration{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+		Expect(f.Spec.IPIPEnabled).To(Equal(new(bool)))
+	})
+})
@@ -0,0 +1,72 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// egressGatewayAnnotations are the annotations which select the egress gateways used by a namespace.
+var egressGatewayAnnotations = []string{
+	"egress.projectcalico.org/selector",
+	"egress.projectcalico.org/namespaceSelector",
+}
+
+// handleEgressGateway blocks migrating an Enterprise install which uses egress gateways, as the
+// Installation can't express them. The error lists each piece of egress gateway config found so
+// that the user knows what must be migrated manually.
+func handleEgressGateway(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	if c.variant != operatorv1.TigeraSecureEnterprise {
+		return nil
+	}
+
+	var found []string
+
+	if node := getContainer(c.node.Spec.Template.Spec, containerCalicoNode); node != nil {
+		for _, e := range node.Env {
+			if strings.HasPrefix(e.Name, "FELIX_EGRESSIP") {
+				found = append(found, fmt.Sprintf("%s on %s", e.Name, ComponentCalicoNode))
+			}
+		}
+	}
+
+	deployments := appsv1.DeploymentList{}
+	if err := c.client.List(ctx, &deployments); err != nil {
+		return fmt.Errorf("failed to list deployments: %v", err)
+	}
+	for _, d := range deployments.Items {
+		for _, container := range d.Spec.Template.Spec.Containers {
+			if strings.Contains(container.Image, "egress-gateway") {
+				found = append(found, fmt.Sprintf("egress gateway deployment %s/%s", d.Namespace, d.Name))
+				break
+			}
+		}
+	}
+
+	namespaces := corev1.NamespaceList{}
+	if err := c.client.List(ctx, &namespaces); err != nil {
+		return fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	for _, ns := range namespaces.Items {
+		for _, a := range egressGatewayAnnotations {
+			if _, ok := ns.Annotations[a]; ok {
+				found = append(found, fmt.Sprintf("%s annotation on namespace %s", a, ns.Name))
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+	sort.Strings(found)
+	return c.incompatible(ErrIncompatibleCluster{
+		err:       fmt.Sprintf("egress gateway configuration can not be migrated by the Installation: %s", strings.Join(found, ", ")),
+		component: ComponentCalicoNode,
+		fix:       "remove the egress gateway configuration before migrating, and re-apply it manually once the operator manages the cluster",
+	})
+}
@@ -0,0 +1,76 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("egress gateway", func() {
+	var (
+		comps = emptyComponents()
+		i     = &operatorv1.Installation{}
+	)
+
+	gatewayDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress-gateway", Namespace: "egress"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "egress-gateway",
+						Image: "quay.io/tigera/egress-gateway:v3.4.0",
+					}},
+				},
+			},
+		},
+	}
+	annotatedNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Annotations: map[string]string{"egress.projectcalico.org/selector": "egress-code == 'red'"},
+		},
+	}
+
+	BeforeEach(func() {
+		comps = emptyComponents()
+		comps.variant = operatorv1.TigeraSecureEnterprise
+		comps.client = fake.NewFakeClient()
+		i = &operatorv1.Installation{}
+	})
+
+	It("should not error on enterprise without egress gateway config", func() {
+		Expect(handleEgressGateway(ctx, &comps, i)).ToNot(HaveOccurred())
+	})
+
+	It("should not check calico installs", func() {
+		comps.variant = operatorv1.Calico
+		comps.client = fake.NewFakeClient(gatewayDeployment, annotatedNamespace)
+		comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_EGRESSIPSUPPORT", Value: "EnabledPerNamespace"}}
+		Expect(handleEgressGateway(ctx, &comps, i)).ToNot(HaveOccurred())
+	})
+
+	It("should list all egress gateway config in the error", func() {
+		comps.client = fake.NewFakeClient(gatewayDeployment, annotatedNamespace)
+		comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_EGRESSIPSUPPORT", Value: "EnabledPerNamespace"}}
+		err := handleEgressGateway(ctx, &comps, i)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("FELIX_EGRESSIPSUPPORT on " + ComponentCalicoNode))
+		Expect(err.Error()).To(ContainSubstring("egress gateway deployment egress/egress-gateway"))
+		Expect(err.Error()).To(ContainSubstring("egress.projectcalico.org/selector annotation on namespace app"))
+	})
+
+	It("should only warn in lenient mode", func() {
+		comps.mode = ModeLenient
+		comps.client = fake.NewFakeClient(gatewayDeployment)
+		Expect(handleEgressGateway(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(HaveLen(1))
+		Expect(comps.report.Warnings[0].Message).To(ContainSubstring("egress gateway deployment egress/egress-gateway"))
+	})
+})
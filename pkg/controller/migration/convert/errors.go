@@ -26,6 +26,10 @@ const (
 	ComponentTypha           = "deployment/calico-typha"
 	ComponentCNIConfig       = "cni-config"
 	ComponentIPPools         = "ippools"
+	ComponentAWSNode         = "daemonset/aws-node"
+	ComponentClusterInfo     = "clusterinformation/default"
+	ComponentIPAMConfig      = "ipamconfig/default"
+	ComponentAPIServer       = "deployment/calico-apiserver"
 )
 
 func ErrMissingHostPathVolume(component, volume, hostPath string) ErrIncompatibleCluster {
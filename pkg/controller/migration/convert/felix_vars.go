@@ -1,9 +1,13 @@
 package convert
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"net"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +18,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type patch struct {
@@ -33,16 +38,65 @@ func (s *patches) Data(obj runtime.Object) ([]byte, error) {
 	return json.Marshal(s)
 }
 
+// felixConfigBuilder accumulates the settings which handlers carry onto the default FelixConfiguration,
+// so that each handler can set fields independently and a single patch is written at the end.
+// The zero value is ready to use.
+type felixConfigBuilder struct {
+	// byPath holds the patch for each field, keyed by its path, so that a later
+	// setting of the same field replaces an earlier one.
+	byPath map[string]patch
+}
+
+// set records the value of the FelixConfigurationSpec field with the given json name.
+func (b *felixConfigBuilder) set(field string, value interface{}) {
+	b.add(patch{
+		Op:    "replace",
+		Path:  fmt.Sprintf("/spec/%s", field),
+		Value: value,
+	})
+}
+
+// add records a patch, replacing any earlier patch of the same field.
+func (b *felixConfigBuilder) add(p patch) {
+	if b.byPath == nil {
+		b.byPath = map[string]patch{}
+	}
+	b.byPath[p.Path] = p
+}
+
+// patches returns the recorded settings, ordered by path so that the output is stable.
+func (b *felixConfigBuilder) patches() *patches {
+	paths := []string{}
+	for path := range b.byPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	p := patches{}
+	for _, path := range paths {
+		p = append(p, b.byPath[path])
+	}
+	return &p
+}
+
+// apply patches the recorded settings into the default FelixConfiguration. It is only called once
+// the migration has succeeded, so that a failed migration leaves the FelixConfiguration untouched.
+func (b *felixConfigBuilder) apply(ctx context.Context, cli client.Client) error {
+	return cli.Patch(ctx, &crdv1.FelixConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+	}, b.patches())
+}
+
 // handleFelixVars handles unexpected felix env vars (i.e. vars that start with FELIX_*) on the calico-node container
-// by patching them into the default FelixConfiguration resource.
-func handleFelixVars(c *components) error {
+// by adding them to the settings for the default FelixConfiguration resource. It runs after all other handlers,
+// so a var which it carries over replaces any setting of the same field by an earlier handler.
+func handleFelixVars(ctx context.Context, c *components) error {
 	cn := getContainer(c.node.Spec.Template.Spec, containerCalicoNode)
 	if cn == nil {
 		return fmt.Errorf("missing calico-node container")
 	}
 	// loop through all env vars of the form 'FELIX_key=val', and convert them
 	// into patches
-	p := new(patches)
 	for _, env := range cn.Env {
 		if !strings.HasPrefix(env.Name, "FELIX_") {
 			continue
@@ -61,17 +115,84 @@ func handleFelixVars(c *components) error {
 
 		// downcase and remove FELIX_ prefix
 		key := strings.ToLower(strings.TrimPrefix(env.Name, "FELIX_"))
+		// an invalid value is not carried forward when the migration is lenient.
+		invalid := func(err error) error {
+			return c.incompatible(ErrIncompatibleCluster{
+				err:       fmt.Sprintf("%s is not valid: %v", env.Name, err),
+				component: ComponentCalicoNode,
+				fix:       fmt.Sprintf("correct or remove %s", env.Name),
+			})
+		}
+		if validate, ok := felixVarValidators[key]; ok {
+			if err := validate(*fval); err != nil {
+				if err := invalid(err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
 		pp, err := patchFromVal(key, *fval)
 		if err != nil {
-			return err
+			if err := invalid(err); err != nil {
+				return err
+			}
+			continue
 		}
-		*p = append(*p, pp)
+		c.felixConfig.add(pp)
+	}
+
+	return nil
+}
+
+// the values felix accepts for FELIX_ROUTESOURCE.
+const (
+	routeSourceCalicoIPAM  = "CalicoIPAM"
+	routeSourceWorkloadIPs = "WorkloadIPs"
+)
 
+// felixVarValidators holds additional validation for felix vars, keyed by the downcased name
+// without the FELIX_ prefix, for settings whose type alone does not catch invalid values.
+var felixVarValidators = map[string]func(string) error{
+	"natoutgoingaddress":       validateIP,
+	"deviceroutesourceaddress": validateIP,
+	"routesource": func(val string) error {
+		if val != routeSourceCalicoIPAM && val != routeSourceWorkloadIPs {
+			return fmt.Errorf("'%s' should be one of %s,%s", val, routeSourceCalicoIPAM, routeSourceWorkloadIPs)
+		}
+		return nil
+	},
+	"vxlanport": func(val string) error {
+		return validateIntRange(val, 1, 65535)
+	},
+	// the vni is a 24 bit field in the vxlan header.
+	"vxlanvni": func(val string) error {
+		return validateIntRange(val, 1, 1<<24-1)
+	},
+	// a reporting interval of 0 disables reporting.
+	"reportingintervalsecs": func(val string) error {
+		return validateIntRange(val, 0, math.MaxInt32)
+	},
+	"reportingttlsecs": func(val string) error {
+		return validateIntRange(val, 0, math.MaxInt32)
+	},
+}
+
+func validateIntRange(val string, min, max int) error {
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return fmt.Errorf("'%s' is not an integer", val)
+	}
+	if i < min || i > max {
+		return fmt.Errorf("%d is not between %d and %d", i, min, max)
 	}
+	return nil
+}
 
-	return c.client.Patch(ctx, &crdv1.FelixConfiguration{
-		ObjectMeta: metav1.ObjectMeta{Name: "default"},
-	}, p)
+func validateIP(val string) error {
+	if net.ParseIP(val) == nil {
+		return fmt.Errorf("'%s' is not an IP address", val)
+	}
+	return nil
 }
 
 func patchFromVal(key, val string) (patch, error) {
@@ -84,7 +205,24 @@ func patchFromVal(key, val string) (patch, error) {
 		field := fc.Type().Field(ii)
 		value := fc.Field(ii)
 
-		if strings.ToLower(key) == strings.ToLower(field.Name) {
+		// settings may also be given by their v1 config name, e.g. FELIX_IPINIPENABLED. Durations
+		// set this way, e.g. FELIX_REPORTINGINTERVALSECS, are in the unit given by the timescale
+		// rather than a go duration.
+		v1Name := field.Tag.Get("confignamev1")
+		isV1Name := v1Name != "" && strings.ToLower(key) == strings.ToLower(v1Name)
+		if isV1Name && field.Tag.Get("configv1timescale") == "seconds" {
+			d, err := parseSeconds(val)
+			if err != nil {
+				return patch{}, err
+			}
+			return patch{
+				Op:    "replace",
+				Path:  fmt.Sprintf("/spec/%s", strings.Split(field.Tag.Get("json"), ",")[0]),
+				Value: d,
+			}, nil
+		}
+
+		if isV1Name || strings.ToLower(key) == strings.ToLower(field.Name) {
 			fieldName := strings.Split(field.Tag.Get("json"), ",")[0]
 
 			v, err := convert(value.Interface(), val)
@@ -103,6 +241,70 @@ func patchFromVal(key, val string) (patch, error) {
 	return patch{}, fmt.Errorf("unrecognized felix config setting: %v", key)
 }
 
+// parseSeconds parses a duration given as a non-negative number of seconds.
+func parseSeconds(val string) (*metav1.Duration, error) {
+	secs, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a number of seconds", val)
+	}
+	if secs < 0 {
+		return nil, fmt.Errorf("'%s' is negative", val)
+	}
+	return &metav1.Duration{Duration: time.Duration(secs * float64(time.Second))}, nil
+}
+
+// parseProtoPorts parses a felix list of protocol / port pairs, such as the failsafe host ports,
+// e.g. "tcp:22,udp:68". As in felix, the protocol defaults to tcp if omitted, and the value "none"
+// is an empty list.
+func parseProtoPorts(str string) ([]crdv1.ProtoPort, error) {
+	pps := []crdv1.ProtoPort{}
+	if strings.ToLower(strings.TrimSpace(str)) == "none" {
+		return pps, nil
+	}
+	for _, ppStr := range strings.Split(str, ",") {
+		ppStr = strings.TrimSpace(ppStr)
+		proto, portStr := "tcp", ppStr
+		if vals := strings.Split(ppStr, ":"); len(vals) == 2 {
+			proto, portStr = strings.ToLower(vals[0]), vals[1]
+		} else if len(vals) != 1 {
+			return nil, fmt.Errorf("invalid entry '%s', must be of form [<proto>:]<port>", ppStr)
+		}
+		if proto != "tcp" && proto != "udp" && proto != "sctp" {
+			return nil, fmt.Errorf("invalid protocol '%s' in entry '%s', should be one of tcp,udp,sctp", proto, ppStr)
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("invalid port '%s' in entry '%s', should be within the range of 1-65535", portStr, ppStr)
+		}
+		pps = append(pps, crdv1.ProtoPort{
+			Port:     uint16(port),
+			Protocol: proto,
+		})
+	}
+	return pps, nil
+}
+
+// parsePortRanges parses a comma-separated list of ports and <min>:<max> port ranges, such as
+// FELIX_KUBENODEPORTRANGES. Named ports are rejected since felix only accepts numeric ranges.
+func parsePortRanges(str string) ([]numorstring.Port, error) {
+	ports := []numorstring.Port{}
+	for _, p := range strings.Split(str, ",") {
+		p = strings.TrimSpace(p)
+		port, err := numorstring.PortFromString(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port range '%s': %v", p, err)
+		}
+		if port.PortName != "" {
+			return nil, fmt.Errorf("invalid port range '%s', must be of form <port> or <min>:<max>", p)
+		}
+		if port.MinPort == 0 {
+			return nil, fmt.Errorf("invalid port range '%s', ports should be within the range of 1-65535", p)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
 // convert transforms a string representation to the desired type <t>.
 // the only types supported are the known types of FelixConfigurationSpec.
 func convert(t interface{}, str string) (interface{}, error) {
@@ -147,36 +349,29 @@ func convert(t interface{}, str string) (interface{}, error) {
 		return &v, nil
 
 	case *[]crdv1.ProtoPort:
-		pps := []crdv1.ProtoPort{}
-		ppsStr := strings.Split(str, ",")
-		for _, ppStr := range ppsStr {
-			vals := strings.Split(ppStr, ":")
-			if len(vals) != 2 {
-				return nil, fmt.Errorf("could not convert protoport: must be of form <proto>:<port>")
-			}
-			port, err := strconv.ParseUint(vals[1], 10, 16)
-			if err != nil {
-				return nil, fmt.Errorf("could not convert port to number: %s", vals[0])
-			}
-			pps = append(pps, crdv1.ProtoPort{
-				Port:     uint16(port),
-				Protocol: vals[0],
-			})
+		pps, err := parseProtoPorts(str)
+		if err != nil {
+			return nil, err
 		}
 		return &pps, nil
 
 	case *[]numorstring.Port:
-		ports := []numorstring.Port{}
-		strs := strings.Split(str, ",")
-		for _, p := range strs {
-			port, err := numorstring.PortFromString(p)
-			if err != nil {
-				return nil, err
-			}
-			ports = append(ports, port)
+		ports, err := parsePortRanges(str)
+		if err != nil {
+			return nil, err
 		}
 		return &ports, nil
 
+	case *numorstring.Port:
+		port, err := numorstring.PortFromString(str)
+		if err != nil {
+			return nil, err
+		}
+		if port.PortName != "" {
+			return nil, fmt.Errorf("expected a port or port range, got '%s'", str)
+		}
+		return &port, nil
+
 	case *metav1.Duration:
 		d, err := time.ParseDuration(str)
 		if err != nil {
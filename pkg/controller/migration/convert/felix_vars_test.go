@@ -4,8 +4,10 @@ import (
 	"time"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
+	operatorv1 "github.com/tigera/operator/api/v1"
 	"github.com/tigera/operator/pkg/apis"
 	"github.com/tigera/operator/pkg/controller/migration/convert/numorstring"
 
@@ -70,6 +72,27 @@ var _ = Describe("felix env parser", func() {
 		}))
 	})
 
+	It("converts protoports with a default protocol and whitespace", func() {
+		fe, err := patchFromVal("failsafeoutboundhostports", "UDP:53, 6443")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fe.Value).To(Equal(&[]crdv1.ProtoPort{{Port: 53, Protocol: "udp"}, {Port: 6443, Protocol: "tcp"}}))
+	})
+
+	It("converts protoports set to none", func() {
+		fe, err := patchFromVal("failsafeinboundhostports", "none")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fe.Value).To(Equal(&[]crdv1.ProtoPort{}))
+	})
+
+	It("rejects malformed protoports", func() {
+		_, err := patchFromVal("failsafeinboundhostports", "tcp:22,icmp:8")
+		Expect(err).To(MatchError("invalid protocol 'icmp' in entry 'icmp:8', should be one of tcp,udp,sctp"))
+		_, err = patchFromVal("failsafeinboundhostports", "tcp:ssh")
+		Expect(err).To(MatchError("invalid port 'ssh' in entry 'tcp:ssh', should be within the range of 1-65535"))
+		_, err = patchFromVal("failsafeinboundhostports", "tcp:10.0.0.1:22")
+		Expect(err).To(MatchError("invalid entry 'tcp:10.0.0.1:22', must be of form [<proto>:]<port>"))
+	})
+
 	It("converts a RouteTableRange", func() {
 		fe, err := patchFromVal("routetablerange", "22-44")
 		Expect(err).ToNot(HaveOccurred())
@@ -112,6 +135,21 @@ var _ = Describe("felix env parser", func() {
 		}))
 	})
 
+	It("converts a port range", func() {
+		fe, err := patchFromVal("natportrange", "32768:65535")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fe).To(Equal(patch{
+			Op:    "replace",
+			Path:  "/spec/natPortRange",
+			Value: &numorstring.Port{MinPort: 32768, MaxPort: 65535},
+		}))
+	})
+
+	It("rejects a named port for a port range", func() {
+		_, err := patchFromVal("natportrange", "http")
+		Expect(err).To(HaveOccurred())
+	})
+
 	Context("creating a felixconfiguration", func() {
 		var c = emptyComponents()
 
@@ -129,7 +167,8 @@ var _ = Describe("felix env parser", func() {
 				Value: "true",
 			}}
 
-			Expect(handleFelixVars(&c)).ToNot(HaveOccurred())
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
 
 			f := crdv1.FelixConfiguration{}
 			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
@@ -143,7 +182,8 @@ var _ = Describe("felix env parser", func() {
 				Value: "20s",
 			}}
 
-			Expect(handleFelixVars(&c)).ToNot(HaveOccurred())
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
 
 			f := crdv1.FelixConfiguration{}
 			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
@@ -151,13 +191,41 @@ var _ = Describe("felix env parser", func() {
 			Expect(f.Spec.IptablesRefreshInterval).To(Equal(&metav1.Duration{Duration: 20 * time.Second}))
 		})
 
+		It("sets the reporting settings from their seconds", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_REPORTINGINTERVALSECS", Value: "0"},
+				{Name: "FELIX_REPORTINGTTLSECS", Value: "120"},
+			}
+
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			Expect(f.Spec.ReportingInterval).To(Equal(&metav1.Duration{Duration: 0}))
+			Expect(f.Spec.ReportingTTL).To(Equal(&metav1.Duration{Duration: 120 * time.Second}))
+		})
+
+		DescribeTable("errors on invalid reporting settings", func(env, value string) {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: env, Value: value}}
+			err := handleFelixVars(ctx, &c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(env + " is not valid"))
+		},
+			Entry("negative interval", "FELIX_REPORTINGINTERVALSECS", "-30"),
+			Entry("negative ttl", "FELIX_REPORTINGTTLSECS", "-1"),
+			Entry("fractional ttl", "FELIX_REPORTINGTTLSECS", "1.5"),
+			Entry("go duration interval", "FELIX_REPORTINGINTERVALSECS", "30s"),
+		)
+
 		It("sets iptablesbackend", func() {
 			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
 				Name:  "FELIX_IPTABLESBACKEND",
 				Value: "Legacy",
 			}}
 
-			Expect(handleFelixVars(&c)).ToNot(HaveOccurred())
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
 
 			f := crdv1.FelixConfiguration{}
 			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
@@ -165,5 +233,341 @@ var _ = Describe("felix env parser", func() {
 			legacy := crdv1.IptablesBackend(crdv1.IptablesBackendLegacy)
 			Expect(f.Spec.IptablesBackend).To(Equal(&legacy))
 		})
+
+		It("sets the nat port range and outgoing address", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_NATPORTRANGE", Value: "32768:65535"},
+				{Name: "FELIX_NATOUTGOINGADDRESS", Value: "10.0.0.5"},
+			}
+
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			Expect(f.Spec.NATPortRange).To(Equal(&numorstring.Port{MinPort: 32768, MaxPort: 65535}))
+			Expect(f.Spec.NATOutgoingAddress).To(Equal("10.0.0.5"))
+		})
+
+		It("sets custom failsafe ports", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_FAILSAFEINBOUNDHOSTPORTS", Value: "tcp:22,udp:68,tcp:10250"},
+				{Name: "FELIX_FAILSAFEOUTBOUNDHOSTPORTS", Value: "udp:53,tcp:6443"},
+			}
+
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			Expect(f.Spec.FailsafeInboundHostPorts).To(Equal(&[]crdv1.ProtoPort{
+				{Protocol: "tcp", Port: 22}, {Protocol: "udp", Port: 68}, {Protocol: "tcp", Port: 10250},
+			}))
+			Expect(f.Spec.FailsafeOutboundHostPorts).To(Equal(&[]crdv1.ProtoPort{
+				{Protocol: "udp", Port: 53}, {Protocol: "tcp", Port: 6443},
+			}))
+		})
+
+		It("errors on malformed failsafe ports", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_FAILSAFEINBOUNDHOSTPORTS",
+				Value: "tcp:22,tcp:70000",
+			}}
+			err := handleFelixVars(ctx, &c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FELIX_FAILSAFEINBOUNDHOSTPORTS is not valid: invalid port '70000' in entry 'tcp:70000'"))
+		})
+
+		It("sets custom kube node port ranges", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_KUBENODEPORTRANGES",
+				Value: "30000:32767, 40000:40100,50000",
+			}}
+
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			Expect(f.Spec.KubeNodePortRanges).To(Equal(&[]numorstring.Port{
+				{MinPort: 30000, MaxPort: 32767}, {MinPort: 40000, MaxPort: 40100}, {MinPort: 50000, MaxPort: 50000},
+			}))
+		})
+
+		DescribeTable("errors on malformed kube node port ranges", func(ranges, expected string) {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_KUBENODEPORTRANGES",
+				Value: ranges,
+			}}
+			err := handleFelixVars(ctx, &c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FELIX_KUBENODEPORTRANGES is not valid: " + expected))
+		},
+			Entry("reversed range", "30000:32767,32767:30000", "invalid port range '32767:30000'"),
+			Entry("out of range port", "30000:70000", "invalid port range '30000:70000'"),
+			Entry("named port", "30000:32767,http", "invalid port range 'http', must be of form <port> or <min>:<max>"),
+			Entry("zero port", "0:100", "invalid port range '0:100', ports should be within the range of 1-65535"),
+			Entry("empty entry", "30000:32767,", "invalid port range ''"),
+		)
+
+		It("errors on an invalid nat port range", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_NATPORTRANGE",
+				Value: "65535:32768",
+			}}
+			Expect(handleFelixVars(ctx, &c)).To(HaveOccurred())
+		})
+
+		It("errors on an invalid nat outgoing address", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_NATOUTGOINGADDRESS",
+				Value: "10.0.0",
+			}}
+			err := handleFelixVars(ctx, &c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FELIX_NATOUTGOINGADDRESS is not valid"))
+		})
+
+		It("sets the route source and device route source address", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_ROUTESOURCE", Value: "WorkloadIPs"},
+				{Name: "FELIX_DEVICEROUTESOURCEADDRESS", Value: "10.0.0.5"},
+			}
+
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			Expect(f.Spec.RouteSource).To(Equal("WorkloadIPs"))
+			Expect(f.Spec.DeviceRouteSourceAddress).To(Equal("10.0.0.5"))
+		})
+
+		It("errors on an invalid route source", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_ROUTESOURCE",
+				Value: "BGP",
+			}}
+			err := handleFelixVars(ctx, &c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FELIX_ROUTESOURCE is not valid: 'BGP' should be one of CalicoIPAM,WorkloadIPs"))
+		})
+
+		It("errors on an invalid device route source address", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_DEVICEROUTESOURCEADDRESS",
+				Value: "10.0.0.256",
+			}}
+			err := handleFelixVars(ctx, &c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FELIX_DEVICEROUTESOURCEADDRESS is not valid"))
+		})
+
+		It("sets a custom vxlan port and vni", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_VXLANPORT", Value: "8472"},
+				{Name: "FELIX_VXLANVNI", Value: "1"},
+			}
+
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			Expect(f.Spec.VXLANPort).ToNot(BeNil())
+			Expect(*f.Spec.VXLANPort).To(Equal(8472))
+			Expect(f.Spec.VXLANVNI).ToNot(BeNil())
+			Expect(*f.Spec.VXLANVNI).To(Equal(1))
+		})
+
+		It("errors on an out of range vxlan port", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_VXLANPORT",
+				Value: "70000",
+			}}
+			err := handleFelixVars(ctx, &c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FELIX_VXLANPORT is not valid: 70000 is not between 1 and 65535"))
+		})
+
+		It("errors on an out of range vxlan vni", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_VXLANVNI",
+				Value: "16777216",
+			}}
+			err := handleFelixVars(ctx, &c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FELIX_VXLANVNI is not valid: 16777216 is not between 1 and 16777215"))
+		})
+	})
+
+	Context("felix config builder", func() {
+		var c = emptyComponents()
+
+		BeforeEach(func() {
+			c = emptyComponents()
+
+			scheme := kscheme.Scheme
+			Expect(apis.AddToScheme(scheme)).ToNot(HaveOccurred())
+			c.client = fake.NewFakeClientWithScheme(scheme, emptyFelixConfig())
+		})
+
+		It("orders the patches by path", func() {
+			c.felixConfig.set("logSeverityScreen", "Debug")
+			c.felixConfig.set("bpfEnabled", true)
+			Expect(*c.felixConfig.patches()).To(Equal(patches{
+				{Op: "replace", Path: "/spec/bpfEnabled", Value: true},
+				{Op: "replace", Path: "/spec/logSeverityScreen", Value: "Debug"},
+			}))
+		})
+
+		It("keeps the last value set for a field", func() {
+			c.felixConfig.set("logSeverityScreen", "Debug")
+			c.felixConfig.set("logSeverityScreen", "Warning")
+			Expect(*c.felixConfig.patches()).To(Equal(patches{
+				{Op: "replace", Path: "/spec/logSeverityScreen", Value: "Warning"},
+			}))
+		})
+
+		It("writes the settings of earlier handlers along with the felix vars", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_ROUTESOURCE", Value: "WorkloadIPs"},
+				{Name: "FELIX_IPTABLESBACKEND", Value: "Legacy"},
+			}
+
+			Expect(handleRouteSource(ctx, &c, nil)).ToNot(HaveOccurred())
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			legacy := crdv1.IptablesBackend(crdv1.IptablesBackendLegacy)
+			Expect(*c.felixConfig.patches()).To(Equal(patches{
+				{Op: "replace", Path: "/spec/iptablesBackend", Value: &legacy},
+				{Op: "replace", Path: "/spec/routeSource", Value: "WorkloadIPs"},
+			}))
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			Expect(f.Spec.RouteSource).To(Equal("WorkloadIPs"))
+			Expect(f.Spec.IptablesBackend).To(Equal(&legacy))
+		})
+
+		It("lets a felix var override an earlier handler's setting", func() {
+			c.felixConfig.set("bpfEnabled", true)
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  "FELIX_BPFENABLED",
+				Value: "false",
+			}}
+
+			Expect(handleFelixVars(ctx, &c)).ToNot(HaveOccurred())
+			Expect(c.felixConfig.apply(ctx, c.client)).To(Succeed())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(c.client.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			Expect(f.Spec.BPFEnabled).ToNot(BeNil())
+			Expect(*f.Spec.BPFEnabled).To(BeFalse())
+		})
+
+		It("does not write the FelixConfiguration if the migration fails", func() {
+			node := emptyNodeSpec()
+			node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_BPFENABLED", Value: "true"},
+				{Name: "FOO", Value: "bar"},
+			}
+			pool := crdv1.NewIPPool()
+			pool.Spec = crdv1.IPPoolSpec{CIDR: "192.168.4.0/24", IPIPMode: crdv1.IPIPModeAlways, NATOutgoing: true}
+			cli := fake.NewFakeClientWithScheme(kscheme.Scheme, node, pool, emptyFelixConfig())
+			_, _, err := ConvertWithReport(ctx, cli, Options{})
+			Expect(err).To(HaveOccurred())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(cli.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			Expect(f.Spec.BPFEnabled).To(BeNil())
+		})
+	})
+
+	Context("route source", func() {
+		var c = emptyComponents()
+
+		BeforeEach(func() {
+			c = emptyComponents()
+		})
+
+		It("does not warn about CalicoIPAM with Calico CNI", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "FELIX_ROUTESOURCE", Value: "CalicoIPAM"}}
+			Expect(handleRouteSource(ctx, &c, nil)).ToNot(HaveOccurred())
+			Expect(c.report.Warnings).To(BeEmpty())
+			Expect(c.node.uncheckedVars()).ToNot(ContainElement("calico-node/FELIX_ROUTESOURCE"))
+			Expect(*c.felixConfig.patches()).To(Equal(patches{{Op: "replace", Path: "/spec/routeSource", Value: "CalicoIPAM"}}))
+		})
+
+		It("warns about CalicoIPAM when not using Calico CNI", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_INTERFACEPREFIX", Value: "eni"},
+				{Name: "FELIX_ROUTESOURCE", Value: "CalicoIPAM"},
+			}
+			Expect(handleRouteSource(ctx, &c, nil)).ToNot(HaveOccurred())
+			Expect(c.report.Warnings).To(HaveLen(1))
+			Expect(c.report.Warnings[0].Message).To(ContainSubstring("FELIX_ROUTESOURCE=CalicoIPAM will be overridden"))
+		})
+
+		It("does not warn about WorkloadIPs when not using Calico CNI", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_INTERFACEPREFIX", Value: "eni"},
+				{Name: "FELIX_ROUTESOURCE", Value: "WorkloadIPs"},
+			}
+			Expect(handleRouteSource(ctx, &c, nil)).ToNot(HaveOccurred())
+			Expect(c.report.Warnings).To(BeEmpty())
+		})
+
+		It("errors on an invalid route source", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "FELIX_ROUTESOURCE", Value: "BGP"}}
+			err := handleRouteSource(ctx, &c, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FELIX_ROUTESOURCE is not valid: 'BGP' should be one of CalicoIPAM,WorkloadIPs"))
+		})
+	})
+
+	Context("usage reporting", func() {
+		var pool *crdv1.IPPool
+
+		BeforeEach(func() {
+			pool = crdv1.NewIPPool()
+			pool.Spec = crdv1.IPPoolSpec{
+				CIDR:        "192.168.4.0/24",
+				IPIPMode:    crdv1.IPIPModeAlways,
+				NATOutgoing: true,
+			}
+		})
+
+		convertWithEnv := func(env ...v1.EnvVar) crdv1.FelixConfiguration {
+			scheme := kscheme.Scheme
+			Expect(apis.AddToScheme(scheme)).ToNot(HaveOccurred())
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = env
+			cli := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+
+			_, err := Convert(ctx, cli, operatorv1.ProviderNone)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := crdv1.FelixConfiguration{}
+			Expect(cli.Get(ctx, types.NamespacedName{Name: "default"}, &f)).ToNot(HaveOccurred())
+			return f
+		}
+
+		It("leaves usage reporting at its default when unset", func() {
+			f := convertWithEnv()
+			Expect(f.Spec.UsageReportingEnabled).To(BeNil())
+		})
+
+		It("carries forward usage reporting when enabled", func() {
+			f := convertWithEnv(v1.EnvVar{Name: "FELIX_USAGEREPORTINGENABLED", Value: "true"})
+			Expect(f.Spec.UsageReportingEnabled).ToNot(BeNil())
+			Expect(*f.Spec.UsageReportingEnabled).To(BeTrue())
+		})
+
+		It("carries forward usage reporting when disabled", func() {
+			f := convertWithEnv(v1.EnvVar{Name: "FELIX_USAGEREPORTINGENABLED", Value: "false"})
+			Expect(f.Spec.UsageReportingEnabled).ToNot(BeNil())
+			Expect(*f.Spec.UsageReportingEnabled).To(BeFalse())
+		})
 	})
 })
@@ -1,6 +1,13 @@
 package convert
 
-import operatorv1 "github.com/tigera/operator/api/v1"
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+)
 
 // handlers are grouped by feature or product and check various
 // fields on Calico components to construct a Installation resource that
@@ -9,20 +16,71 @@ import operatorv1 "github.com/tigera/operator/api/v1"
 // - mark incompatible clusters by returning a IncompatibleClusterError
 // - carry user config forward by setting the Installation resource according to the installed config
 // - mark variables as 'checked' so that the final env var catch-all doesn't throw an 'unexpected env var' error
-type handler func(*components, *operatorv1.Installation) error
+// - record warnings in the migration report for config that is carried forward but deserves the user's attention
+type handler func(context.Context, *components, *operatorv1.Installation) error
 
 var handlers = []handler{
+	handleDeprecatedEnvVars,
+	handleCalicoVersion,
+	handleVariant,
 	checkTypha,
+	handleTyphaTLS,
 	handleAddonManager,
 	handleNetwork,
+	handleDualStack,
 	handleIPv6,
+	handleRouterID,
 	handleCore,
+	handleUpgradeIPAM,
+	handleAuxiliaryInitContainers,
+	handleKubeControllersHealth,
+	handleAPIServer,
+	handleEgressGateway,
+	handleNodeServiceAccount,
+	handleNodeSecurityContext,
+	handleNodePriorityClass,
+	handleNodeDNS,
+	handleNodeVolumes,
 	handleAnnotations,
 	handleNodeSelectors,
+	handleSchedulingConstraints,
 	handleFelixNodeMetrics,
+	handleNodeMetricsService,
 	handleTyphaMetrics,
+	handleTyphaScaling,
 	handleCalicoCNI,
 	handleNonCalicoCNI,
+	handleRouteSource,
+	handleAWSNode,
+	handleBGP,
 	handleMTU,
 	handleIPPools,
+	handleIPAMConfig,
+	handlePlatformPodCIDRs,
+	handleBackendEncapsulation,
+	handleDualStackAutodetection,
+	handleMTUEncapsulation,
+	handleCalicoConfig,
+}
+
+// handlerPrerequisites maps the handlers which only apply to an optional component to a check
+// that the component is present. When it is absent, the handler is skipped and recorded as such.
+var handlerPrerequisites = map[string]func(*components) bool{
+	"handleTyphaMetrics":          typhaPresent,
+	"handleTyphaScaling":          typhaPresent,
+	"handleKubeControllersHealth": kubeControllersPresent,
+}
+
+func typhaPresent(c *components) bool {
+	return c.typha != nil
+}
+
+func kubeControllersPresent(c *components) bool {
+	return c.kubeControllers != nil
+}
+
+// handlerName returns the name of the function implementing a handler, e.g. handleNetwork.
+func handlerName(h handler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
 }
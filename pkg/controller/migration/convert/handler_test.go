@@ -0,0 +1,34 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/apis"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+)
+
+var _ = Describe("handlers", func() {
+	It("should each run in isolation against injected components", func() {
+		scheme := kscheme.Scheme
+		Expect(apis.AddToScheme(scheme)).To(Succeed())
+		pool := crdv1.NewIPPool()
+		pool.Spec = crdv1.IPPoolSpec{
+			CIDR:        "192.168.4.0/24",
+			IPIPMode:    crdv1.IPIPModeAlways,
+			NATOutgoing: true,
+		}
+		cli := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
+
+		for _, hdlr := range handlers {
+			name := handlerName(hdlr)
+			comps, err := getComponents(ctx, cli)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hdlr(ctx, comps, &operatorv1.Installation{})).To(Succeed(), name)
+		}
+	})
+})
@@ -0,0 +1,51 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// handleIPAMConfig checks the default IPAMConfig in the datastore.
+// The Installation does not expose any of its settings and the operator does not manage it, so it
+// remains in effect after migration. Any non-default settings are reported so that the user is
+// aware they are not part of the Installation, and are reported as having no effect if the cluster
+// does not use Calico IPAM.
+// It must run after the CNI config has been converted.
+func handleIPAMConfig(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	ipamConfig := crdv1.IPAMConfig{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: "default"}, &ipamConfig); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get IPAMConfig: %v", err)
+	}
+
+	settings := []string{}
+	if ipamConfig.Spec.StrictAffinity {
+		settings = append(settings, "strictAffinity=true")
+	}
+	if !ipamConfig.Spec.AutoAllocateBlocks {
+		settings = append(settings, "autoAllocateBlocks=false")
+	}
+	if ipamConfig.Spec.MaxBlocksPerHost != 0 {
+		settings = append(settings, fmt.Sprintf("maxBlocksPerHost=%d", ipamConfig.Spec.MaxBlocksPerHost))
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+
+	if install.Spec.CNI == nil || install.Spec.CNI.IPAM == nil || install.Spec.CNI.IPAM.Type != operatorv1.IPAMPluginCalico {
+		c.warn(ComponentIPAMConfig, "IPAMConfig settings %s only apply to Calico IPAM, so have no effect with the detected IPAM", strings.Join(settings, ", "))
+		return nil
+	}
+
+	c.warn(ComponentIPAMConfig, "IPAMConfig settings %s can not be configured by the Installation, the IPAMConfig will remain in the datastore unchanged",
+		strings.Join(settings, ", "))
+	return nil
+}
@@ -0,0 +1,82 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/apis"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("IPAMConfig", func() {
+	var (
+		comps  = emptyComponents()
+		i      = &operatorv1.Installation{}
+		scheme *runtime.Scheme
+	)
+
+	BeforeEach(func() {
+		comps = emptyComponents()
+		i = &operatorv1.Installation{
+			Spec: operatorv1.InstallationSpec{
+				CNI: &operatorv1.CNISpec{
+					Type: operatorv1.PluginCalico,
+					IPAM: &operatorv1.IPAMSpec{Type: operatorv1.IPAMPluginCalico},
+				},
+			},
+		}
+		scheme = kscheme.Scheme
+		Expect(apis.AddToScheme(scheme)).ToNot(HaveOccurred())
+		comps.client = fake.NewFakeClientWithScheme(scheme)
+	})
+
+	ipamConfig := func(spec crdv1.IPAMConfigSpec) *crdv1.IPAMConfig {
+		return &crdv1.IPAMConfig{
+			ObjectMeta: v1.ObjectMeta{Name: "default"},
+			Spec:       spec,
+		}
+	}
+
+	It("should not error if there is no IPAMConfig", func() {
+		Expect(handleIPAMConfig(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(BeEmpty())
+	})
+
+	It("should not warn about the default IPAMConfig", func() {
+		comps.client = fake.NewFakeClientWithScheme(scheme, ipamConfig(crdv1.IPAMConfigSpec{AutoAllocateBlocks: true}))
+		Expect(handleIPAMConfig(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(BeEmpty())
+	})
+
+	It("should warn that strictAffinity is left in the datastore", func() {
+		comps.client = fake.NewFakeClientWithScheme(scheme, ipamConfig(crdv1.IPAMConfigSpec{StrictAffinity: true, AutoAllocateBlocks: true}))
+		Expect(handleIPAMConfig(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(ConsistOf(Warning{
+			Component: ComponentIPAMConfig,
+			Message:   "IPAMConfig settings strictAffinity=true can not be configured by the Installation, the IPAMConfig will remain in the datastore unchanged",
+		}))
+	})
+
+	It("should list each non-default setting", func() {
+		comps.client = fake.NewFakeClientWithScheme(scheme, ipamConfig(crdv1.IPAMConfigSpec{StrictAffinity: true, MaxBlocksPerHost: 4}))
+		Expect(handleIPAMConfig(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(HaveLen(1))
+		Expect(comps.report.Warnings[0].Message).To(HavePrefix("IPAMConfig settings strictAffinity=true, autoAllocateBlocks=false, maxBlocksPerHost=4 "))
+	})
+
+	It("should warn that strictAffinity has no effect without Calico IPAM", func() {
+		i.Spec.CNI.IPAM.Type = operatorv1.IPAMPluginHostLocal
+		comps.client = fake.NewFakeClientWithScheme(scheme, ipamConfig(crdv1.IPAMConfigSpec{StrictAffinity: true, AutoAllocateBlocks: true}))
+		Expect(handleIPAMConfig(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(ConsistOf(Warning{
+			Component: ComponentIPAMConfig,
+			Message:   "IPAMConfig settings strictAffinity=true only apply to Calico IPAM, so have no effect with the detected IPAM",
+		}))
+	})
+})
@@ -1,14 +1,20 @@
 package convert
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strings"
 
+	configv1 "github.com/openshift/api/config/v1"
 	operatorv1 "github.com/tigera/operator/api/v1"
 	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+	"github.com/tigera/operator/pkg/controller/utils/podcidr"
 	"github.com/tigera/operator/pkg/render"
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // handleIPPools sets the install.Spec.CalicoNetwork.IPPools field based on the
@@ -17,7 +23,7 @@ import (
 // See selectInitialPool for details on which pool will be selected.
 // Since the operator only supports one v4 and one v6 only one of each will be picked
 // if they exist.
-func handleIPPools(c *components, install *operatorv1.Installation) error {
+func handleIPPools(ctx context.Context, c *components, install *operatorv1.Installation) error {
 	pools := crdv1.IPPoolList{}
 	if err := c.client.List(ctx, &pools); err != nil && !kerrors.IsNotFound(err) {
 		return fmt.Errorf("failed to list IPPools %v", err)
@@ -32,6 +38,29 @@ func handleIPPools(c *components, install *operatorv1.Installation) error {
 	if err != nil {
 		return err
 	}
+
+	// The Installation can only hold one pool of each IP version. Any other pools are left in the
+	// datastore untouched, so warn that they will not be managed by the operator.
+	for _, p := range pools.Items {
+		if (v4pool != nil && p.Name == v4pool.Name) || (v6pool != nil && p.Name == v6pool.Name) {
+			continue
+		}
+		c.warn(ComponentIPPools, "IPPool %s (%s) will not be managed by the operator, it will remain in the datastore unchanged", p.Name, p.Spec.CIDR)
+	}
+
+	// With calico IPAM, assign_ipv6 decides whether pods get IPv6 addresses. The operator only assigns
+	// them when the Installation has an IPv6 pool, so a pool which is not assigned from is left out of it.
+	if v6pool != nil && c.cni.CalicoConfig != nil && c.cni.CalicoConfig.IPAM.Type == "calico-ipam" &&
+		(c.cni.CalicoConfig.IPAM.AssignIpv6 == nil || strings.ToLower(*c.cni.CalicoConfig.IPAM.AssignIpv6) != "true") {
+		c.warn(ComponentIPPools, "IPPool %s (%s) will not be managed by the operator as the CNI config does not set assign_ipv6=true, "+
+			"it will remain in the datastore unchanged", v6pool.Name, v6pool.Spec.CIDR)
+		v6pool = nil
+	}
+
+	v4NodeSelector, err := getPoolNodeSelector(ctx, c, "CALICO_IPV4POOL_NODE_SELECTOR")
+	if err != nil {
+		return err
+	}
 	// Only if there is at least one v4 or v6 pool will we initialize CalicoNetwork
 	if v4pool != nil || v6pool != nil {
 		if install.Spec.CalicoNetwork == nil {
@@ -51,6 +80,14 @@ func handleIPPools(c *components, install *operatorv1.Installation) error {
 					component: ComponentIPPools,
 				}
 			}
+			// the node selector env var is only used when calico-node creates the initial pool,
+			// so only fall back to it if the pool in the datastore does not have one.
+			if pool.NodeSelector == "" && v4NodeSelector != nil {
+				pool.NodeSelector = *v4NodeSelector
+			}
+			if err := checkInitialPoolIPIP(ctx, c, pool); err != nil {
+				return err
+			}
 			install.Spec.CalicoNetwork.IPPools = append(install.Spec.CalicoNetwork.IPPools, pool)
 		}
 
@@ -93,14 +130,6 @@ func handleIPPools(c *components, install *operatorv1.Installation) error {
 					fix:       "create an IPv6 pool or set assign_ipv6=false",
 				}
 			}
-		} else {
-			if v6pool != nil {
-				return ErrIncompatibleCluster{
-					err:       "CNI config indicates assign_ipv6=false but an IPv6 pool was found",
-					component: ComponentCNIConfig,
-					fix:       "delete the IPv6 pool or set assign_ipv6=true",
-				}
-			}
 		}
 	}
 
@@ -111,7 +140,6 @@ func handleIPPools(c *components, install *operatorv1.Installation) error {
 	c.node.ignoreEnv("calico-node", "CALICO_IPV4POOL_IPIP")
 	c.node.ignoreEnv("calico-node", "CALICO_IPV4POOL_VXLAN")
 	c.node.ignoreEnv("calico-node", "CALICO_IPV4POOL_NAT_OUTGOING")
-	c.node.ignoreEnv("calico-node", "CALICO_IPV4POOL_NODE_SELECTOR")
 	// V6
 	c.node.ignoreEnv("calico-node", "CALICO_IPV6POOL_CIDR")
 	c.node.ignoreEnv("calico-node", "CALICO_IPV6POOL_BLOCK_SIZE")
@@ -123,6 +151,318 @@ func handleIPPools(c *components, install *operatorv1.Installation) error {
 	return nil
 }
 
+// checkInitialPoolIPIP compares the encapsulation of the converted IPv4 pool against CALICO_IPV4POOL_IPIP,
+// warning if they differ. The env var is only used when calico-node creates the initial pool, so the pool
+// in the datastore is always the one carried forward.
+func checkInitialPoolIPIP(ctx context.Context, c *components, pool operatorv1.IPPool) error {
+	ipip, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "CALICO_IPV4POOL_IPIP")
+	if err != nil || ipip == nil {
+		return err
+	}
+	encap, err := parseIPIPMode(*ipip)
+	if err != nil {
+		// the env var is not used after migration, so in lenient mode there is nothing to compare.
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       err.Error(),
+			component: ComponentCalicoNode,
+			fix:       "set CALICO_IPV4POOL_IPIP to 'Always', 'CrossSubnet', or 'Never'",
+		})
+	}
+
+	var mismatch bool
+	switch pool.Encapsulation {
+	case operatorv1.EncapsulationVXLAN, operatorv1.EncapsulationVXLANCrossSubnet:
+		// a VXLAN pool is configured with CALICO_IPV4POOL_VXLAN and IPIP disabled.
+		mismatch = encap != operatorv1.EncapsulationNone
+	default:
+		mismatch = encap != pool.Encapsulation
+	}
+	if mismatch {
+		c.warn(ComponentIPPools, "CALICO_IPV4POOL_IPIP=%s does not match the %s encapsulation of IPPool %s, the IPPool's encapsulation will be used",
+			*ipip, pool.Encapsulation, pool.CIDR)
+	}
+	return nil
+}
+
+// parseIPIPMode converts a CALICO_IPV4POOL_IPIP value into the equivalent encapsulation.
+// Very old manifests used the boolean spellings 'true' and 'false' in place of 'Always' and 'Never'.
+func parseIPIPMode(mode string) (operatorv1.EncapsulationType, error) {
+	switch strings.ToLower(mode) {
+	case "", "off", "never", "false":
+		return operatorv1.EncapsulationNone, nil
+	case "always", "true":
+		return operatorv1.EncapsulationIPIP, nil
+	case "crosssubnet", "cross-subnet":
+		return operatorv1.EncapsulationIPIPCrossSubnet, nil
+	}
+	return "", fmt.Errorf("CALICO_IPV4POOL_IPIP=%s is not a valid IPIP mode", mode)
+}
+
+// handleDualStack is a migration handler which decides whether the existing install is dual-stack, so that
+// the handlers which depend on it all make the same assumption. It must run before any of those handlers.
+func handleDualStack(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	kubeadmConfig, err := getKubeadmConfig(ctx, c)
+	if err != nil {
+		return err
+	}
+	c.dualStack = detectDualStack(c, kubeadmConfig)
+	return nil
+}
+
+// detectDualStack returns true if any of the signals of a dual-stack install are present: initial pool CIDRs
+// for both IP families, address autodetection methods for both IP families, or kubeadm pod network CIDRs for
+// both IP families. kubeadmConfig may be nil if the cluster was not installed with kubeadm.
+// The env vars are only checked for presence, so are not marked as checked.
+func detectDualStack(c *components, kubeadmConfig *corev1.ConfigMap) bool {
+	if nodeEnvSet(c, "CALICO_IPV4POOL_CIDR") && nodeEnvSet(c, "CALICO_IPV6POOL_CIDR") {
+		return true
+	}
+	if nodeEnvSet(c, "IP_AUTODETECTION_METHOD") && nodeEnvSet(c, "IP6_AUTODETECTION_METHOD") {
+		return true
+	}
+	if kubeadmConfig != nil {
+		var v4, v6 bool
+		// an unparseable podSubnet has no CIDRs to detect dual-stack from.
+		cidrs, _ := podcidr.ExtractKubeadmCIDRs(kubeadmConfig)
+		for _, cidr := range cidrs {
+			if podcidr.IsIPv6CIDR(cidr) {
+				v6 = true
+			} else {
+				v4 = true
+			}
+		}
+		if v4 && v6 {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeEnvSet returns true if the calico-node container sets the given env var to a value or a reference.
+func nodeEnvSet(c *components, key string) bool {
+	container := getContainer(c.node.Spec.Template.Spec, containerCalicoNode)
+	if container == nil {
+		return false
+	}
+	for _, e := range container.Env {
+		if e.Name == key {
+			return e.Value != "" || e.ValueFrom != nil
+		}
+	}
+	return false
+}
+
+// handleDualStackAutodetection is a migration handler which checks that a dual-stack install, i.e. one detected
+// as dual-stack or with both an IPv4 and an IPv6 pool, configures address autodetection for both IP families or for neither.
+// If only one is configured, the other would silently fall back to the operator's default.
+// It must run after the IP pools have been converted.
+func handleDualStackAutodetection(_ context.Context, c *components, install *operatorv1.Installation) error {
+	cn := install.Spec.CalicoNetwork
+	if cn == nil {
+		return nil
+	}
+	if !c.dualStack && (render.GetIPv4Pool(cn.IPPools) == nil || render.GetIPv6Pool(cn.IPPools) == nil) {
+		return nil
+	}
+
+	switch {
+	case cn.NodeAddressAutodetectionV4 != nil && cn.NodeAddressAutodetectionV6 == nil:
+		return ErrIncompatibleCluster{
+			err:       "the install is dual-stack, but only IPv4 address autodetection is configured",
+			component: ComponentCalicoNode,
+			fix:       "set IP6_AUTODETECTION_METHOD, or remove IP_AUTODETECTION_METHOD",
+		}
+	case cn.NodeAddressAutodetectionV4 == nil && cn.NodeAddressAutodetectionV6 != nil:
+		return ErrIncompatibleCluster{
+			err:       "the install is dual-stack, but only IPv6 address autodetection is configured",
+			component: ComponentCalicoNode,
+			fix:       "set IP_AUTODETECTION_METHOD, or remove IP6_AUTODETECTION_METHOD",
+		}
+	}
+	return nil
+}
+
+// handlePlatformPodCIDRs is a migration handler which checks the initial pool CIDR env vars and the converted
+// pools against the pod network CIDRs configured in the platform (kubeadm or OpenShift). With Calico CNI, the
+// operator merges in the platform CIDRs after conversion and rejects pools outside of them, so this is checked
+// here to give a clear error which names where the conflicting CIDR came from. The env vars are checked even
+// though the existing pools take precedence, since a CIDR outside of the platform's is a misconfiguration which
+// would be silently dropped by the migration.
+// On AKS, which does not expose the cluster's pod network CIDR, the pools are instead checked against the pod
+// CIDRs allocated to the nodes.
+// It must run after the IP pools have been converted.
+func handlePlatformPodCIDRs(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	if install.Spec.CNI != nil && install.Spec.CNI.Type != operatorv1.PluginCalico {
+		return nil
+	}
+	if c.provider == operatorv1.ProviderAKS {
+		return checkAKSNodePodCIDRs(ctx, c, install)
+	}
+	platformCIDRs, source, err := getPlatformPodCIDRs(ctx, c)
+	if err != nil || len(platformCIDRs) == 0 {
+		return err
+	}
+
+	for _, key := range []string{"CALICO_IPV4POOL_CIDR", "CALICO_IPV6POOL_CIDR"} {
+		cidr, err := c.node.getEnv(ctx, c.client, containerCalicoNode, key)
+		if err != nil {
+			return err
+		}
+		if cidr == nil {
+			continue
+		}
+		if err := checkWithinPlatformCIDRs(key+"="+*cidr, *cidr, platformCIDRs, source); err != nil {
+			if err := c.incompatible(ErrIncompatibleCluster{
+				err:       err.Error(),
+				component: ComponentCalicoNode,
+				fix:       fmt.Sprintf("set %s to a CIDR within %v or remove the env var", key, platformCIDRs),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if install.Spec.CalicoNetwork == nil {
+		return nil
+	}
+	for _, pool := range install.Spec.CalicoNetwork.IPPools {
+		if err := checkWithinPlatformCIDRs("IPPool "+pool.CIDR, pool.CIDR, platformCIDRs, source); err != nil {
+			if err := c.incompatible(ErrIncompatibleCluster{
+				err:       err.Error(),
+				component: ComponentIPPools,
+				fix:       fmt.Sprintf("update the %s to include the IPPool", platformCIDRConfig[source]),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkAKSNodePodCIDRs checks that the IP pools contain the pod CIDRs allocated to the AKS nodes when pod IPs
+// are assigned from them with host-local IPAM. The node CIDRs are only a part of the cluster's pod network
+// CIDR, so they are checked to be within the pools rather than the other way around.
+func checkAKSNodePodCIDRs(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	// AKS defaults to the Azure CNI plugin, so only check an explicitly configured Calico CNI.
+	if install.Spec.CNI == nil || install.Spec.CNI.Type != operatorv1.PluginCalico {
+		return nil
+	}
+	if install.Spec.CNI.IPAM == nil || install.Spec.CNI.IPAM.Type != operatorv1.IPAMPluginHostLocal {
+		return nil
+	}
+	if install.Spec.CalicoNetwork == nil || len(install.Spec.CalicoNetwork.IPPools) == 0 {
+		return nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := c.client.List(ctx, nodes); err != nil {
+		return fmt.Errorf("failed to list nodes: %v", err)
+	}
+	nodeCIDRs, err := podcidr.ExtractAKSCIDRs(nodes)
+	if err != nil {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("failed to read the AKS node pod CIDR(s): %v", err),
+			component: ComponentIPPools,
+		})
+	}
+	for _, nodeCIDR := range nodeCIDRs {
+		within := false
+		for _, pool := range install.Spec.CalicoNetwork.IPPools {
+			within = within || podcidr.CIDRWithinCIDR(pool.CIDR, nodeCIDR)
+		}
+		if !within {
+			if err := c.incompatible(ErrIncompatibleCluster{
+				err:       fmt.Sprintf("AKS node pod CIDR %s is not within any IPPool", nodeCIDR),
+				component: ComponentIPPools,
+				fix:       "create an IPPool which includes the pod CIDRs allocated to the nodes",
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// platformCIDRConfig names where the pod network CIDRs of each platform are configured.
+var platformCIDRConfig = map[string]string{
+	"kubeadm":   "podSubnet in the kubeadm configuration",
+	"OpenShift": "clusterNetwork in the OpenShift network configuration",
+}
+
+// checkWithinPlatformCIDRs returns an error if the cidr is not within one of the platform's pod network CIDRs of
+// the same IP family. desc names the cidr in the error and source names the platform the CIDRs were read from.
+func checkWithinPlatformCIDRs(desc, cidr string, platformCIDRs []string, source string) error {
+	if podcidr.CheckPoolWithinCIDRs(cidr, platformCIDRs) == nil {
+		return nil
+	}
+	for _, p := range platformCIDRs {
+		if podcidr.IsIPv6CIDR(p) == podcidr.IsIPv6CIDR(cidr) {
+			return fmt.Errorf("%s is not within the %s pod network CIDR(s) %v", desc, source, platformCIDRs)
+		}
+	}
+	return fmt.Errorf("%s is an %s CIDR but the %s pod network CIDR(s) %v have no %s CIDR",
+		desc, podcidr.Family(cidr), source, platformCIDRs, podcidr.Family(cidr))
+}
+
+// getPlatformPodCIDRs returns the pod network CIDRs configured in the platform along with the name of the
+// platform they were read from. No CIDRs are returned if the platform does not configure the pod network CIDRs.
+func getPlatformPodCIDRs(ctx context.Context, c *components) ([]string, string, error) {
+	if c.provider == operatorv1.ProviderOpenShift {
+		network := &configv1.Network{}
+		if err := c.client.Get(ctx, types.NamespacedName{Name: "cluster"}, network); err != nil {
+			if kerrors.IsNotFound(err) {
+				return nil, "", nil
+			}
+			return nil, "", fmt.Errorf("failed to get the OpenShift network configuration: %v", err)
+		}
+		cidrs := []string{}
+		for _, n := range network.Spec.ClusterNetwork {
+			cidrs = append(cidrs, n.CIDR)
+		}
+		return cidrs, "OpenShift", nil
+	}
+
+	kubeadmConfig, err := getKubeadmConfig(ctx, c)
+	if err != nil || kubeadmConfig == nil {
+		return nil, "", err
+	}
+	cidrs, err := podcidr.ExtractKubeadmCIDRs(kubeadmConfig)
+	if err != nil {
+		return nil, "", c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("failed to read the kubeadm pod network CIDR(s): %v", err),
+			component: ComponentIPPools,
+			fix:       "set the podSubnet in the kubeadm configuration to include the IPPools",
+		})
+	}
+	return cidrs, "kubeadm", nil
+}
+
+// getKubeadmConfig returns the kubeadm-config ConfigMap, or nil if the cluster was not installed with kubeadm.
+func getKubeadmConfig(ctx context.Context, c *components) (*corev1.ConfigMap, error) {
+	kubeadmConfig := &corev1.ConfigMap{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: "kubeadm-config", Namespace: metav1.NamespaceSystem}, kubeadmConfig); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get kubeadm config: %v", err)
+	}
+	return kubeadmConfig, nil
+}
+
+// getPoolNodeSelector reads the node selector for the initial pool from the given env var on calico-node.
+// If the env var is not set or is empty, nil is returned. The selector is carried forward as is, it is
+// validated by Calico when the operator creates the pool.
+func getPoolNodeSelector(ctx context.Context, c *components, key string) (*string, error) {
+	sel, err := c.node.getEnv(ctx, c.client, containerCalicoNode, key)
+	if err != nil || sel == nil {
+		return nil, err
+	}
+	if trimmed := strings.TrimSpace(*sel); trimmed != "" {
+		return &trimmed, nil
+	}
+	return nil, nil
+}
+
 // getIPPools searches through the pools passed in using the matcher function passed in to see if the pool
 // should be selected, the first pool that the matcher returns true on is returned.
 // If there is an error returned from the matcher then that error is returned.
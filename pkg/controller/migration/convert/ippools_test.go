@@ -8,11 +8,13 @@ import (
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
+	configv1 "github.com/openshift/api/config/v1"
 	operatorv1 "github.com/tigera/operator/api/v1"
 	"github.com/tigera/operator/pkg/apis"
 	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	kscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -91,7 +93,7 @@ var _ = Describe("Convert network tests", func() {
 				Value: `{"type": "calico", "name": "k8s-pod-network", "ipam": {"type": "calico-ipam"}}`,
 			}}
 			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, emptyFelixConfig())
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(cfg.Spec.CalicoNetwork.IPPools).To(Equal([]operatorv1.IPPool{{
 				CIDR:          "1.168.4.0/24",
@@ -109,7 +111,7 @@ var _ = Describe("Convert network tests", func() {
 			}
 
 			c := fake.NewFakeClientWithScheme(scheme, ds, emptyFelixConfig())
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 		})
 		DescribeTable("should pick v4 default pool", func(envcidr, expectcidr string) {
@@ -123,7 +125,7 @@ var _ = Describe("Convert network tests", func() {
 				Value: envcidr,
 			}}
 			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, v4pool2, v4pooldefault, emptyFelixConfig())
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(cfg.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
 			Expect(cfg.Spec.CalicoNetwork.IPPools[0].Encapsulation).To(Equal(operatorv1.EncapsulationIPIP))
@@ -145,7 +147,7 @@ var _ = Describe("Convert network tests", func() {
 				Value: envcidr,
 			}}
 			c := fake.NewFakeClientWithScheme(scheme, ds, v6pool1, v6pool2, v6pooldefault, emptyFelixConfig())
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(cfg.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
 			Expect(cfg.Spec.CalicoNetwork.IPPools[0].Encapsulation).To(Equal(operatorv1.EncapsulationNone))
@@ -155,6 +157,25 @@ var _ = Describe("Convert network tests", func() {
 			Entry("find default pool even when CIDR suggests other", "ff00:0001::/24", "ff00:0003::/24"),
 			Entry("find default pool", "ff00:0003::/24", "ff00:0003::/24"),
 		)
+		DescribeTable("should carry the v4 node selector env var", func(env, crdSelector, expectSelector string) {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+				Name:  "CALICO_IPV4POOL_NODE_SELECTOR",
+				Value: env,
+			}}
+			v4pooldefault.Spec.NodeSelector = crdSelector
+			c := fake.NewFakeClientWithScheme(scheme, ds, v4pooldefault, emptyFelixConfig())
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
+			Expect(cfg.Spec.CalicoNetwork.IPPools[0].NodeSelector).To(Equal(expectSelector))
+		},
+			Entry("default selector", "all()", "", "all()"),
+			Entry("custom selector", `zone == "us-east-1a"`, "", `zone == "us-east-1a"`),
+			Entry("custom selector with whitespace", ` zone == "us-east-1a" `, "", `zone == "us-east-1a"`),
+			Entry("empty selector is left for the defaults", "", "", ""),
+			Entry("selector from the datastore takes precedence", `zone == "us-east-1a"`, `zone == "us-east-1b"`, `zone == "us-east-1b"`),
+		)
 		It("should error on bad pool CIDR", func() {
 			ds := emptyNodeSpec()
 			ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
@@ -163,7 +184,7 @@ var _ = Describe("Convert network tests", func() {
 			}}
 			v4pool1.Spec.CIDR = "1.168.0/24"
 			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1)
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).To(HaveOccurred())
 		})
 		It("should ignore disabled pools", func() {
@@ -179,7 +200,7 @@ var _ = Describe("Convert network tests", func() {
 			}}
 			v4pooldefault.Spec.Disabled = true
 			c := fake.NewFakeClientWithScheme(scheme, ds, v4pooldefault, v4pool2, emptyFelixConfig())
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(cfg.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
 			Expect(cfg.Spec.CalicoNetwork.IPPools[0].CIDR).To(Equal("2.168.4.0/24"))
@@ -191,7 +212,7 @@ var _ = Describe("Convert network tests", func() {
 				Value: `{"type": "calico", "name": "k8s-pod-network", "ipam": {"type": "calico-ipam", "assign_ipv6":"true"}}`,
 			}}
 			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, v6pool1, emptyFelixConfig())
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(cfg.Spec.CalicoNetwork.IPPools).To(ConsistOf([]operatorv1.IPPool{{
 				CIDR:          "1.168.4.0/24",
@@ -203,6 +224,396 @@ var _ = Describe("Convert network tests", func() {
 				NATOutgoing:   operatorv1.NATOutgoingEnabled,
 			}}))
 		})
+		It("should warn about pools the operator will not manage", func() {
+			ds := emptyNodeSpec()
+			c := fake.NewFakeClientWithScheme(scheme, ds, v4pooldefault, v4pool1, emptyFelixConfig())
+			cfg, report, err := ConvertWithReport(ctx, c, Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
+			Expect(cfg.Spec.CalicoNetwork.IPPools[0].CIDR).To(Equal("3.168.4.0/24"))
+			Expect(report.Warnings).To(ContainElement(Warning{
+				Component: ComponentIPPools,
+				Message:   "IPPool not-default (1.168.4.0/24) will not be managed by the operator, it will remain in the datastore unchanged",
+			}))
+			for _, w := range report.Warnings {
+				Expect(w.Message).ToNot(ContainSubstring("default-ipv4-pool"))
+			}
+		})
+		It("should error if a dual-stack install only configures v4 autodetection", func() {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+				Name:  "CNI_NETWORK_CONFIG",
+				Value: `{"type": "calico", "name": "k8s-pod-network", "ipam": {"type": "calico-ipam", "assign_ipv6":"true"}}`,
+			}}
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+				Name:  "IP_AUTODETECTION_METHOD",
+				Value: "interface=eth0",
+			}}
+			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, v6pool1, emptyFelixConfig())
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("only IPv4 address autodetection is configured"))
+		})
+		It("should error if a dual-stack install only configures v6 autodetection", func() {
+			first := true
+			i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+				IPPools:                    []operatorv1.IPPool{{CIDR: "1.168.4.0/24"}, {CIDR: "ff00:0001::/24"}},
+				NodeAddressAutodetectionV6: &operatorv1.NodeAddressAutodetection{FirstFound: &first},
+			}}}
+			comps := emptyComponents()
+			err := handleDualStackAutodetection(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("only IPv6 address autodetection is configured"))
+		})
+		DescribeTable("should parse CALICO_IPV4POOL_IPIP", func(mode string, expected operatorv1.EncapsulationType) {
+			encap, err := parseIPIPMode(mode)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(encap).To(Equal(expected))
+		},
+			Entry("Always", "Always", operatorv1.EncapsulationIPIP),
+			Entry("CrossSubnet", "CrossSubnet", operatorv1.EncapsulationIPIPCrossSubnet),
+			Entry("Never", "Never", operatorv1.EncapsulationNone),
+			Entry("off", "off", operatorv1.EncapsulationNone),
+			Entry("legacy true", "true", operatorv1.EncapsulationIPIP),
+			Entry("legacy false", "false", operatorv1.EncapsulationNone),
+			Entry("legacy True", "True", operatorv1.EncapsulationIPIP),
+		)
+		It("should accept a legacy boolean CALICO_IPV4POOL_IPIP matching the pool", func() {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_IPIP", Value: "true"}}
+			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, emptyFelixConfig())
+			cfg, report, err := ConvertWithReport(ctx, c, Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Spec.CalicoNetwork.IPPools[0].Encapsulation).To(Equal(operatorv1.EncapsulationIPIP))
+			for _, w := range report.Warnings {
+				Expect(w.Message).NotTo(ContainSubstring("CALICO_IPV4POOL_IPIP"))
+			}
+		})
+		It("should warn if a legacy boolean CALICO_IPV4POOL_IPIP does not match the pool", func() {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_IPIP", Value: "false"}}
+			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, emptyFelixConfig())
+			cfg, report, err := ConvertWithReport(ctx, c, Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Spec.CalicoNetwork.IPPools[0].Encapsulation).To(Equal(operatorv1.EncapsulationIPIP))
+			Expect(report.Warnings).To(ContainElement(Warning{
+				Component: ComponentIPPools,
+				Message:   "CALICO_IPV4POOL_IPIP=false does not match the IPIP encapsulation of IPPool 1.168.4.0/24, the IPPool's encapsulation will be used",
+			}))
+		})
+		It("should error on an invalid CALICO_IPV4POOL_IPIP", func() {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_IPIP", Value: "yes"}}
+			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, emptyFelixConfig())
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("CALICO_IPV4POOL_IPIP=yes is not a valid IPIP mode"))
+		})
+		It("should use the pool's encapsulation for an invalid CALICO_IPV4POOL_IPIP when lenient", func() {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_IPIP", Value: "yes"}}
+			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, emptyFelixConfig())
+			cfg, report, err := ConvertWithReport(ctx, c, Options{Mode: ModeLenient})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Spec.CalicoNetwork.IPPools[0].Encapsulation).To(Equal(operatorv1.EncapsulationIPIP))
+			Expect(report.ManualSteps).To(ContainElement(ManualStep{
+				Component: ComponentCalicoNode,
+				Issue:     "CALICO_IPV4POOL_IPIP=yes is not a valid IPIP mode",
+				Step:      "set CALICO_IPV4POOL_IPIP to 'Always', 'CrossSubnet', or 'Never'",
+			}))
+		})
+		Context("dual-stack detection", func() {
+			kubeadmConfig := func(podSubnet string) *corev1.ConfigMap {
+				return &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "kubeadm-config", Namespace: "kube-system"},
+					Data:       map[string]string{"ClusterConfiguration": "networking:\n  podSubnet: " + podSubnet + "\n"},
+				}
+			}
+
+			DescribeTable("should consolidate the dual-stack signals", func(env []corev1.EnvVar, cfg *corev1.ConfigMap, expected bool) {
+				comps := emptyComponents()
+				comps.node.Spec.Template.Spec.Containers[0].Env = env
+				Expect(detectDualStack(&comps, cfg)).To(Equal(expected))
+				Expect(comps.node.checkedVars).To(BeEmpty())
+			},
+				Entry("no signals", nil, nil, false),
+				Entry("only a v4 pool env var", []corev1.EnvVar{{Name: "CALICO_IPV4POOL_CIDR", Value: "192.168.0.0/16"}}, nil, false),
+				Entry("both pool env vars", []corev1.EnvVar{
+					{Name: "CALICO_IPV4POOL_CIDR", Value: "192.168.0.0/16"},
+					{Name: "CALICO_IPV6POOL_CIDR", Value: "fd00::/48"},
+				}, nil, true),
+				Entry("an empty v6 pool env var", []corev1.EnvVar{
+					{Name: "CALICO_IPV4POOL_CIDR", Value: "192.168.0.0/16"},
+					{Name: "CALICO_IPV6POOL_CIDR", Value: ""},
+				}, nil, false),
+				Entry("only a v6 autodetection method", []corev1.EnvVar{{Name: "IP6_AUTODETECTION_METHOD", Value: "first-found"}}, nil, false),
+				Entry("both autodetection methods", []corev1.EnvVar{
+					{Name: "IP_AUTODETECTION_METHOD", Value: "interface=eth0"},
+					{Name: "IP6_AUTODETECTION_METHOD", Value: "first-found"},
+				}, nil, true),
+				Entry("a single-stack platform CIDR", nil, kubeadmConfig("192.168.0.0/16"), false),
+				Entry("dual-stack platform CIDRs", nil, kubeadmConfig("192.168.0.0/16,fd00::/48"), true),
+				Entry("a v4 pool env var and a v6 autodetection method", []corev1.EnvVar{
+					{Name: "CALICO_IPV4POOL_CIDR", Value: "192.168.0.0/16"},
+					{Name: "IP6_AUTODETECTION_METHOD", Value: "first-found"},
+				}, kubeadmConfig("192.168.0.0/16"), false),
+			)
+
+			It("should require IPv6 to be enabled on a dual-stack install", func() {
+				comps := emptyComponents()
+				comps.client = fake.NewFakeClientWithScheme(scheme, kubeadmConfig("192.168.0.0/16,fd00::/48"))
+				comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+					{Name: "FELIX_IPV6SUPPORT", Value: "true"},
+					{Name: "IP6", Value: "autodetect"},
+				}
+				i := &operatorv1.Installation{}
+				Expect(handleDualStack(ctx, &comps, i)).To(Succeed())
+				Expect(comps.dualStack).To(BeTrue())
+				Expect(handleIPv6(ctx, &comps, i)).To(Succeed())
+
+				comps.node.Spec.Template.Spec.Containers[0].Env[1].Value = "none"
+				err := handleIPv6(ctx, &comps, i)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("IP6=none is not supported"))
+			})
+
+			It("should convert a dual-stack install which sets both autodetection methods", func() {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{
+					{Name: "FELIX_IPV6SUPPORT", Value: "true"},
+					{Name: "IP6", Value: "autodetect"},
+					{Name: "IP_AUTODETECTION_METHOD", Value: "interface=eth0"},
+					{Name: "IP6_AUTODETECTION_METHOD", Value: "interface=eth0"},
+				}
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name:  "CNI_NETWORK_CONFIG",
+					Value: `{"type": "calico", "name": "k8s-pod-network", "ipam": {"type": "calico-ipam", "assign_ipv4": "true", "assign_ipv6": "true"}}`,
+				}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, v6pool1, emptyFelixConfig())
+				cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Spec.CalicoNetwork.NodeAddressAutodetectionV4).To(Equal(&operatorv1.NodeAddressAutodetection{Interface: "eth0"}))
+				Expect(cfg.Spec.CalicoNetwork.NodeAddressAutodetectionV6).To(Equal(&operatorv1.NodeAddressAutodetection{Interface: "eth0"}))
+			})
+
+			It("should check autodetection for both families on a dual-stack install with one pool", func() {
+				first := true
+				i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					IPPools:                    []operatorv1.IPPool{{CIDR: "1.168.4.0/24"}},
+					NodeAddressAutodetectionV4: &operatorv1.NodeAddressAutodetection{FirstFound: &first},
+				}}}
+				comps := emptyComponents()
+				Expect(handleDualStackAutodetection(ctx, &comps, i)).To(Succeed())
+				comps.dualStack = true
+				err := handleDualStackAutodetection(ctx, &comps, i)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("only IPv4 address autodetection is configured"))
+			})
+		})
+		Context("with a kubeadm pod network CIDR", func() {
+			kubeadmConfig := func(podSubnet string) *corev1.ConfigMap {
+				return &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "kubeadm-config", Namespace: "kube-system"},
+					Data:       map[string]string{"ClusterConfiguration": "networking:\n  podSubnet: " + podSubnet + "\n"},
+				}
+			}
+
+			It("should accept a pool and env var within the platform CIDR", func() {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_CIDR", Value: "1.168.4.0/24"}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, emptyFelixConfig(), kubeadmConfig("1.168.0.0/16"))
+				cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Spec.CalicoNetwork.IPPools[0].CIDR).To(Equal("1.168.4.0/24"))
+			})
+
+			It("should error on an env var CIDR outside the platform CIDR", func() {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_CIDR", Value: "10.0.0.0/16"}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, emptyFelixConfig(), kubeadmConfig("1.168.0.0/16"))
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("CALICO_IPV4POOL_CIDR=10.0.0.0/16 is not within the kubeadm pod network CIDR(s) [1.168.0.0/16]"))
+			})
+
+			It("should error on a pool outside the platform CIDR", func() {
+				ds := emptyNodeSpec()
+				c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, emptyFelixConfig(), kubeadmConfig("10.0.0.0/8"))
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("IPPool 1.168.4.0/24 is not within the kubeadm pod network CIDR(s) [10.0.0.0/8]"))
+			})
+
+			It("should warn on a pool outside the platform CIDR when lenient", func() {
+				comps := emptyComponents()
+				comps.mode = ModeLenient
+				comps.client = fake.NewFakeClientWithScheme(scheme, kubeadmConfig("10.0.0.0/8"))
+				i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					IPPools: []operatorv1.IPPool{{CIDR: "1.168.4.0/24"}},
+				}}}
+				Expect(handlePlatformPodCIDRs(ctx, &comps, i)).To(Succeed())
+				Expect(comps.report.ManualSteps).To(HaveLen(1))
+				Expect(comps.report.ManualSteps[0].Issue).To(ContainSubstring("IPPool 1.168.4.0/24 is not within"))
+			})
+
+			It("should error on a pool with no platform CIDR of its family", func() {
+				comps := emptyComponents()
+				comps.client = fake.NewFakeClientWithScheme(scheme, kubeadmConfig("1.168.0.0/16"))
+				i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					IPPools: []operatorv1.IPPool{{CIDR: "1.168.4.0/24"}, {CIDR: "ff00:0001::/24"}},
+				}}}
+				err := handlePlatformPodCIDRs(ctx, &comps, i)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("IPPool ff00:0001::/24 is an IPv6 CIDR but the kubeadm pod network CIDR(s) [1.168.0.0/16] have no IPv6 CIDR"))
+			})
+
+			It("should not check the pools when not using Calico CNI", func() {
+				comps := emptyComponents()
+				comps.client = fake.NewFakeClientWithScheme(scheme, kubeadmConfig("10.0.0.0/8"))
+				i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{
+					CNI: &operatorv1.CNISpec{Type: operatorv1.PluginAmazonVPC},
+					CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+						IPPools: []operatorv1.IPPool{{CIDR: "1.168.4.0/24"}},
+					},
+				}}
+				Expect(handlePlatformPodCIDRs(ctx, &comps, i)).To(Succeed())
+			})
+
+			It("should check each pool of a dual-stack install against its own family", func() {
+				comps := emptyComponents()
+				comps.client = fake.NewFakeClientWithScheme(scheme, kubeadmConfig("1.168.0.0/16,ff00::/16"))
+				i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					IPPools: []operatorv1.IPPool{{CIDR: "1.168.4.0/24"}, {CIDR: "ff00:0001::/24"}},
+				}}}
+				Expect(handlePlatformPodCIDRs(ctx, &comps, i)).To(Succeed())
+			})
+
+			It("should error on an IPv6 env var CIDR outside the platform CIDR", func() {
+				comps := emptyComponents()
+				comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV6POOL_CIDR", Value: "fd00:1234::/48"}}
+				comps.client = fake.NewFakeClientWithScheme(scheme, kubeadmConfig("1.168.0.0/16,ff00::/16"))
+				err := handlePlatformPodCIDRs(ctx, &comps, &operatorv1.Installation{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("CALICO_IPV6POOL_CIDR=fd00:1234::/48 is not within the kubeadm pod network CIDR(s) [1.168.0.0/16 ff00::/16]"))
+			})
+
+			It("should record a manual step for an env var CIDR outside the platform CIDR when lenient", func() {
+				comps := emptyComponents()
+				comps.mode = ModeLenient
+				comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_CIDR", Value: "10.0.0.0/16"}}
+				comps.client = fake.NewFakeClientWithScheme(scheme, kubeadmConfig("1.168.0.0/16"))
+				i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					IPPools: []operatorv1.IPPool{{CIDR: "1.168.4.0/24"}},
+				}}}
+				Expect(handlePlatformPodCIDRs(ctx, &comps, i)).To(Succeed())
+				Expect(comps.report.ManualSteps).To(HaveLen(1))
+				Expect(comps.report.ManualSteps[0].Component).To(Equal(ComponentCalicoNode))
+				Expect(comps.report.ManualSteps[0].Issue).To(ContainSubstring("CALICO_IPV4POOL_CIDR=10.0.0.0/16 is not within"))
+			})
+
+			It("should not check the env var when not using Calico CNI", func() {
+				comps := emptyComponents()
+				comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_CIDR", Value: "10.0.0.0/16"}}
+				comps.client = fake.NewFakeClientWithScheme(scheme, kubeadmConfig("1.168.0.0/16"))
+				i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CNI: &operatorv1.CNISpec{Type: operatorv1.PluginAmazonVPC}}}
+				Expect(handlePlatformPodCIDRs(ctx, &comps, i)).To(Succeed())
+			})
+		})
+		Context("with an OpenShift cluster network", func() {
+			openshiftNetwork := func(cidrs ...string) *configv1.Network {
+				n := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+				for _, cidr := range cidrs {
+					n.Spec.ClusterNetwork = append(n.Spec.ClusterNetwork, configv1.ClusterNetworkEntry{CIDR: cidr})
+				}
+				return n
+			}
+
+			It("should accept an env var and pool within the cluster network", func() {
+				comps := emptyComponents()
+				comps.provider = operatorv1.ProviderOpenShift
+				comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_CIDR", Value: "10.128.0.0/14"}}
+				comps.client = fake.NewFakeClientWithScheme(scheme, openshiftNetwork("10.128.0.0/14"))
+				i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					IPPools: []operatorv1.IPPool{{CIDR: "10.128.0.0/16"}},
+				}}}
+				Expect(handlePlatformPodCIDRs(ctx, &comps, i)).To(Succeed())
+			})
+
+			It("should error on an env var CIDR outside the cluster network", func() {
+				comps := emptyComponents()
+				comps.provider = operatorv1.ProviderOpenShift
+				comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "CALICO_IPV4POOL_CIDR", Value: "192.168.0.0/16"}}
+				comps.client = fake.NewFakeClientWithScheme(scheme, openshiftNetwork("10.128.0.0/14"))
+				err := handlePlatformPodCIDRs(ctx, &comps, &operatorv1.Installation{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("CALICO_IPV4POOL_CIDR=192.168.0.0/16 is not within the OpenShift pod network CIDR(s) [10.128.0.0/14]"))
+			})
+
+			It("should error on a pool outside the cluster network", func() {
+				comps := emptyComponents()
+				comps.provider = operatorv1.ProviderOpenShift
+				comps.client = fake.NewFakeClientWithScheme(scheme, openshiftNetwork("10.128.0.0/14"))
+				i := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					IPPools: []operatorv1.IPPool{{CIDR: "192.168.0.0/16"}},
+				}}}
+				err := handlePlatformPodCIDRs(ctx, &comps, i)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("IPPool 192.168.0.0/16 is not within the OpenShift pod network CIDR(s) [10.128.0.0/14]"))
+			})
+		})
+		Context("with AKS node pod CIDRs", func() {
+			var comps components
+			var i *operatorv1.Installation
+
+			aksNode := func(name, cidr string) *corev1.Node {
+				return &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   name,
+						Labels: map[string]string{"kubernetes.azure.com/cluster": "MC_rg_cluster_eastus"},
+					},
+					Spec: corev1.NodeSpec{PodCIDR: cidr},
+				}
+			}
+
+			BeforeEach(func() {
+				comps = emptyComponents()
+				comps.provider = operatorv1.ProviderAKS
+				comps.client = fake.NewFakeClientWithScheme(scheme, aksNode("aks-nodepool1-0", "10.244.0.0/24"), aksNode("aks-nodepool1-1", "10.244.1.0/24"))
+				i = &operatorv1.Installation{Spec: operatorv1.InstallationSpec{
+					CNI: &operatorv1.CNISpec{
+						Type: operatorv1.PluginCalico,
+						IPAM: &operatorv1.IPAMSpec{Type: operatorv1.IPAMPluginHostLocal},
+					},
+					CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+						IPPools: []operatorv1.IPPool{{CIDR: "10.244.0.0/16"}},
+					},
+				}}
+			})
+
+			It("should accept pools containing the node pod CIDRs", func() {
+				Expect(handlePlatformPodCIDRs(ctx, &comps, i)).To(Succeed())
+			})
+
+			It("should error if a node pod CIDR is outside of the pools", func() {
+				i.Spec.CalicoNetwork.IPPools[0].CIDR = "10.244.1.0/24"
+				err := handlePlatformPodCIDRs(ctx, &comps, i)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("AKS node pod CIDR 10.244.0.0/24 is not within any IPPool"))
+			})
+
+			It("should record a manual step for a node pod CIDR outside of the pools when lenient", func() {
+				comps.mode = ModeLenient
+				i.Spec.CalicoNetwork.IPPools[0].CIDR = "10.244.1.0/24"
+				Expect(handlePlatformPodCIDRs(ctx, &comps, i)).To(Succeed())
+				Expect(comps.report.ManualSteps).To(HaveLen(1))
+			})
+
+			It("should skip the check with Calico IPAM", func() {
+				i.Spec.CNI.IPAM.Type = operatorv1.IPAMPluginCalico
+				i.Spec.CalicoNetwork.IPPools[0].CIDR = "192.168.0.0/16"
+				Expect(handlePlatformPodCIDRs(ctx, &comps, i)).To(Succeed())
+			})
+		})
 		DescribeTable("should block mismatch of pools and assign_ip*", func(assigns string, cidrs ...string) {
 			ds := emptyNodeSpec()
 			ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
@@ -221,7 +632,7 @@ var _ = Describe("Convert network tests", func() {
 				pools = append(pools, p)
 			}
 			c := fake.NewFakeClientWithScheme(scheme, append([]runtime.Object{ds}, pools...)...)
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).To(HaveOccurred())
 		},
 			Entry("v4 pool but no assigning v4", `"assign_ipv4": "false"`, "1.168.4.0/24"),
@@ -230,7 +641,26 @@ var _ = Describe("Convert network tests", func() {
 			Entry("v4 pool but assign v6 and v4", `"assign_ipv4": "true", "assign_ipv6": "true"`, "1.168.4.0/24"),
 			Entry("v6 pool but assign v6 and v4", `"assign_ipv4": "true", "assign_ipv6": "true"`, "ff00:0001::/24"),
 			Entry("v4 and v6 pool but no assigning v4", `"assign_ipv4": "false", "assign_ipv6": "true"`, "1.168.4.0/24", "ff00:0001::/24"),
-			Entry("v4 and v6 pool but no assigning v6", `"assign_ipv4": "true", "assign_ipv6": "false"`, "1.168.4.0/24", "ff00:0001::/24"),
+		)
+		DescribeTable("should leave out a v6 pool which is not assigned from", func(assigns string) {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+				Name:  "CNI_NETWORK_CONFIG",
+				Value: fmt.Sprintf(`{"type": "calico", "name": "k8s-pod-network", "ipam": {"type": "calico-ipam"%s}}`, assigns),
+			}}
+			c := fake.NewFakeClientWithScheme(scheme, ds, v4pool1, v6pool1, emptyFelixConfig())
+			cfg, report, err := ConvertWithReport(ctx, c, Options{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
+			Expect(cfg.Spec.CalicoNetwork.IPPools[0].CIDR).To(Equal("1.168.4.0/24"))
+			Expect(report.Warnings).To(ContainElement(Warning{
+				Component: ComponentIPPools,
+				Message: "IPPool not-default1-v6 (ff00:0001::/24) will not be managed by the operator as the CNI config " +
+					"does not set assign_ipv6=true, it will remain in the datastore unchanged",
+			}))
+		},
+			Entry("assign_ipv6 false", `, "assign_ipv4": "true", "assign_ipv6": "false"`),
+			Entry("assign_ipv6 unset", ``),
 		)
 		DescribeTable("test convert pool flags", func(success bool, crdPool crdv1.IPPool, opPool operatorv1.IPPool) {
 			p, err := convertPool(crdPool)
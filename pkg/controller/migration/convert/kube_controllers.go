@@ -0,0 +1,66 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// handleKubeControllersHealth checks that kube-controllers reports its health in the way the operator
+// expects. The operator probes kube-controllers by exec'ing check-status, which requires health reporting
+// to be enabled, so probes against a custom health port will not be carried forward.
+func handleKubeControllersHealth(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	if c.kubeControllers == nil {
+		return nil
+	}
+
+	healthEnabled, err := getEnv(ctx, c.client, c.kubeControllers.Spec.Template.Spec, ComponentKubeControllers, containerKubeControllers, "HEALTH_ENABLED")
+	if err != nil {
+		return err
+	}
+	if healthEnabled != nil && strings.ToLower(*healthEnabled) != "true" {
+		if err := c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("HEALTH_ENABLED=%s is not supported, the operator checks the readiness of kube-controllers using its health reports", *healthEnabled),
+			component: ComponentKubeControllers,
+			fix:       "remove the HEALTH_ENABLED env var or set it to 'true'",
+		}); err != nil {
+			return err
+		}
+	}
+
+	container := getContainer(c.kubeControllers.Spec.Template.Spec, containerKubeControllers)
+	if container == nil {
+		return nil
+	}
+	for _, p := range []struct {
+		name  string
+		probe *corev1.Probe
+	}{
+		{"readiness", container.ReadinessProbe},
+		{"liveness", container.LivenessProbe},
+	} {
+		if port := probePort(p.probe); port != "" {
+			c.warn(ComponentKubeControllers, "the %s probe checks health port %s, but the operator will replace it with a probe which runs check-status", p.name, port)
+		}
+	}
+
+	return nil
+}
+
+// probePort returns the port checked by an http or tcp probe, or an empty string if
+// the probe does not check a port.
+func probePort(probe *corev1.Probe) string {
+	if probe == nil {
+		return ""
+	}
+	switch {
+	case probe.HTTPGet != nil:
+		return probe.HTTPGet.Port.String()
+	case probe.TCPSocket != nil:
+		return probe.TCPSocket.Port.String()
+	}
+	return ""
+}
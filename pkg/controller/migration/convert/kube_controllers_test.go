@@ -0,0 +1,90 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("kube-controllers health", func() {
+	var (
+		comps = emptyComponents()
+		i     = &operatorv1.Installation{}
+	)
+
+	BeforeEach(func() {
+		comps = emptyComponents()
+		comps.client = fake.NewFakeClient()
+		i = &operatorv1.Installation{}
+	})
+
+	It("should not warn for the default check-status probe", func() {
+		comps.kubeControllers.Spec.Template.Spec.Containers[0].ReadinessProbe = &corev1.Probe{
+			Handler: corev1.Handler{
+				Exec: &corev1.ExecAction{Command: []string{"/usr/bin/check-status", "-r"}},
+			},
+		}
+		Expect(handleKubeControllersHealth(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(BeEmpty())
+	})
+
+	It("should not error without kube-controllers", func() {
+		comps.kubeControllers = nil
+		Expect(handleKubeControllersHealth(ctx, &comps, i)).ToNot(HaveOccurred())
+	})
+
+	DescribeTable("HEALTH_ENABLED", func(value string, valid bool) {
+		comps.kubeControllers.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+			Name:  "HEALTH_ENABLED",
+			Value: value,
+		}}
+		err := handleKubeControllersHealth(ctx, &comps, i)
+		if valid {
+			Expect(err).ToNot(HaveOccurred())
+		} else {
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("HEALTH_ENABLED=" + value + " is not supported"))
+		}
+	},
+		Entry("true", "true", true),
+		Entry("True", "True", true),
+		Entry("false", "false", false),
+	)
+
+	It("should warn instead of erroring on HEALTH_ENABLED=false in lenient mode", func() {
+		comps.mode = ModeLenient
+		comps.kubeControllers.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+			Name:  "HEALTH_ENABLED",
+			Value: "false",
+		}}
+		Expect(handleKubeControllersHealth(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(HaveLen(1))
+		Expect(comps.report.Warnings[0].Component).To(Equal(ComponentKubeControllers))
+	})
+
+	DescribeTable("custom health port", func(probe *corev1.Probe) {
+		comps.kubeControllers.Spec.Template.Spec.Containers[0].LivenessProbe = probe
+		Expect(handleKubeControllersHealth(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(HaveLen(1))
+		Expect(comps.report.Warnings[0].Component).To(Equal(ComponentKubeControllers))
+		Expect(comps.report.Warnings[0].Message).To(Equal("the liveness probe checks health port 9099, " +
+			"but the operator will replace it with a probe which runs check-status"))
+	},
+		Entry("http", &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/liveness", Port: intstr.FromInt(9099)},
+			},
+		}),
+		Entry("tcp", &corev1.Probe{
+			Handler: corev1.Handler{
+				TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(9099)},
+			},
+		}),
+	)
+})
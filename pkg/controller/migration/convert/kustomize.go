@@ -0,0 +1,117 @@
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+)
+
+// kustomizationFile is the name of the kustomization written by WriteKustomization.
+const kustomizationFile = "kustomization.yaml"
+
+// MigratedResources returns the resources which make up the migrated configuration: the given
+// Installation, followed by the default FelixConfiguration and BGPConfiguration, if present,
+// and all IPPools.
+func MigratedResources(ctx context.Context, cli client.Client, install *operatorv1.Installation) ([]runtime.Object, error) {
+	objs := []runtime.Object{install}
+
+	fc := &crdv1.FelixConfiguration{}
+	if err := cli.Get(ctx, types.NamespacedName{Name: "default"}, fc); err == nil {
+		objs = append(objs, fc)
+	} else if !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get FelixConfiguration: %v", err)
+	}
+
+	bgp := &crdv1.BGPConfiguration{}
+	if err := cli.Get(ctx, types.NamespacedName{Name: "default"}, bgp); err == nil {
+		objs = append(objs, bgp)
+	} else if !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get BGPConfiguration: %v", err)
+	}
+
+	pools := crdv1.IPPoolList{}
+	if err := cli.List(ctx, &pools); err != nil && !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to list IPPools: %v", err)
+	}
+	for i := range pools.Items {
+		objs = append(objs, &pools.Items[i])
+	}
+
+	return objs, nil
+}
+
+// WriteKustomization writes each of the given resources to its own YAML file in dir, along with a
+// kustomization.yaml which references them, so that the migrated configuration can be adopted
+// by GitOps tooling. The scheme is used to fill in the apiVersion and kind of each resource, and
+// fields populated by the API server are dropped.
+func WriteKustomization(dir string, scheme *runtime.Scheme, objs ...runtime.Object) error {
+	resources := []string{}
+	for _, obj := range objs {
+		gvks, _, err := scheme.ObjectKinds(obj)
+		if err != nil {
+			return err
+		}
+		obj = obj.DeepCopyObject()
+		obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+
+		m, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		m.SetResourceVersion("")
+		m.SetUID("")
+		m.SetSelfLink("")
+		m.SetGeneration(0)
+		m.SetCreationTimestamp(metav1.Time{})
+		m.SetManagedFields(nil)
+
+		name := strings.ToLower(gvks[0].Kind)
+		if m.GetName() != "" {
+			name += "-" + m.GetName()
+		}
+		name += ".yaml"
+
+		if err := writeYAML(filepath.Join(dir, name), obj); err != nil {
+			return err
+		}
+		resources = append(resources, name)
+	}
+
+	return writeYAML(filepath.Join(dir, kustomizationFile), map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  resources,
+	})
+}
+
+// writeYAML writes obj to path as YAML. The object is first round tripped through JSON so that
+// its json tags are honoured and empty fields are omitted.
+func writeYAML(path string, obj interface{}) error {
+	j, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(j, &v); err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
@@ -0,0 +1,104 @@
+package convert
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/apis"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+)
+
+var _ = Describe("kustomize output", func() {
+	var (
+		ctx    = context.Background()
+		scheme *runtime.Scheme
+		dir    string
+	)
+
+	BeforeEach(func() {
+		scheme = kscheme.Scheme
+		Expect(apis.AddToScheme(scheme)).To(Succeed())
+
+		var err error
+		dir, err = ioutil.TempDir("", "kustomize")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	readYAML := func(name string) map[interface{}]interface{} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		Expect(err).ToNot(HaveOccurred())
+		out := map[interface{}]interface{}{}
+		Expect(yaml.Unmarshal(b, &out)).To(Succeed())
+		return out
+	}
+
+	It("should write each resource and a kustomization referencing them", func() {
+		pool := crdv1.NewIPPool()
+		pool.Name = "default-ipv4-ippool"
+		pool.Spec = crdv1.IPPoolSpec{CIDR: "192.168.0.0/16", IPIPMode: crdv1.IPIPModeAlways, NATOutgoing: true}
+		cli := fake.NewFakeClientWithScheme(scheme, emptyFelixConfig(), pool, &crdv1.BGPConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		})
+
+		install := &operatorv1.Installation{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Spec:       operatorv1.InstallationSpec{Variant: operatorv1.Calico},
+		}
+		objs, err := MigratedResources(ctx, cli, install)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(objs).To(HaveLen(4))
+
+		Expect(WriteKustomization(dir, scheme, objs...)).To(Succeed())
+
+		k := readYAML("kustomization.yaml")
+		Expect(k["kind"]).To(Equal("Kustomization"))
+		Expect(k["resources"]).To(Equal([]interface{}{
+			"installation-default.yaml",
+			"felixconfiguration-default.yaml",
+			"bgpconfiguration-default.yaml",
+			"ippool-default-ipv4-ippool.yaml",
+		}))
+
+		for file, kind := range map[string]string{
+			"installation-default.yaml":       "Installation",
+			"felixconfiguration-default.yaml": "FelixConfiguration",
+			"bgpconfiguration-default.yaml":   "BGPConfiguration",
+			"ippool-default-ipv4-ippool.yaml": "IPPool",
+		} {
+			out := readYAML(file)
+			Expect(out["kind"]).To(Equal(kind), file)
+			Expect(out["metadata"]).ToNot(HaveKey("resourceVersion"), file)
+		}
+
+		ippool := readYAML("ippool-default-ipv4-ippool.yaml")
+		Expect(ippool["apiVersion"]).To(Equal("crd.projectcalico.org/v1"))
+		Expect(ippool["spec"]).To(HaveKeyWithValue("cidr", "192.168.0.0/16"))
+
+		i := readYAML("installation-default.yaml")
+		Expect(i["apiVersion"]).To(Equal("operator.tigera.io/v1"))
+		Expect(i["spec"]).To(HaveKeyWithValue("variant", "Calico"))
+	})
+
+	It("should only include the Installation and pools which exist", func() {
+		cli := fake.NewFakeClientWithScheme(scheme)
+		objs, err := MigratedResources(ctx, cli, &operatorv1.Installation{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(objs).To(HaveLen(1))
+	})
+})
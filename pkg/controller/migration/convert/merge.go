@@ -0,0 +1,53 @@
+package convert
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+)
+
+// operatorAPIPkg is the package path of the operator API types, whose structs are merged field by field.
+var operatorAPIPkg = reflect.TypeOf(operatorv1.InstallationSpec{}).PkgPath()
+
+// MergeIntoInstallation fills in any fields which the existing Installation leaves unset with the values
+// of the detected Installation, so that users who have written part of their Installation by hand can use
+// the migration to complete it. Fields which are set in both must agree. If any conflict, an error listing
+// them is returned and the existing Installation is left unchanged.
+func MergeIntoInstallation(detected, existing *operatorv1.Installation) error {
+	merged := existing.Spec.DeepCopy()
+	conflicts := mergeFields(reflect.ValueOf(merged).Elem(), reflect.ValueOf(detected.Spec.DeepCopy()).Elem(), "spec")
+	if len(conflicts) != 0 {
+		return fmt.Errorf("detected configuration conflicts with the existing Installation: %s", strings.Join(conflicts, ", "))
+	}
+	existing.Spec = *merged
+	return nil
+}
+
+// mergeFields sets each unset field of dst to the value of the same field in src, and returns the paths
+// of the fields which are set to different values in both. Structs of the operator API are merged field by
+// field, while any other values, including slices and maps, are compared as a whole.
+func mergeFields(dst, src reflect.Value, path string) []string {
+	var conflicts []string
+	for i := 0; i < dst.NumField(); i++ {
+		d, s := dst.Field(i), src.Field(i)
+		if !d.CanSet() {
+			continue
+		}
+		name := path + "." + strings.Split(dst.Type().Field(i).Tag.Get("json"), ",")[0]
+
+		switch {
+		case s.IsZero():
+		case d.IsZero():
+			d.Set(s)
+		case d.Kind() == reflect.Ptr && d.Elem().Kind() == reflect.Struct && d.Elem().Type().PkgPath() == operatorAPIPkg:
+			conflicts = append(conflicts, mergeFields(d.Elem(), s.Elem(), name)...)
+		case d.Kind() == reflect.Struct && d.Type().PkgPath() == operatorAPIPkg:
+			conflicts = append(conflicts, mergeFields(d, s, name)...)
+		case !reflect.DeepEqual(d.Interface(), s.Interface()):
+			conflicts = append(conflicts, name)
+		}
+	}
+	return conflicts
+}
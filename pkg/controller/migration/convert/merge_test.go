@@ -0,0 +1,76 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+)
+
+var _ = Describe("merging into an existing installation", func() {
+	var detected *operatorv1.Installation
+
+	BeforeEach(func() {
+		mtu := int32(1440)
+		detected = &operatorv1.Installation{
+			Spec: operatorv1.InstallationSpec{
+				CNI: &operatorv1.CNISpec{
+					Type: operatorv1.PluginCalico,
+					IPAM: &operatorv1.IPAMSpec{Type: operatorv1.IPAMPluginCalico},
+				},
+				CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					BGP:     operatorv1.BGPOptionPtr(operatorv1.BGPEnabled),
+					MTU:     &mtu,
+					IPPools: []operatorv1.IPPool{{CIDR: "192.168.0.0/16", Encapsulation: operatorv1.EncapsulationIPIP}},
+				},
+			},
+		}
+	})
+
+	It("should fill an empty installation", func() {
+		existing := &operatorv1.Installation{}
+		Expect(MergeIntoInstallation(detected, existing)).To(Succeed())
+		Expect(existing.Spec).To(Equal(detected.Spec))
+	})
+
+	It("should only fill the fields which are unset", func() {
+		existing := &operatorv1.Installation{
+			Spec: operatorv1.InstallationSpec{
+				Registry: "registry.local/",
+				CNI:      &operatorv1.CNISpec{Type: operatorv1.PluginCalico},
+				CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					BGP: operatorv1.BGPOptionPtr(operatorv1.BGPEnabled),
+				},
+			},
+		}
+		Expect(MergeIntoInstallation(detected, existing)).To(Succeed())
+		Expect(existing.Spec.Registry).To(Equal("registry.local/"))
+		Expect(existing.Spec.CNI.IPAM.Type).To(Equal(operatorv1.IPAMPluginCalico))
+		Expect(*existing.Spec.CalicoNetwork.MTU).To(Equal(int32(1440)))
+		Expect(existing.Spec.CalicoNetwork.IPPools).To(Equal(detected.Spec.CalicoNetwork.IPPools))
+	})
+
+	It("should not share values with the detected installation", func() {
+		existing := &operatorv1.Installation{}
+		Expect(MergeIntoInstallation(detected, existing)).To(Succeed())
+		*existing.Spec.CalicoNetwork.MTU = 9000
+		Expect(*detected.Spec.CalicoNetwork.MTU).To(Equal(int32(1440)))
+	})
+
+	It("should error on conflicts and leave the existing installation unchanged", func() {
+		existing := &operatorv1.Installation{
+			Spec: operatorv1.InstallationSpec{
+				CNI: &operatorv1.CNISpec{Type: operatorv1.PluginAmazonVPC},
+				CalicoNetwork: &operatorv1.CalicoNetworkSpec{
+					IPPools: []operatorv1.IPPool{{CIDR: "10.0.0.0/16"}},
+				},
+			},
+		}
+		err := MergeIntoInstallation(detected, existing)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.cni.type"))
+		Expect(err.Error()).To(ContainSubstring("spec.calicoNetwork.ipPools"))
+		Expect(existing.Spec.CNI.IPAM).To(BeNil())
+		Expect(existing.Spec.CalicoNetwork.MTU).To(BeNil())
+	})
+})
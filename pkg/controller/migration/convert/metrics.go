@@ -0,0 +1,67 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handleNodeMetricsService is a migration handler which looks for Services exposing calico-node's
+// prometheus metrics, and cross-checks their ports against the NodeMetricsPort detected from
+// FELIX_PROMETHEUSMETRICSPORT. It must run after handleFelixNodeMetrics.
+// Such Services are not managed by the operator and will stop selecting calico-node once it
+// moves out of kube-system, so they are always recorded in the report.
+func handleNodeMetricsService(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	podLabels := labels.Set(c.node.Spec.Template.Labels)
+	if len(podLabels) == 0 {
+		return nil
+	}
+
+	svcs := corev1.ServiceList{}
+	if err := c.client.List(ctx, &svcs, client.InNamespace(metav1.NamespaceSystem)); err != nil {
+		return fmt.Errorf("failed to list services: %v", err)
+	}
+
+	for _, svc := range svcs.Items {
+		if len(svc.Spec.Selector) == 0 || !labels.SelectorFromSet(svc.Spec.Selector).Matches(podLabels) {
+			continue
+		}
+
+		c.warn(ComponentCalicoNode, "service %s/%s selects calico-node pods but is not managed by the operator, "+
+			"it must be updated to select calico-node in the calico-system namespace after migration", svc.Namespace, svc.Name)
+
+		if install.Spec.NodeMetricsPort == nil {
+			c.warn(ComponentCalicoNode, "service %s/%s selects calico-node pods but felix prometheus metrics are not enabled", svc.Namespace, svc.Name)
+			continue
+		}
+
+		if !servicePortsTarget(svc, *install.Spec.NodeMetricsPort) {
+			c.warn(ComponentCalicoNode, "service %s/%s does not target the felix prometheus metrics port %d",
+				svc.Namespace, svc.Name, *install.Spec.NodeMetricsPort)
+		}
+	}
+
+	return nil
+}
+
+// servicePortsTarget returns true if any of the service's ports forward to the given container port.
+// Named target ports can not be resolved, so are assumed to match.
+func servicePortsTarget(svc corev1.Service, port int32) bool {
+	for _, p := range svc.Spec.Ports {
+		switch {
+		case p.TargetPort.Type == intstr.String:
+			return true
+		case p.TargetPort.IntVal == 0 && p.Port == port:
+			return true
+		case p.TargetPort.IntVal == port:
+			return true
+		}
+	}
+	return false
+}
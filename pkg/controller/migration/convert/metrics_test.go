@@ -0,0 +1,87 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/apis"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("calico-node metrics service", func() {
+	var (
+		comps  = emptyComponents()
+		i      = &operatorv1.Installation{}
+		scheme *runtime.Scheme
+		svc    *corev1.Service
+	)
+
+	BeforeEach(func() {
+		comps = emptyComponents()
+		comps.node.Spec.Template.Labels = map[string]string{"k8s-app": "calico-node"}
+		i = &operatorv1.Installation{}
+		scheme = kscheme.Scheme
+		Expect(apis.AddToScheme(scheme)).ToNot(HaveOccurred())
+		svc = &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "felix-metrics-svc",
+				Namespace: metav1.NamespaceSystem,
+			},
+			Spec: corev1.ServiceSpec{
+				Selector: map[string]string{"k8s-app": "calico-node"},
+				Ports: []corev1.ServicePort{{
+					Port:       9091,
+					TargetPort: intstr.FromInt(9091),
+				}},
+			},
+		}
+	})
+
+	It("should not warn if there is no service", func() {
+		comps.client = fake.NewFakeClientWithScheme(scheme)
+		Expect(handleNodeMetricsService(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(BeEmpty())
+	})
+
+	It("should ignore services which don't select calico-node", func() {
+		svc.Spec.Selector = map[string]string{"k8s-app": "calico-typha"}
+		comps.client = fake.NewFakeClientWithScheme(scheme, svc)
+		Expect(handleNodeMetricsService(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(BeEmpty())
+	})
+
+	It("should only warn about the namespace if the port matches", func() {
+		comps.client = fake.NewFakeClientWithScheme(scheme, svc)
+		i.Spec.NodeMetricsPort = &svc.Spec.Ports[0].Port
+		Expect(handleNodeMetricsService(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(HaveLen(1))
+		Expect(comps.report.Warnings[0].Message).To(ContainSubstring("must be updated to select calico-node in the calico-system namespace"))
+	})
+
+	It("should warn if the port does not match", func() {
+		comps.client = fake.NewFakeClientWithScheme(scheme, svc)
+		var port int32 = 7777
+		i.Spec.NodeMetricsPort = &port
+		Expect(handleNodeMetricsService(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(ContainElement(Warning{
+			Component: ComponentCalicoNode,
+			Message:   "service kube-system/felix-metrics-svc does not target the felix prometheus metrics port 7777",
+		}))
+	})
+
+	It("should warn if metrics are disabled", func() {
+		comps.client = fake.NewFakeClientWithScheme(scheme, svc)
+		Expect(handleNodeMetricsService(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(comps.report.Warnings).To(ContainElement(Warning{
+			Component: ComponentCalicoNode,
+			Message:   "service kube-system/felix-metrics-svc selects calico-node pods but felix prometheus metrics are not enabled",
+		}))
+	})
+})
@@ -1,27 +1,36 @@
 package convert
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"strings"
 
 	operatorv1 "github.com/tigera/operator/api/v1"
 )
 
+// vxlanV6ExtraOverhead is the number of bytes VXLAN over IPv6 adds to a packet on top of VXLAN over IPv4.
+const vxlanV6ExtraOverhead = 20
+
 // handleMTU is a migration handler which ensures MTU configuration is carried forward.
-func handleMTU(c *components, install *operatorv1.Installation) error {
+func handleMTU(ctx context.Context, c *components, install *operatorv1.Installation) error {
 	var (
 		curMTU    *int32
 		curMTUSrc string
 	)
 
-	for _, src := range []string{"FELIX_IPINIPMTU", "FELIX_VXLANMTU", "FELIX_WIREGUARDMTU"} {
-		mtu, err := getMTU(c, containerCalicoNode, src)
+	for _, src := range []string{"FELIX_IPINIPMTU", "FELIX_VXLANMTU", "FELIX_VXLANMTUV6", "FELIX_WIREGUARDMTU"} {
+		mtu, err := getMTU(ctx, c, containerCalicoNode, src)
 		if err != nil {
-			return ErrIncompatibleCluster{
+			// in lenient mode, an invalid mtu is ignored as if it were not set.
+			if err := c.incompatible(ErrIncompatibleCluster{
 				err:       fmt.Sprintf("failed to parse mtu from %s: %v", src, err),
 				component: ComponentCalicoNode,
 				fix:       fmt.Sprintf("adjust %s to a valid integer or unset the env var", src),
+			}); err != nil {
+				return err
 			}
+			continue
 		}
 
 		// if this mtu source is not set, ignore
@@ -31,11 +40,34 @@ func handleMTU(c *components, install *operatorv1.Installation) error {
 
 		// compare against current mtu.
 		if curMTU != nil && *curMTU != *mtu {
-			return ErrIncompatibleCluster{
+			if src == "FELIX_VXLANMTUV6" && curMTUSrc == "FELIX_VXLANMTU" && *mtu == *curMTU-vxlanV6ExtraOverhead {
+				// this is the standard IPv6 vxlan mtu for the IPv4 one. The operator only configures the IPv4
+				// vxlan mtu, leaving felix to calculate the IPv6 one, so it is not carried forward.
+				c.note(ComponentCalicoNode, "%s=%d is %d bytes smaller than %s=%d to allow for the larger IPv6 header, "+
+					"felix will calculate the ipv6 vxlan mtu", src, *mtu, vxlanV6ExtraOverhead, curMTUSrc, *curMTU)
+				continue
+			}
+			// in lenient mode, the first mtu found is kept.
+			if strings.HasSuffix(src, "V6") {
+				// the operator only has a single mtu setting which is used for both ip families.
+				if err := c.incompatible(ErrIncompatibleCluster{
+					err: fmt.Sprintf("ipv6 mtu %s=%d does not match ipv4 mtu %s=%d, the operator can not configure a separate mtu per ip family",
+						src, *mtu, curMTUSrc, *curMTU),
+					component: ComponentCalicoNode,
+					fix:       fmt.Sprintf("adjust %s and %s to match or unset %s", src, curMTUSrc, src),
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := c.incompatible(ErrIncompatibleCluster{
 				err:       fmt.Sprintf("mtu %s=%d does not match mtu %s=%d", src, *mtu, curMTUSrc, *curMTU),
 				component: ComponentCalicoNode,
 				fix:       fmt.Sprintf("adjust %s and %s to match or unset one of them", src, curMTUSrc),
+			}); err != nil {
+				return err
 			}
+			continue
 		}
 
 		curMTU, curMTUSrc = mtu, src
@@ -46,45 +78,74 @@ func handleMTU(c *components, install *operatorv1.Installation) error {
 			// if MTU is -1, we assume it was us who replaced it when doing initial CNI
 			// config loading. We need to pull it from the correct source
 			var src = "CNI_MTU"
-			mtu, err := getMTU(c, containerInstallCNI, src)
-			if err != nil {
-				return ErrIncompatibleCluster{
-					err:       fmt.Sprintf("failed to parse mtu from %s: %v", src, err),
-					component: ComponentCalicoNode,
-					fix:       fmt.Sprintf("adjust %s to a valid integer", src),
+			var mtu *int32
+			// CNI_MTU can only be set if install-cni renders the CNI config.
+			if getContainer(c.node.Spec.Template.Spec, containerInstallCNI) != nil {
+				var err error
+				if mtu, err = getMTU(ctx, c, containerInstallCNI, src); err != nil {
+					// in lenient mode, an invalid CNI_MTU is ignored as if it were not set.
+					if err := c.incompatible(ErrIncompatibleCluster{
+						err:       fmt.Sprintf("failed to parse mtu from %s: %v", src, err),
+						component: ComponentCalicoNode,
+						fix:       fmt.Sprintf("adjust %s to a valid integer", src),
+					}); err != nil {
+						return err
+					}
 				}
 			}
 
 			if mtu == nil {
-				// if not set, install-cni will use a known default mtu of 1500
-				mtu = new(int32)
-				*mtu = 1500
+				if curMTU != nil {
+					// CNI_MTU isn't set, but the MTU is configured for felix, so assume the CNI config is
+					// meant to use the same MTU and carry it forward rather than the default.
+					c.warn(ComponentCalicoNode, "CNI_MTU is not set, using the mtu %s=%d for the CNI config", curMTUSrc, *curMTU)
+					mtu = curMTU
+					src = curMTUSrc
+				} else if mtu = getAutoDetectedMTU(c); mtu != nil {
+					// use the mtu calico-node auto-detected rather than the install-cni default.
+					src = mtuAnnotation
+				} else {
+					// if not set, install-cni will use a known default mtu of 1500
+					mtu = new(int32)
+					*mtu = 1500
+				}
 			}
 
-			// compare against current mtu.
+			// compare against current mtu. In lenient mode, the felix mtu is kept.
 			if curMTU != nil && *curMTU != *mtu {
-				return ErrIncompatibleCluster{
+				if err := c.incompatible(ErrIncompatibleCluster{
 					err:       fmt.Sprintf("mtu %s=%d does not match mtu %s=%d", src, *mtu, curMTUSrc, *curMTU),
 					component: ComponentCalicoNode,
-					fix:       fmt.Sprintf("adjust %s and %s to match or unset one of them", src, curMTUSrc)}
+					fix:       fmt.Sprintf("adjust %s and %s to match or unset one of them", src, curMTUSrc)}); err != nil {
+					return err
+				}
+			} else {
+				curMTU, curMTUSrc = mtu, src
 			}
-			curMTU, curMTUSrc = mtu, "CNI_MTU"
 
 		} else {
 			// user must have hardcoded their CNI instead of using the cni templating engine.
 			// use the hardcoded value.
 			mtu := int32(c.cni.CalicoConfig.MTU)
 			if curMTU != nil && *curMTU != mtu {
-				return ErrIncompatibleCluster{
+				// in lenient mode, the felix mtu is kept.
+				if err := c.incompatible(ErrIncompatibleCluster{
 					err:       fmt.Sprintf("mtu '%d' specified in CNI config does not match mtu %s=%d", mtu, curMTUSrc, *curMTU),
 					component: ComponentCalicoNode,
 					fix:       fmt.Sprintf("adjust the mtu value set in CNI config to match %s or unset one of them", curMTUSrc),
+				}); err != nil {
+					return err
 				}
+			} else {
+				curMTU = &mtu
 			}
-			curMTU = &mtu
 		}
 	}
 
+	if curMTU == nil {
+		curMTU = getAutoDetectedMTU(c)
+	}
+
 	if curMTU != nil {
 		if install.Spec.CalicoNetwork == nil {
 			install.Spec.CalicoNetwork = &operatorv1.CalicoNetworkSpec{}
@@ -95,10 +156,69 @@ func handleMTU(c *components, install *operatorv1.Installation) error {
 	return nil
 }
 
+// encapOverhead is the number of bytes each encapsulation adds to a packet.
+var encapOverhead = map[operatorv1.EncapsulationType]int32{
+	operatorv1.EncapsulationIPIP:             20,
+	operatorv1.EncapsulationIPIPCrossSubnet:  20,
+	operatorv1.EncapsulationVXLAN:            50,
+	operatorv1.EncapsulationVXLANCrossSubnet: 50,
+}
+
+// hostMTUs are common network interface MTUs. A pod MTU matching one of these while
+// encapsulation is in use suggests the tunnel overhead was not subtracted.
+var hostMTUs = []int32{1460, 1500, 8981, 9000, 9001}
+
+// handleMTUEncapsulation is a migration handler which checks that the detected MTU leaves room for
+// the overhead of the IP pools' encapsulation. It must run after both the MTU and IP pools have been
+// converted. It is advisory only: an inconsistent MTU is recorded in the report as a warning.
+func handleMTUEncapsulation(_ context.Context, c *components, install *operatorv1.Installation) error {
+	if install.Spec.CalicoNetwork == nil || install.Spec.CalicoNetwork.MTU == nil {
+		return nil
+	}
+	mtu := *install.Spec.CalicoNetwork.MTU
+
+	for _, pool := range install.Spec.CalicoNetwork.IPPools {
+		overhead, ok := encapOverhead[pool.Encapsulation]
+		if !ok {
+			continue
+		}
+		for _, hostMTU := range hostMTUs {
+			if mtu == hostMTU {
+				c.warn(ComponentCalicoNode, "mtu %d matches a common host MTU but IPPool %s uses %s encapsulation, "+
+					"the mtu should be at least %d bytes smaller than the host MTU (e.g. %d)",
+					mtu, pool.CIDR, pool.Encapsulation, overhead, hostMTU-overhead)
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// mtuAnnotation is set on the calico-node daemonset by newer versions of Calico to record the MTU they auto-detected.
+const mtuAnnotation = "projectcalico.org/mtu"
+
+// getAutoDetectedMTU returns the MTU recorded in the mtuAnnotation, or nil if it is absent. An invalid value
+// is recorded as a warning and ignored, since it only stands in for an MTU which was not explicitly configured.
+func getAutoDetectedMTU(c *components) *int32 {
+	v, ok := c.node.Annotations[mtuAnnotation]
+	if !ok {
+		return nil
+	}
+	i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 32)
+	if err != nil || i <= 0 {
+		c.warn(ComponentCalicoNode, "ignoring invalid auto-detected mtu %s=%s", mtuAnnotation, v)
+		return nil
+	}
+	mtu := int32(i)
+	return &mtu
+}
+
 // getMTU retrieves an mtu value from an env var on a container.
 // if the specified env var does not exist, it will return nil.
 // since env vars are strings, this function also parses it into an int32 pointer.
-func getMTU(c *components, container, key string) (*int32, error) {
+// values sourced from a ConfigMap often carry trailing newlines, so surrounding
+// whitespace is trimmed before parsing.
+func getMTU(ctx context.Context, c *components, container, key string) (*int32, error) {
 	m, err := c.node.getEnv(ctx, c.client, container, key)
 	if err != nil {
 		return nil, err
@@ -108,9 +228,13 @@ func getMTU(c *components, container, key string) (*int32, error) {
 		return nil, nil
 	}
 
-	i, err := strconv.ParseInt(*m, 10, 32)
+	v := strings.TrimSpace(*m)
+	i, err := strconv.ParseInt(v, 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't convert %s to integer: %v", *m, err)
+		return nil, fmt.Errorf("couldn't convert '%s' to integer: %v", v, err)
+	}
+	if i <= 0 {
+		return nil, fmt.Errorf("mtu must be a positive integer, got %d", i)
 	}
 	mtu := int32(i)
 	return &mtu, nil
@@ -4,6 +4,8 @@ import (
 	operatorv1 "github.com/tigera/operator/api/v1"
 	"github.com/tigera/operator/pkg/controller/migration/cni"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/extensions/table"
@@ -21,7 +23,7 @@ var _ = Describe("mtu handler", func() {
 		i = &operatorv1.Installation{}
 	})
 	It("should not set mtu if none defined", func() {
-		err := handleMTU(&comps, i)
+		err := handleMTU(ctx, &comps, i)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(i.Spec.CalicoNetwork).To(BeNil())
 	})
@@ -30,7 +32,7 @@ var _ = Describe("mtu handler", func() {
 		comps.cni.CalicoConfig = &cni.CalicoConf{
 			MTU: 1234,
 		}
-		err := handleMTU(&comps, i)
+		err := handleMTU(ctx, &comps, i)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(i.Spec.CalicoNetwork).ToNot(BeNil())
 		Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1234))
@@ -41,16 +43,56 @@ var _ = Describe("mtu handler", func() {
 			Name:  env,
 			Value: "1324",
 		}}
-		err := handleMTU(&comps, i)
+		err := handleMTU(ctx, &comps, i)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(i.Spec.CalicoNetwork).ToNot(BeNil())
 		Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1324))
 	},
 		table.Entry("ipip", "FELIX_IPINIPMTU"),
 		table.Entry("vxlan", "FELIX_VXLANMTU"),
+		table.Entry("vxlan v6", "FELIX_VXLANMTUV6"),
 		table.Entry("wireguard", "FELIX_WIREGUARDMTU"),
 	)
 
+	It("should accept matching v4 and v6 vxlan mtus", func() {
+		comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+			{Name: "FELIX_VXLANMTU", Value: "1410"},
+			{Name: "FELIX_VXLANMTUV6", Value: "1410"},
+		}
+		Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1410))
+	})
+
+	It("should accept a v6 vxlan mtu which allows for the larger IPv6 header", func() {
+		comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+			{Name: "FELIX_VXLANMTU", Value: "1410"},
+			{Name: "FELIX_VXLANMTUV6", Value: "1390"},
+		}
+		Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1410))
+		Expect(comps.report.Notes).To(HaveLen(1))
+		Expect(comps.report.Notes[0].Message).To(ContainSubstring("FELIX_VXLANMTUV6=1390 is 20 bytes smaller than FELIX_VXLANMTU=1410"))
+	})
+
+	It("should error if the v4 and v6 vxlan mtus differ", func() {
+		comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+			{Name: "FELIX_VXLANMTU", Value: "1410"},
+			{Name: "FELIX_VXLANMTUV6", Value: "1400"},
+		}
+		err := handleMTU(ctx, &comps, i)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("ipv6 mtu FELIX_VXLANMTUV6=1400 does not match ipv4 mtu FELIX_VXLANMTU=1410"))
+		Expect(i.Spec.CalicoNetwork).To(BeNil())
+	})
+
+	It("should only allow for the IPv6 header against the v4 vxlan mtu", func() {
+		comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+			{Name: "FELIX_IPINIPMTU", Value: "1410"},
+			{Name: "FELIX_VXLANMTUV6", Value: "1390"},
+		}
+		Expect(handleMTU(ctx, &comps, i)).To(HaveOccurred())
+	})
+
 	It("should error if given conflicting mtu values between env vars", func() {
 		comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
 			{
@@ -62,10 +104,48 @@ var _ = Describe("mtu handler", func() {
 				Value: "999",
 			},
 		}
-		err := handleMTU(&comps, i)
+		err := handleMTU(ctx, &comps, i)
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should keep the first mtu if given conflicting mtu values between env vars when lenient", func() {
+		comps.mode = ModeLenient
+		comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+			{Name: "FELIX_IPINIPMTU", Value: "1324"},
+			{Name: "FELIX_VXLANMTU", Value: "999"},
+		}
+		Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(*i.Spec.CalicoNetwork.MTU).To(Equal(int32(1324)))
+		Expect(comps.report.ManualSteps).To(HaveLen(1))
+		Expect(comps.report.ManualSteps[0].Issue).To(Equal("mtu FELIX_VXLANMTU=999 does not match mtu FELIX_IPINIPMTU=1324"))
+	})
+
+	It("should ignore an invalid mtu when lenient", func() {
+		comps.mode = ModeLenient
+		comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+			{Name: "FELIX_IPINIPMTU", Value: "abc"},
+			{Name: "FELIX_VXLANMTU", Value: "1400"},
+		}
+		Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(*i.Spec.CalicoNetwork.MTU).To(Equal(int32(1400)))
+		Expect(comps.report.ManualSteps).To(HaveLen(1))
+		Expect(comps.report.ManualSteps[0].Issue).To(ContainSubstring("failed to parse mtu from FELIX_IPINIPMTU"))
+	})
+
+	It("should keep the felix mtu if given conflicting mtu values between cni and env var when lenient", func() {
+		comps.mode = ModeLenient
+		comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+			Name:  "FELIX_IPINIPMTU",
+			Value: "1324",
+		}}
+		comps.cni.CalicoConfig = &cni.CalicoConf{
+			MTU: 1234,
+		}
+		Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+		Expect(*i.Spec.CalicoNetwork.MTU).To(Equal(int32(1324)))
+		Expect(comps.report.ManualSteps).To(HaveLen(1))
+	})
+
 	It("should error if given conflicting mtu values between cni and env var", func() {
 		comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
 			Name:  "FELIX_IPINIPMTU",
@@ -74,7 +154,221 @@ var _ = Describe("mtu handler", func() {
 		comps.cni.CalicoConfig = &cni.CalicoConf{
 			MTU: 1234,
 		}
-		err := handleMTU(&comps, i)
+		err := handleMTU(ctx, &comps, i)
 		Expect(err).To(HaveOccurred())
 	})
+
+	Context("auto-detected mtu annotation", func() {
+		BeforeEach(func() {
+			comps.node.Annotations = map[string]string{mtuAnnotation: "8951"}
+		})
+
+		It("should use the annotation if no mtu is configured", func() {
+			Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(8951))
+		})
+
+		It("should use the annotation over the default for a templated CNI mtu", func() {
+			comps.cni.CalicoConfig = &cni.CalicoConf{MTU: -1}
+			Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(8951))
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+
+		It("should prefer an explicitly configured mtu", func() {
+			comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "FELIX_IPINIPMTU", Value: "1440"}}
+			Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1440))
+		})
+
+		It("should ignore an invalid annotation", func() {
+			comps.node.Annotations[mtuAnnotation] = "auto"
+			Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork).To(BeNil())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Message).To(Equal("ignoring invalid auto-detected mtu projectcalico.org/mtu=auto"))
+		})
+
+		It("should not be rejected as an unexpected annotation", func() {
+			Expect(handleAnnotations(ctx, &comps, i)).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("templated CNI mtu without CNI_MTU", func() {
+		BeforeEach(func() {
+			comps.cni.CalicoConfig = &cni.CalicoConf{
+				MTU: -1,
+			}
+		})
+
+		table.DescribeTable("should use the felix mtu", func(env string) {
+			comps.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name:  env,
+				Value: "1440",
+			}}
+			Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1440))
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Message).To(Equal("CNI_MTU is not set, using the mtu " + env + "=1440 for the CNI config"))
+		},
+			table.Entry("ipip", "FELIX_IPINIPMTU"),
+			table.Entry("vxlan", "FELIX_VXLANMTU"),
+		)
+
+		It("should default to 1500 if no felix mtu is set", func() {
+			Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1500))
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+	})
+
+	table.DescribeTable("should resolve a templated mtu appearing in multiple fields", func(cniConfig string) {
+		ds := emptyNodeSpec()
+		ds.Spec.Template.Spec.InitContainers[0].Env = []v1.EnvVar{
+			{Name: "CNI_NETWORK_CONFIG", Value: cniConfig},
+			{Name: "CNI_MTU", Value: "1450"},
+		}
+		c, err := getComponents(ctx, fake.NewFakeClient(ds))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.cni.CalicoConfig).ToNot(BeNil())
+		Expect(c.cni.CalicoConfig.MTU).To(Equal(-1))
+		Expect(c.cni.Plugins).To(HaveKey("tuning"))
+
+		Expect(handleMTU(ctx, c, i)).ToNot(HaveOccurred())
+		Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1450))
+	},
+		table.Entry("unquoted", `{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [
+    {"type": "calico", "mtu": __CNI_MTU__, "ipam": {"type": "calico-ipam"}},
+    {"type": "tuning", "mtu": __CNI_MTU__, "sysctl": {"net.core.somaxconn": "500"}}
+  ]
+}`),
+		table.Entry("quoted", `{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [
+    {"type": "calico", "mtu": "__CNI_MTU__", "ipam": {"type": "calico-ipam"}},
+    {"type": "tuning", "mtu": __CNI_MTU__}
+  ]
+}`),
+		table.Entry("escaped", `{\n  \"name\": \"k8s-pod-network\",\n  \"cniVersion\": \"0.3.1\",\n  \"plugins\": [\n    `+
+			`{\"type\": \"calico\", \"mtu\": \"__CNI_MTU__\", \"ipam\": {\"type\": \"calico-ipam\"}},\n    `+
+			`{\"type\": \"tuning\", \"mtu\": __CNI_MTU__}\n  ]\n}`),
+	)
+
+	table.DescribeTable("should read the mtu from the conflist as a number or a string", func(mtu string) {
+		ds := emptyNodeSpec()
+		ds.Spec.Template.Spec.InitContainers[0].Env = []v1.EnvVar{{
+			Name: "CNI_NETWORK_CONFIG",
+			Value: `{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.1",
+  "plugins": [{"type": "calico", "mtu": ` + mtu + `, "ipam": {"type": "calico-ipam"}}]
+}`,
+		}}
+		c, err := getComponents(ctx, fake.NewFakeClient(ds))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.cni.CalicoConfig.MTU).To(Equal(1440))
+
+		Expect(handleMTU(ctx, c, i)).ToNot(HaveOccurred())
+		Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1440))
+	},
+		table.Entry("number", `1440`),
+		table.Entry("string", `"1440"`),
+	)
+
+	It("should error on an mtu string which is not a number", func() {
+		ds := emptyNodeSpec()
+		ds.Spec.Template.Spec.InitContainers[0].Env = []v1.EnvVar{{
+			Name:  "CNI_NETWORK_CONFIG",
+			Value: `{"name": "k8s-pod-network", "plugins": [{"type": "calico", "mtu": "large"}]}`,
+		}}
+		_, err := getComponents(ctx, fake.NewFakeClient(ds))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`mtu "large" is not a number`))
+	})
+
+	Context("CNI_MTU from a ConfigMap", func() {
+		setCNIMTU := func(value string) {
+			comps.client = fake.NewFakeClient(&v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "calico-config",
+					Namespace: "kube-system",
+				},
+				Data: map[string]string{"veth_mtu": value},
+			})
+			comps.node.Spec.Template.Spec.InitContainers[0].Env = []v1.EnvVar{{
+				Name: "CNI_MTU",
+				ValueFrom: &v1.EnvVarSource{
+					ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "calico-config"},
+						Key:                  "veth_mtu",
+					},
+				},
+			}}
+			comps.cni.CalicoConfig = &cni.CalicoConf{
+				MTU: -1,
+			}
+		}
+
+		It("should read the mtu", func() {
+			setCNIMTU("1410")
+			Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1410))
+		})
+
+		It("should ignore surrounding whitespace", func() {
+			setCNIMTU(" 1410\n")
+			Expect(handleMTU(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(*i.Spec.CalicoNetwork.MTU).To(BeEquivalentTo(1410))
+		})
+
+		It("should error on a non-integer value", func() {
+			setCNIMTU("14 10")
+			err := handleMTU(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("couldn't convert '14 10' to integer"))
+		})
+
+		It("should error on a non-positive value", func() {
+			setCNIMTU("0")
+			Expect(handleMTU(ctx, &comps, i)).To(HaveOccurred())
+		})
+	})
+
+	Context("mtu and encapsulation", func() {
+		setNetwork := func(mtu int32, encap operatorv1.EncapsulationType) {
+			i.Spec.CalicoNetwork = &operatorv1.CalicoNetworkSpec{
+				MTU: &mtu,
+				IPPools: []operatorv1.IPPool{{
+					CIDR:          "192.168.0.0/16",
+					Encapsulation: encap,
+				}},
+			}
+		}
+
+		It("should warn if the mtu ignores the IPIP overhead", func() {
+			setNetwork(1500, operatorv1.EncapsulationIPIP)
+			Expect(handleMTUEncapsulation(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message: "mtu 1500 matches a common host MTU but IPPool 192.168.0.0/16 uses IPIP encapsulation, " +
+					"the mtu should be at least 20 bytes smaller than the host MTU (e.g. 1480)",
+			}))
+		})
+
+		It("should not warn if the mtu accounts for the IPIP overhead", func() {
+			setNetwork(1440, operatorv1.EncapsulationIPIP)
+			Expect(handleMTUEncapsulation(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+
+		It("should not warn without encapsulation", func() {
+			setNetwork(1500, operatorv1.EncapsulationNone)
+			Expect(handleMTUEncapsulation(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+	})
 })
@@ -1,7 +1,12 @@
 package convert
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -9,6 +14,7 @@ import (
 	operatorv1 "github.com/tigera/operator/api/v1"
 	v1 "github.com/tigera/operator/api/v1"
 	"github.com/tigera/operator/pkg/controller/migration/cni"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -19,7 +25,7 @@ const (
 
 // handleNetwork is a migration handler that validates any network settings that are common across
 // all calico installations regardless of their networking configuration.
-func handleNetwork(c *components, install *operatorv1.Installation) error {
+func handleNetwork(ctx context.Context, c *components, install *operatorv1.Installation) error {
 	// Verify FELIX_DEFAULTENDPOINTTOHOSTACTION is set to Accept because that is what the operator sets it to.
 	if err := c.node.assertEnv(ctx, c.client, containerCalicoNode, "FELIX_DEFAULTENDPOINTTOHOSTACTION", "accept"); err != nil {
 		return err
@@ -30,8 +36,8 @@ func handleNetwork(c *components, install *operatorv1.Installation) error {
 
 // handleCalicoCNI is a migration handler that validates and converts Calico CNI configuration if Calico CNI is in use. This
 // includes verifying that compatible networking backend and IPAM plugin are in use.
-func handleCalicoCNI(c *components, install *operatorv1.Installation) error {
-	plugin, err := getCNIPlugin(c)
+func handleCalicoCNI(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	plugin, err := getCNIPlugin(ctx, c)
 	if err != nil {
 		return err
 	}
@@ -40,6 +46,14 @@ func handleCalicoCNI(c *components, install *operatorv1.Installation) error {
 	}
 
 	if c.cni.CalicoConfig == nil {
+		if getContainer(c.node.Spec.Template.Spec, containerInstallCNI) == nil {
+			return ErrIncompatibleCluster{
+				err: fmt.Sprintf("detected Calico CNI but calico-node has no install-cni container and ConfigMap %s/%s has no %s, so the CNI config can't be read",
+					metav1.NamespaceSystem, calicoConfigName, cniNetworkConfigKey),
+				component: ComponentCNIConfig,
+				fix:       fmt.Sprintf("add the CNI config template to the %s key of ConfigMap %s/%s", cniNetworkConfigKey, metav1.NamespaceSystem, calicoConfigName),
+			}
+		}
 		return ErrIncompatibleCluster{
 			err:       "detected Calico CNI but couldn't find any CNI plugin with type=calico",
 			component: ComponentCNIConfig,
@@ -47,6 +61,15 @@ func handleCalicoCNI(c *components, install *operatorv1.Installation) error {
 		}
 	}
 
+	// the calico plugin connects to the datastore itself, so it must be checked separately from DATASTORE_TYPE on calico-node.
+	if dsType := c.cni.CalicoConfig.DatastoreType; dsType != "" && dsType != "kubernetes" {
+		return ErrIncompatibleCluster{
+			err:       fmt.Sprintf("calico CNI config has datastore_type=%s, only datastore_type=kubernetes is supported", dsType),
+			component: ComponentCNIConfig,
+			fix:       "migrate the cluster to the kubernetes datastore",
+		}
+	}
+
 	if install.Spec.CNI == nil {
 		install.Spec.CNI = &operatorv1.CNISpec{}
 	}
@@ -60,7 +83,7 @@ func handleCalicoCNI(c *components, install *operatorv1.Installation) error {
 		install.Spec.CalicoNetwork = &operatorv1.CalicoNetworkSpec{}
 	}
 
-	netBackend, err := getNetworkingBackend(c.node, c.client)
+	netBackend, err := getNetworkingBackend(ctx, c.node, c.client)
 	if err != nil {
 		return err
 	}
@@ -85,6 +108,20 @@ func handleCalicoCNI(c *components, install *operatorv1.Installation) error {
 		if err := subhandleHostLocalIPAM(netBackend, *c.cni.HostLocalIPAMConfig, install); err != nil {
 			return err
 		}
+
+		if usesPodCIDR(*c.cni.HostLocalIPAMConfig) {
+			enabled, err := nodeControllerEnabled(ctx, c)
+			if err != nil {
+				return err
+			}
+			if !enabled {
+				return ErrIncompatibleCluster{
+					err:       "host-local IPAM with usePodCidr depends on the kube-controllers node controller, but it is not enabled",
+					component: ComponentKubeControllers,
+					fix:       "deploy calico-kube-controllers with 'node' in ENABLED_CONTROLLERS",
+				}
+			}
+		}
 	default:
 		return ErrIncompatibleCluster{
 			err:       fmt.Sprintf("unrecognized IPAM plugin '%s'", c.cni.CalicoConfig.IPAM.Type),
@@ -99,7 +136,7 @@ func handleCalicoCNI(c *components, install *operatorv1.Installation) error {
 	}
 
 	// IP_AUTODETECTION_METHOD
-	if err := handleAutoDetectionMethod(c, install); err != nil {
+	if err := handleAutoDetectionMethod(ctx, c, install); err != nil {
 		return err
 	}
 
@@ -112,6 +149,34 @@ func handleCalicoCNI(c *components, install *operatorv1.Installation) error {
 		install.Spec.CalicoNetwork.HostPorts = &hp
 	}
 
+	// the operator only renders the portmap and bandwidth plugins alongside calico
+	unsupported := []string{}
+	for plugin := range c.cni.Plugins {
+		if plugin != "portmap" && plugin != "bandwidth" {
+			unsupported = append(unsupported, plugin)
+		}
+	}
+	sort.Strings(unsupported)
+	for _, plugin := range unsupported {
+		msg := fmt.Sprintf("CNI plugin '%s' is not supported and will be removed", plugin)
+		if plugin == "tuning" {
+			sysctls, err := getTuningSysctls(c.cni.Plugins[plugin].Bytes)
+			if err != nil {
+				return err
+			}
+			if len(sysctls) != 0 {
+				msg += fmt.Sprintf(", so the operator can not reproduce its sysctls %s", strings.Join(sysctls, ", "))
+			}
+		}
+		if err := c.incompatible(ErrIncompatibleCluster{
+			err:       msg,
+			component: ComponentCNIConfig,
+			fix:       fmt.Sprintf("remove the '%s' plugin from the CNI config", plugin),
+		}); err != nil {
+			return err
+		}
+	}
+
 	if c.cni.ConfigName != "k8s-pod-network" {
 		return ErrIncompatibleCluster{
 			err:       fmt.Sprintf("only 'k8s-pod-network' is supported as CNI name, found %s", c.cni.ConfigName),
@@ -122,38 +187,135 @@ func handleCalicoCNI(c *components, install *operatorv1.Installation) error {
 
 	// Other CNI features
 	if c.cni.CalicoConfig.FeatureControl.FloatingIPs {
-		return ErrIncompatibleCluster{
+		if err := c.incompatible(ErrIncompatibleCluster{
 			err:       "floating IPs not supported",
 			component: ComponentCNIConfig,
 			fix:       "disable 'floating_ips' in the CNI configuration",
+		}); err != nil {
+			return err
 		}
 	}
 	if c.cni.CalicoConfig.FeatureControl.IPAddrsNoIpam {
-		return ErrIncompatibleCluster{
+		if err := c.incompatible(ErrIncompatibleCluster{
 			err:       "IpAddrsNoIpam not supported",
 			component: ComponentCNIConfig,
 			fix:       "disable 'IpAddrsNoIpam' in the CNI configuration",
+		}); err != nil {
+			return err
 		}
 	}
 	if c.cni.CalicoConfig.ContainerSettings.AllowIPForwarding {
-		return ErrIncompatibleCluster{
-			err:       "AllowIPForwarding not supported",
+		fwd := operatorv1.ContainerIPForwardingEnabled
+		install.Spec.CalicoNetwork.ContainerIPForwarding = &fwd
+	} else {
+		fwd := operatorv1.ContainerIPForwardingDisabled
+		install.Spec.CalicoNetwork.ContainerIPForwarding = &fwd
+	}
+
+	// the operator only sets nodename_file_optional for Docker EE, where pods may be created before
+	// calico-node has written the nodename file.
+	if c.cni.CalicoConfig.NodenameFileOptional {
+		c.warn(ComponentCNIConfig, "nodename_file_optional=true will not be carried forward as the operator only sets it for the %s provider, "+
+			"so pod networking will fail until calico-node has written the nodename file", operatorv1.ProviderDockerEE)
+	}
+
+	checkCNIKubeconfig(c)
+	checkCNILogRotation(c)
+
+	// the plugin uses the kubeconfig to reach the API server unless policy.k8s_api_root points it
+	// elsewhere, such as a proxy. The operator never sets it.
+	if root := c.cni.CalicoConfig.Policy.K8sAPIRoot; root != "" && root != defaultK8sAPIRoot {
+		c.warn(ComponentCNIConfig, "policy.k8s_api_root=%s will not be carried forward, the calico plugin will reach the API server "+
+			"using the kubeconfig instead", root)
+	}
+
+	// the operator always configures the calico plugin with kubernetes policy, which both Calico
+	// and policy-only topologies such as Canal depend on.
+	if t := c.cni.CalicoConfig.Policy.PolicyType; t != "" && t != "k8s" {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("unexpected policy type '%s' in the calico CNI plugin config, only 'k8s' is supported", t),
 			component: ComponentCNIConfig,
-			fix:       "disable 'AllowIPForwarding' in the CNI configuration",
-		}
+			fix:       "set the policy type of the calico CNI plugin to 'k8s'",
+		})
 	}
 
 	return nil
 }
 
+// handleBackendEncapsulation is a migration handler which checks that the encapsulation of the converted
+// IP pools can be used with the detected networking backend. It must run after both the backend and the
+// IP pools have been converted. The vxlan and none backends don't run BGP, so they can't program routes for
+// IPIP or unencapsulated pools, and VXLAN pools are expected to be used with the vxlan backend rather than bird.
+// VXLAN CrossSubnet pools are accepted with either backend, as BGP can route traffic within a subnet.
+func handleBackendEncapsulation(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	if install.Spec.CNI == nil || install.Spec.CNI.Type != operatorv1.PluginCalico ||
+		install.Spec.CNI.IPAM == nil || install.Spec.CNI.IPAM.Type != operatorv1.IPAMPluginCalico ||
+		install.Spec.CalicoNetwork == nil || install.Spec.CalicoNetwork.BGP == nil {
+		return nil
+	}
+	bgp := *install.Spec.CalicoNetwork.BGP == operatorv1.BGPEnabled
+	netBackend, err := getNetworkingBackend(ctx, c.node, c.client)
+	if err != nil {
+		return err
+	}
+
+	for _, pool := range install.Spec.CalicoNetwork.IPPools {
+		switch pool.Encapsulation {
+		case operatorv1.EncapsulationIPIP, operatorv1.EncapsulationIPIPCrossSubnet, operatorv1.EncapsulationNone:
+			if !bgp {
+				return ErrIncompatibleCluster{
+					err:       fmt.Sprintf("IPPool %s uses %s encapsulation which requires BGP, but CALICO_NETWORKING_BACKEND is %s", pool.CIDR, pool.Encapsulation, netBackend),
+					component: ComponentIPPools,
+					fix:       "set CALICO_NETWORKING_BACKEND to bird or change the IPPool to use VXLAN encapsulation",
+				}
+			}
+		case operatorv1.EncapsulationVXLANCrossSubnet:
+		case operatorv1.EncapsulationVXLAN:
+			if bgp {
+				if err := c.incompatible(ErrIncompatibleCluster{
+					err:       fmt.Sprintf("IPPool %s uses %s encapsulation, but CALICO_NETWORKING_BACKEND is bird", pool.CIDR, pool.Encapsulation),
+					component: ComponentIPPools,
+					fix:       "set CALICO_NETWORKING_BACKEND to vxlan or change the IPPool to use IPIP encapsulation",
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // handleIPv6 is a migration handler which ensures that IPv6 is configured as expected.
-// since the operator itself does not support IPv6, we verify that IPv6 is disabled.
-func handleIPv6(c *components, _ *operatorv1.Installation) error {
-	if err := c.node.assertEnv(ctx, c.client, containerCalicoNode, "FELIX_IPV6SUPPORT", "false"); err != nil {
+// The operator only enables IPv6 on dual-stack installs, so we verify that IPv6 is enabled
+// if the install is dual-stack, and disabled otherwise. IPv6 support enabled on an install
+// with neither an IPv6 pool nor IPv6 addresses has no effect, so is only warned about.
+func handleIPv6(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	ipv6Support, ip6 := "false", "none"
+	if c.dualStack {
+		ipv6Support, ip6 = "true", "autodetect"
+	}
+
+	if !c.dualStack && !nodeEnvSet(c, "CALICO_IPV6POOL_CIDR") && !nodeEnvSet(c, "IP6_AUTODETECTION_METHOD") {
+		ip6Value, err := getEnv(ctx, c.client, c.node.Spec.Template.Spec, ComponentCalicoNode, containerCalicoNode, "IP6")
+		if err != nil {
+			return err
+		}
+		support, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "FELIX_IPV6SUPPORT")
+		if err != nil {
+			return err
+		}
+		if support != nil && strings.ToLower(*support) == "true" && (ip6Value == nil || strings.ToLower(*ip6Value) == "none") {
+			c.warn(ComponentCalicoNode, "FELIX_IPV6SUPPORT=true has no effect as there is no IPv6 pool or IPv6 address autodetection, "+
+				"so IPv6 support will be disabled")
+			ipv6Support = "true"
+		}
+	}
+
+	if err := c.node.assertEnv(ctx, c.client, containerCalicoNode, "FELIX_IPV6SUPPORT", ipv6Support); err != nil {
 		return err
 	}
 
-	if err := c.node.assertEnv(ctx, c.client, containerCalicoNode, "IP6", "none"); err != nil {
+	if err := c.node.assertEnv(ctx, c.client, containerCalicoNode, "IP6", ip6); err != nil {
 		return err
 	}
 
@@ -162,7 +324,35 @@ func handleIPv6(c *components, _ *operatorv1.Installation) error {
 	return nil
 }
 
-func getNetworkingBackend(node CheckedDaemonSet, client client.Client) (string, error) {
+// handleRouterID is a migration handler which checks CALICO_ROUTER_ID. The operator derives the
+// BGP router ID from the node's IPv4 address, so any other strategy (e.g. 'hash' or an explicit ID)
+// can only be dropped if BGP is not in use. Otherwise, changing the router ID would reset BGP sessions.
+func handleRouterID(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	routerID, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "CALICO_ROUTER_ID")
+	if err != nil {
+		return err
+	}
+	if routerID == nil || *routerID == "" {
+		return nil
+	}
+
+	netBackend, err := getNetworkingBackend(ctx, c.node, c.client)
+	if err != nil {
+		return err
+	}
+	if netBackend == "bird" {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("CALICO_ROUTER_ID=%s is not supported, the router ID would change and reset BGP sessions", *routerID),
+			component: ComponentCalicoNode,
+			fix:       "remove CALICO_ROUTER_ID so that the router ID is derived from the node's IPv4 address",
+		})
+	}
+
+	c.warn(ComponentCalicoNode, "CALICO_ROUTER_ID=%s will not be carried forward, it has no effect since BGP is disabled", *routerID)
+	return nil
+}
+
+func getNetworkingBackend(ctx context.Context, node CheckedDaemonSet, client client.Client) (string, error) {
 	netBackend, err := node.getEnv(ctx, client, containerCalicoNode, "CALICO_NETWORKING_BACKEND")
 	if err != nil {
 		return "", err
@@ -193,7 +383,9 @@ func subhandleCalicoIPAM(netBackend string, cnicfg cni.CalicoConf, install *oper
 	switch netBackend {
 	case "bird":
 		install.Spec.CalicoNetwork.BGP = operatorv1.BGPOptionPtr(operatorv1.BGPEnabled)
-	case "vxlan":
+	case "vxlan", "none":
+		// with Calico IPAM a 'none' backend is a VXLAN-only network without BGP, which is checked against
+		// the encapsulation of the IP pools by handleBackendEncapsulation.
 		install.Spec.CalicoNetwork.BGP = operatorv1.BGPOptionPtr(operatorv1.BGPDisabled)
 	default:
 		return ErrIncompatibleCluster{
@@ -227,6 +419,25 @@ func subhandleCalicoIPAM(netBackend string, cnicfg cni.CalicoConf, install *oper
 	return nil
 }
 
+// getTuningSysctls returns the sysctls set by the tuning plugin config, as sorted key=value pairs.
+func getTuningSysctls(conf []byte) ([]string, error) {
+	var tuning struct {
+		Sysctl map[string]string `json:"sysctl"`
+	}
+	if err := json.Unmarshal(conf, &tuning); err != nil {
+		return nil, ErrIncompatibleCluster{
+			err:       fmt.Sprintf("failed to parse the tuning plugin config: %v", err),
+			component: ComponentCNIConfig,
+		}
+	}
+	sysctls := []string{}
+	for k, v := range tuning.Sysctl {
+		sysctls = append(sysctls, k+"="+v)
+	}
+	sort.Strings(sysctls)
+	return sysctls, nil
+}
+
 // subhandleHostLocalIPAM checks all fields in the Host Local IPAM configuration,
 // if any fields have unexpected values an error message will be returned.
 // The function tries to collect all the errors and report one message.
@@ -286,6 +497,43 @@ func subhandleHostLocalIPAM(netBackend string, ipamcfg cni.HostLocalIPAMConfig,
 	return nil
 }
 
+// usesPodCIDR returns true if the host-local IPAM config allocates addresses from the node's podCIDR.
+func usesPodCIDR(ipamcfg cni.HostLocalIPAMConfig) bool {
+	if ipamcfg.Range != nil && ipamcfg.Range.Subnet == "usePodCidr" {
+		return true
+	}
+	for _, set := range ipamcfg.Ranges {
+		for _, r := range set {
+			if r.Subnet == "usePodCidr" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeControllerEnabled returns true if calico-kube-controllers is deployed with its node controller.
+// kube-controllers enables the node controller when ENABLED_CONTROLLERS is unset. The var is read
+// without marking it as checked since handleCore asserts its value.
+func nodeControllerEnabled(ctx context.Context, c *components) (bool, error) {
+	if c.kubeControllers == nil {
+		return false, nil
+	}
+	enabled, err := getEnv(ctx, c.client, c.kubeControllers.Spec.Template.Spec, ComponentKubeControllers, containerKubeControllers, "ENABLED_CONTROLLERS")
+	if err != nil {
+		return false, err
+	}
+	if enabled == nil {
+		return true, nil
+	}
+	for _, ctrl := range strings.Split(*enabled, ",") {
+		if strings.TrimSpace(ctrl) == "node" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // checkRange checks the fields in r for invalid values for HostLocal IPAM configuration.
 func checkRange(prefix string, r cni.Range) []string {
 	bf := []string{}
@@ -307,10 +555,61 @@ func checkRange(prefix string, r cni.Range) []string {
 	return bf
 }
 
+// defaultK8sAPIRoot is the policy.k8s_api_root set by older manifests, which install-cni substitutes
+// with the address of the kubernetes service, the same address the kubeconfig points to.
+const defaultK8sAPIRoot = "https://__KUBERNETES_SERVICE_HOST__:__KUBERNETES_SERVICE_PORT__"
+
+// checkCNIKubeconfig warns if the calico plugin reads its kubeconfig from anywhere other than the
+// calico-kubeconfig which install-cni writes to the CNI network config directory. The operator always
+// points the plugin at that file, so a kubeconfig elsewhere will no longer be used after migration.
+func checkCNIKubeconfig(c *components) {
+	kubeconfig := c.cni.CalicoConfig.Kubernetes.Kubeconfig
+	if kubeconfig == "" || kubeconfig == "__KUBECONFIG_FILEPATH__" {
+		return
+	}
+
+	netDir := expectedCNIDirectories(c.provider).net
+	if v := getVolume(c.node.Spec.Template.Spec, "cni-net-dir"); v != nil && v.HostPath != nil {
+		netDir = v.HostPath.Path
+	}
+	expected := path.Join(netDir, "calico-kubeconfig")
+	if path.Clean(kubeconfig) != expected {
+		c.warn(ComponentCNIConfig, "kubernetes.kubeconfig=%s will not be carried forward, the calico plugin will use the kubeconfig at %s",
+			kubeconfig, expected)
+	}
+}
+
+// The log rotation defaults of the calico plugin, which the operator relies on by never setting them.
+const (
+	defaultCNILogFileMaxSize  = 100
+	defaultCNILogFileMaxAge   = 30
+	defaultCNILogFileMaxCount = 10
+)
+
+// checkCNILogRotation warns if the calico plugin rotates its log file with settings other than the
+// plugin's defaults, as the operator will not carry them forward.
+func checkCNILogRotation(c *components) {
+	conf := c.cni.CalicoConfig
+	for _, s := range []struct {
+		key          string
+		value, deflt int
+	}{
+		{"log_file_max_size", conf.LogFileMaxSize, defaultCNILogFileMaxSize},
+		{"log_file_max_age", conf.LogFileMaxAge, defaultCNILogFileMaxAge},
+		{"log_file_max_count", conf.LogFileMaxCount, defaultCNILogFileMaxCount},
+	} {
+		// unset is the same as the default.
+		if s.value != 0 && s.value != s.deflt {
+			c.warn(ComponentCNIConfig, "%s=%d will not be carried forward, the calico plugin will use the default of %d",
+				s.key, s.value, s.deflt)
+		}
+	}
+}
+
 // handleCalicoCNI is a migration handler that handles all CNI plugins excluding calico-cni.
 // This includes verifying that compatible networking backend and IPAM plugin are in use.
-func handleNonCalicoCNI(c *components, install *operatorv1.Installation) error {
-	plugin, err := getCNIPlugin(c)
+func handleNonCalicoCNI(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	plugin, err := getCNIPlugin(ctx, c)
 	if err != nil {
 		return err
 	}
@@ -375,19 +674,99 @@ func handleNonCalicoCNI(c *components, install *operatorv1.Installation) error {
 		return err
 	}
 
+	// IP addresses are managed by the other CNI plugin, so set an empty list of pools rather than leaving
+	// it nil, which would cause the operator to create the default 192.168.0.0/16 pool. Any pools found
+	// in the datastore are still added by handleIPPools.
+	if install.Spec.CalicoNetwork == nil {
+		install.Spec.CalicoNetwork = &operatorv1.CalicoNetworkSpec{}
+	}
+	if install.Spec.CalicoNetwork.IPPools == nil {
+		install.Spec.CalicoNetwork.IPPools = []operatorv1.IPPool{}
+	}
+
+	return nil
+}
+
+// handleRouteSource is a migration handler which carries FELIX_ROUTESOURCE onto the FelixConfiguration,
+// checking it against the CNI plugin. The operator sets FELIX_ROUTESOURCE=WorkloadIPs on calico-node when
+// not using Calico CNI, which overrides the FelixConfiguration, so any other value can't be carried forward.
+func handleRouteSource(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	routeSource, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "FELIX_ROUTESOURCE")
+	if err != nil {
+		return err
+	}
+	if routeSource == nil {
+		return nil
+	}
+	if err := felixVarValidators["routesource"](*routeSource); err != nil {
+		return c.incompatible(ErrIncompatibleCluster{
+			err:       fmt.Sprintf("FELIX_ROUTESOURCE is not valid: %v", err),
+			component: ComponentCalicoNode,
+			fix:       "correct or remove FELIX_ROUTESOURCE",
+		})
+	}
+	c.felixConfig.set("routeSource", *routeSource)
+	if *routeSource == routeSourceWorkloadIPs {
+		return nil
+	}
+
+	plugin, err := getCNIPlugin(ctx, c)
+	if err != nil {
+		return err
+	}
+	if plugin != operatorv1.PluginCalico {
+		c.warn(ComponentCalicoNode, "FELIX_ROUTESOURCE=%s will be overridden, the operator sets it to %s when not using Calico CNI",
+			*routeSource, routeSourceWorkloadIPs)
+	}
 	return nil
 }
 
-// getAutoDetection auto-detects the IP and Network using the requested
-// detection method.
-func handleAutoDetectionMethod(c *components, install *operatorv1.Installation) error {
-	method, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "IP_AUTODETECTION_METHOD")
+// handleAutoDetectionMethod converts IP_AUTODETECTION_METHOD into the IPv4 address autodetection
+// of the Installation, and on dual-stack installs, IP6_AUTODETECTION_METHOD into the IPv6 address
+// autodetection. The operator only autodetects IPv6 addresses on dual-stack installs.
+func handleAutoDetectionMethod(ctx context.Context, c *components, install *operatorv1.Installation) error {
+	v4, err := getAutoDetectionMethod(ctx, c, "IP_AUTODETECTION_METHOD")
 	if err != nil {
 		return err
 	}
-	if method == nil {
+	if v4 != nil {
+		install.Spec.CalicoNetwork.NodeAddressAutodetectionV4 = v4
+	}
+	if !c.dualStack {
 		return nil
 	}
+	v6, err := getAutoDetectionMethod(ctx, c, "IP6_AUTODETECTION_METHOD")
+	if err != nil {
+		return err
+	}
+	if v6 != nil {
+		install.Spec.CalicoNetwork.NodeAddressAutodetectionV6 = v6
+	}
+	return nil
+}
+
+// getAutoDetectionMethod returns the address autodetection set by the given autodetection method env var,
+// or nil if it is not set.
+func getAutoDetectionMethod(ctx context.Context, c *components, key string) (*operatorv1.NodeAddressAutodetection, error) {
+	method, err := c.node.getEnv(ctx, c.client, containerCalicoNode, key)
+	if err != nil || method == nil {
+		return nil, err
+	}
+	return parseAutoDetectionMethod(c, key, *method)
+}
+
+// parseAutoDetectionMethod parses the value of the autodetection method env var named by key, i.e.
+// IP_AUTODETECTION_METHOD or IP6_AUTODETECTION_METHOD, which both accept the same methods.
+func parseAutoDetectionMethod(c *components, key, method string) (*operatorv1.NodeAddressAutodetection, error) {
+	// values sourced from a ConfigMap often carry surrounding whitespace.
+	method = strings.TrimSpace(method)
+	if strings.ContainsAny(method, "\r\n") {
+		return nil, ErrIncompatibleCluster{
+			err:       fmt.Sprintf("%s=%q spans multiple lines", key, method),
+			component: ComponentCalicoNode,
+			fix:       fmt.Sprintf("set %s to a single autodetection method", key),
+		}
+	}
 
 	const (
 		AutodetectionMethodFirst         = "first-found"
@@ -397,41 +776,99 @@ func handleAutoDetectionMethod(c *components, install *operatorv1.Installation)
 	)
 
 	// first-found
-	if *method == "" || *method == AutodetectionMethodFirst {
+	if method == "" || method == AutodetectionMethodFirst {
 		var t = true
-		install.Spec.CalicoNetwork.NodeAddressAutodetectionV4 = &operatorv1.NodeAddressAutodetection{FirstFound: &t}
-		return nil
+		return &operatorv1.NodeAddressAutodetection{FirstFound: &t}, nil
 	}
 
 	// interface
-	if strings.HasPrefix(*method, AutodetectionMethodInterface) {
-		ifStr := strings.TrimPrefix(*method, AutodetectionMethodInterface)
-		install.Spec.CalicoNetwork.NodeAddressAutodetectionV4 = &operatorv1.NodeAddressAutodetection{Interface: ifStr}
-		return nil
+	if strings.HasPrefix(method, AutodetectionMethodInterface) {
+		ifStr, err := joinInterfaceRegexes(key, strings.TrimPrefix(method, AutodetectionMethodInterface))
+		if err != nil {
+			return nil, err
+		}
+		return &operatorv1.NodeAddressAutodetection{Interface: ifStr}, nil
 	}
 
 	// can-reach
-	if strings.HasPrefix(*method, AutodetectionMethodCanReach) {
-		dest := strings.TrimPrefix(*method, AutodetectionMethodCanReach)
-		install.Spec.CalicoNetwork.NodeAddressAutodetectionV4 = &operatorv1.NodeAddressAutodetection{CanReach: dest}
-		return nil
+	if strings.HasPrefix(method, AutodetectionMethodCanReach) {
+		dest := strings.TrimPrefix(method, AutodetectionMethodCanReach)
+		return &operatorv1.NodeAddressAutodetection{CanReach: dest}, nil
 	}
 
 	// skip-interface
-	if strings.HasPrefix(*method, AutodetectionMethodSkipInterface) {
-		ifStr := strings.TrimPrefix(*method, AutodetectionMethodSkipInterface)
-		install.Spec.CalicoNetwork.NodeAddressAutodetectionV4 = &operatorv1.NodeAddressAutodetection{SkipInterface: ifStr}
-		return nil
+	if strings.HasPrefix(method, AutodetectionMethodSkipInterface) {
+		kept, dropped := stripDefaultSkipInterfaces(strings.TrimPrefix(method, AutodetectionMethodSkipInterface))
+		if len(dropped) != 0 {
+			c.warn(ComponentCalicoNode, "%s skip-interface patterns %s are always excluded by calico-node and will not be migrated",
+				key, strings.Join(dropped, ","))
+		}
+		if len(kept) == 0 {
+			// first-found excludes the same default interfaces.
+			var t = true
+			return &operatorv1.NodeAddressAutodetection{FirstFound: &t}, nil
+		}
+		ifStr, err := joinInterfaceRegexes(key, strings.Join(kept, ","))
+		if err != nil {
+			return nil, err
+		}
+		return &operatorv1.NodeAddressAutodetection{SkipInterface: ifStr}, nil
 	}
 
-	return ErrIncompatibleCluster{
-		err:       fmt.Sprintf("IP_AUTODETECTION_METHOD=%s is not supported", *method),
+	return nil, ErrIncompatibleCluster{
+		err:       fmt.Sprintf("%s=%s is not supported", key, method),
 		component: ComponentCalicoNode,
-		fix:       "remove the IP_AUTODETECTION_METHOD env var or set it to 'first-found', 'can-reach=*', 'interface=*', or 'skip-interface=*'",
+		fix:       fmt.Sprintf("remove the %s env var or set it to 'first-found', 'can-reach=*', 'interface=*', or 'skip-interface=*'", key),
+	}
+}
+
+// defaultSkipInterfaces are the interface regexes which calico-node's autodetection always excludes,
+// in addition to any given by the skip-interface= autodetection method.
+var defaultSkipInterfaces = []string{
+	"docker.*", "cbr.*", "dummy.*", "virbr.*", "lxcbr.*", "veth.*", "lo",
+	"cali.*", "tunl.*", "flannel.*", "kube-ipvs.*", "cni.*", "vxlan.calico.*", "wireguard.cali.*",
+}
+
+// stripDefaultSkipInterfaces splits the comma-separated list of skip-interface regexes into those
+// which are meaningful to keep and those which only repeat one of calico-node's default exclusions.
+// calico-node anchors the default exclusions, so a pattern is considered a repeat with or without anchors.
+func stripDefaultSkipInterfaces(list string) (kept, dropped []string) {
+	for _, r := range strings.Split(list, ",") {
+		unanchored := strings.TrimSuffix(strings.TrimPrefix(r, "^"), "$")
+		isDefault := false
+		for _, d := range defaultSkipInterfaces {
+			if unanchored == d {
+				isDefault = true
+				break
+			}
+		}
+		if isDefault {
+			dropped = append(dropped, r)
+		} else {
+			kept = append(kept, r)
+		}
+	}
+	return kept, dropped
+}
+
+// joinInterfaceRegexes validates the comma-separated list of interface regexes accepted by calico-node's
+// interface= and skip-interface= autodetection methods, and joins them into the single regex
+// expected by the Installation.
+func joinInterfaceRegexes(key, list string) (string, error) {
+	regexes := strings.Split(list, ",")
+	for _, r := range regexes {
+		if _, err := regexp.Compile(r); err != nil {
+			return "", ErrIncompatibleCluster{
+				err:       fmt.Sprintf("%s contains an invalid interface regex '%s': %v", key, r, err),
+				component: ComponentCalicoNode,
+				fix:       fmt.Sprintf("correct the regex in %s", key),
+			}
+		}
 	}
+	return strings.Join(regexes, "|"), nil
 }
 
-func getCNIPlugin(c *components) (operatorv1.CNIPluginType, error) {
+func getCNIPlugin(ctx context.Context, c *components) (operatorv1.CNIPluginType, error) {
 	prefix, err := c.node.getEnv(ctx, c.client, containerCalicoNode, "FELIX_INTERFACEPREFIX")
 	if err != nil {
 		return "", err
@@ -450,9 +887,13 @@ func getCNIPlugin(c *components) (operatorv1.CNIPluginType, error) {
 	case "cali":
 		return operatorv1.PluginCalico, nil
 	default:
+		// the operator sets the prefix based on the CNI plugin, so any other prefix would change the
+		// name felix expects for workload interfaces and orphan the existing workload endpoints.
 		return "", ErrIncompatibleCluster{
-			err:       fmt.Sprintf("unexpected FELIX_INTERFACEPREFIX value: '%s'. Only 'eni, azv, gke, cali' are supported.", *prefix),
+			err: fmt.Sprintf("unexpected FELIX_INTERFACEPREFIX value: '%s'. Only 'eni, azv, gke, cali' are supported. "+
+				"The operator sets the interface prefix based on the CNI plugin, so changing it would orphan existing workload endpoints", *prefix),
 			component: ComponentCalicoNode,
+			fix:       "migrate workloads to one of the supported interface prefixes before migrating",
 		}
 	}
 }
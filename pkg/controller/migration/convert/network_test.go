@@ -3,6 +3,7 @@ package convert
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -11,14 +12,17 @@ import (
 	operatorv1 "github.com/tigera/operator/api/v1"
 	"github.com/tigera/operator/pkg/apis"
 	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+	"github.com/tigera/operator/pkg/controller/migration/cni"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -59,10 +63,12 @@ var _ = Describe("Convert network tests", func() {
 			})
 
 			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(cfg).ToNot(BeNil())
 			Expect(cfg.Spec.CNI.Type).To(Equal(plugin))
+			// a none backend with another CNI plugin is policy-only, so no networking is configured.
+			Expect(cfg.Spec.CalicoNetwork.BGP).To(BeNil())
 		},
 			Entry("AzureVNET", []corev1.EnvVar{{Name: "FELIX_INTERFACEPREFIX", Value: "azv"}}, operatorv1.PluginAzureVNET),
 			Entry("AmazonVPC", []corev1.EnvVar{
@@ -75,23 +81,149 @@ var _ = Describe("Convert network tests", func() {
 				{Name: "FELIX_IPTABLESFILTERALLOWACTION", Value: "Return"},
 			}, operatorv1.PluginGKE),
 		)
+		DescribeTable("custom interface prefixes", func(prefix string) {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_INTERFACEPREFIX", Value: prefix}}
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("unexpected FELIX_INTERFACEPREFIX value: '%s'", prefix)))
+			Expect(err.Error()).To(ContainSubstring("would orphan existing workload endpoints"))
+		},
+			Entry("custom prefix", "wl"),
+			Entry("multiple prefixes", "cali,tap"),
+		)
+		It("should accept the default interface prefix", func() {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_INTERFACEPREFIX", Value: "cali"}}
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
+		})
 		It("should convert AWS CNI install", func() {
 			c := fake.NewFakeClientWithScheme(scheme, append([]runtime.Object{pool, emptyFelixConfig(), getK8sNodes(6)}, awsCNIPolicyOnlyConfig()...)...)
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 		})
+		It("should set an empty list of IPPools for AWS CNI without pools", func() {
+			c := fake.NewFakeClientWithScheme(scheme, append([]runtime.Object{emptyFelixConfig(), getK8sNodes(6)}, awsCNIPolicyOnlyConfig()...)...)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Spec.CalicoNetwork).ToNot(BeNil())
+			Expect(cfg.Spec.CalicoNetwork.IPPools).ToNot(BeNil())
+			Expect(cfg.Spec.CalicoNetwork.IPPools).To(BeEmpty())
+		})
+		It("should keep existing IPPools for AWS CNI", func() {
+			c := fake.NewFakeClientWithScheme(scheme, append([]runtime.Object{pool, emptyFelixConfig(), getK8sNodes(6)}, awsCNIPolicyOnlyConfig()...)...)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
+			Expect(cfg.Spec.CalicoNetwork.IPPools[0].CIDR).To(Equal(pool.Spec.CIDR))
+		})
+		Context("aws-node settings", func() {
+			convertAWS := func(env ...corev1.EnvVar) (*Report, error) {
+				c := fake.NewFakeClientWithScheme(scheme, append([]runtime.Object{pool, emptyFelixConfig(), getK8sNodes(6), awsNodeDaemonSet(env...)}, awsCNIPolicyOnlyConfig()...)...)
+				_, report, err := ConvertWithReport(ctx, c, Options{})
+				return report, err
+			}
+
+			It("should not warn for the default aws-node settings", func() {
+				report, err := convertAWS()
+				Expect(err).NotTo(HaveOccurred())
+				for _, w := range report.Warnings {
+					Expect(w.Component).ToNot(Equal(ComponentAWSNode))
+				}
+			})
+			It("should accept the eni veth prefix", func() {
+				_, err := convertAWS(corev1.EnvVar{Name: "AWS_VPC_K8S_CNI_VETHPREFIX", Value: "eni"})
+				Expect(err).NotTo(HaveOccurred())
+			})
+			It("should error for a custom veth prefix", func() {
+				_, err := convertAWS(corev1.EnvVar{Name: "AWS_VPC_K8S_CNI_VETHPREFIX", Value: "vpc"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("AWS_VPC_K8S_CNI_VETHPREFIX=vpc is not supported"))
+			})
+			It("should warn about external SNAT", func() {
+				report, err := convertAWS(corev1.EnvVar{Name: "AWS_VPC_K8S_CNI_EXTERNALSNAT", Value: "true"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Warnings).To(ContainElement(Warning{
+					Component: ComponentAWSNode,
+					Message:   "AWS_VPC_K8S_CNI_EXTERNALSNAT=true, pod traffic leaving the VPC is not SNATed on the node, so destinations outside the VPC will see pod IPs rather than node IPs",
+				}))
+			})
+			It("should warn about security groups for pods", func() {
+				report, err := convertAWS(corev1.EnvVar{Name: "ENABLE_POD_ENI", Value: "true"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Warnings).To(ContainElement(Warning{
+					Component: ComponentAWSNode,
+					Message:   "ENABLE_POD_ENI=true, Calico policy is not enforced for pods which use security groups for pods",
+				}))
+			})
+		})
 	})
 
 	Describe("handle Calico CNI migration", func() {
 		It("migrate default", func() {
 			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(cfg).ToNot(BeNil())
 			Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
 			Expect(cfg.Spec.CNI.IPAM.Type).To(Equal(operatorv1.IPAMPluginCalico))
 			Expect(*cfg.Spec.CalicoNetwork.BGP).To(Equal(operatorv1.BGPEnabled))
 		})
+		Context("unsupported CNI plugins", func() {
+			var c client.Client
+			BeforeEach(func() {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: ConfigList(`{"type": "calico", "ipam": {"type": "calico-ipam"}},
+						{"type": "tuning", "sysctl": {"net.core.somaxconn": "500"}}`),
+				}}
+				c = fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			})
+			It("should error in strict mode", func() {
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("CNI plugin 'tuning' is not supported"))
+			})
+			It("should warn in lenient mode", func() {
+				cfg, report, err := ConvertWithReport(ctx, c, Options{Mode: ModeLenient})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
+				Expect(report.Warnings).To(ContainElement(Warning{
+					Component: ComponentCNIConfig,
+					Message: "CNI plugin 'tuning' is not supported and will be removed, so the operator can not reproduce its sysctls net.core.somaxconn=500. " +
+						"To fix it, remove the 'tuning' plugin from the CNI config",
+				}))
+			})
+			It("should list each sysctl of the tuning plugin", func() {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: ConfigList(`{"type": "calico", "ipam": {"type": "calico-ipam"}},
+						{"type": "tuning", "sysctl": {"net.ipv4.conf.all.arp_ignore": "1", "net.core.somaxconn": "500"}}`),
+				}}
+				c = fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("the operator can not reproduce its sysctls net.core.somaxconn=500, net.ipv4.conf.all.arp_ignore=1"))
+			})
+			It("should not list sysctls for a tuning plugin without any", func() {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: ConfigList(`{"type": "calico", "ipam": {"type": "calico-ipam"}},
+						{"type": "tuning", "mtu": 1400}`),
+				}}
+				c = fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("CNI plugin 'tuning' is not supported and will be removed. "))
+			})
+		})
 		It("should convert Calico v3.15 manifest", func() {
 			pool = crdv1.NewIPPool()
 			pool.Spec = crdv1.IPPoolSpec{
@@ -100,26 +232,28 @@ var _ = Describe("Convert network tests", func() {
 				NATOutgoing: true,
 			}
 			c := fake.NewFakeClientWithScheme(scheme, append([]runtime.Object{pool, emptyFelixConfig()}, calicoDefaultConfig()...)...)
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 			var _1440 int32 = 1440
 			_1intstr := intstr.FromInt(1)
+			fwd := operatorv1.ContainerIPForwardingDisabled
 			Expect(*cfg).To(Equal(operatorv1.Installation{Spec: operatorv1.InstallationSpec{
 				CNI: &operatorv1.CNISpec{
 					Type: operatorv1.PluginCalico,
 					IPAM: &operatorv1.IPAMSpec{Type: operatorv1.IPAMPluginCalico},
 				},
 				CalicoNetwork: &operatorv1.CalicoNetworkSpec{
-					BGP:       operatorv1.BGPOptionPtr(operatorv1.BGPEnabled),
-					MTU:       &_1440,
-					HostPorts: operatorv1.HostPortsTypePtr(operatorv1.HostPortsEnabled),
+					BGP:                   operatorv1.BGPOptionPtr(operatorv1.BGPEnabled),
+					MTU:                   &_1440,
+					HostPorts:             operatorv1.HostPortsTypePtr(operatorv1.HostPortsEnabled),
+					ContainerIPForwarding: &fwd,
 					IPPools: []operatorv1.IPPool{{
 						CIDR:          "192.168.4.0/24",
 						Encapsulation: operatorv1.EncapsulationIPIP,
 						NATOutgoing:   operatorv1.NATOutgoingEnabled,
 					}},
 				},
-				FlexVolumePath: "/usr/libexec/kubernetes/kubelet-plugins/volume/exec/nodeagent~uds",
+				FlexVolumePath: "/usr/libexec/kubernetes/kubelet-plugins/volume/exec/",
 				NodeUpdateStrategy: appsv1.DaemonSetUpdateStrategy{
 					Type: "RollingUpdate",
 					RollingUpdate: &appsv1.RollingUpdateDaemonSet{
@@ -147,7 +281,7 @@ var _ = Describe("Convert network tests", func() {
 				Value: "none",
 			}}
 			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(cfg).ToNot(BeNil())
 			Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
@@ -164,14 +298,98 @@ var _ = Describe("Convert network tests", func() {
 				Name:  "CALICO_NETWORKING_BACKEND",
 				Value: "vxlan",
 			}}
+			pool.Spec.IPIPMode = crdv1.IPIPModeNever
+			pool.Spec.VXLANMode = crdv1.VXLANModeAlways
 			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-			cfg, err := Convert(ctx, c)
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(cfg).ToNot(BeNil())
 			Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
 			Expect(cfg.Spec.CNI.IPAM.Type).To(Equal(operatorv1.IPAMPluginCalico))
 			Expect(*cfg.Spec.CalicoNetwork.BGP).To(Equal(operatorv1.BGPDisabled))
 		})
+		DescribeTable("backend and encapsulation",
+			func(backend, ipip, vxlan string, valid bool) {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+					Name:  "CALICO_NETWORKING_BACKEND",
+					Value: backend,
+				}}
+				pool.Spec.IPIPMode = crdv1.IPIPMode(ipip)
+				pool.Spec.VXLANMode = crdv1.VXLANMode(vxlan)
+				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
+				if valid {
+					Expect(err).ToNot(HaveOccurred())
+				} else {
+					Expect(err).To(HaveOccurred())
+				}
+			},
+			Entry("bird and ipip", "bird", "Always", "Never", true),
+			Entry("bird and ipip cross subnet", "bird", "CrossSubnet", "Never", true),
+			Entry("bird and no encapsulation", "bird", "Never", "Never", true),
+			Entry("bird and vxlan", "bird", "Never", "Always", false),
+			Entry("bird and vxlan cross subnet", "bird", "Never", "CrossSubnet", true),
+			Entry("vxlan and vxlan", "vxlan", "Never", "Always", true),
+			Entry("vxlan and vxlan cross subnet", "vxlan", "Never", "CrossSubnet", true),
+			Entry("vxlan and ipip", "vxlan", "Always", "Never", false),
+			Entry("vxlan and no encapsulation", "vxlan", "Never", "Never", false),
+			Entry("none and vxlan", "none", "Never", "Always", true),
+			Entry("none and vxlan cross subnet", "none", "Never", "CrossSubnet", true),
+			Entry("none and ipip", "none", "Always", "Never", false),
+			Entry("none and no encapsulation", "none", "Never", "Never", false),
+		)
+		It("should convert a none backend with vxlan pools to a vxlan network without bgp", func() {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+				Name:  "CALICO_NETWORKING_BACKEND",
+				Value: "none",
+			}}
+			pool.Spec.IPIPMode = crdv1.IPIPModeNever
+			pool.Spec.VXLANMode = crdv1.VXLANModeAlways
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
+			Expect(cfg.Spec.CNI.IPAM.Type).To(Equal(operatorv1.IPAMPluginCalico))
+			Expect(*cfg.Spec.CalicoNetwork.BGP).To(Equal(operatorv1.BGPDisabled))
+			Expect(cfg.Spec.CalicoNetwork.IPPools).To(HaveLen(1))
+			Expect(cfg.Spec.CalicoNetwork.IPPools[0].Encapsulation).To(Equal(operatorv1.EncapsulationVXLAN))
+		})
+		It("should name the none backend when its pools require bgp", func() {
+			ds := emptyNodeSpec()
+			ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+				Name:  "CALICO_NETWORKING_BACKEND",
+				Value: "none",
+			}}
+			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("uses IPIP encapsulation which requires BGP, but CALICO_NETWORKING_BACKEND is none"))
+		})
+		It("should accept vxlan cross subnet with bird without warnings", func() {
+			pool.Spec.IPIPMode = crdv1.IPIPModeNever
+			pool.Spec.VXLANMode = crdv1.VXLANModeCrossSubnet
+			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			cfg, report, err := ConvertWithReport(ctx, c, Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*cfg.Spec.CalicoNetwork.BGP).To(Equal(operatorv1.BGPEnabled))
+			Expect(cfg.Spec.CalicoNetwork.IPPools[0].Encapsulation).To(Equal(operatorv1.EncapsulationVXLANCrossSubnet))
+			for _, w := range report.Warnings {
+				Expect(w.Component).ToNot(Equal(ComponentIPPools))
+			}
+		})
+		It("should only warn about bird and vxlan when lenient", func() {
+			pool.Spec.IPIPMode = crdv1.IPIPModeNever
+			pool.Spec.VXLANMode = crdv1.VXLANModeAlways
+			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig())
+			_, report, err := ConvertWithReport(ctx, c, Options{Mode: ModeLenient})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Warnings).To(ContainElement(Warning{
+				Component: ComponentIPPools,
+				Message:   "IPPool 192.168.4.0/24 uses VXLAN encapsulation, but CALICO_NETWORKING_BACKEND is bird. To fix it, set CALICO_NETWORKING_BACKEND to vxlan or change the IPPool to use IPIP encapsulation",
+			}))
+		})
 		DescribeTable("test invalid ipam and backend",
 			func(ipam, backend string) {
 				ds := emptyNodeSpec()
@@ -184,7 +402,7 @@ var _ = Describe("Convert network tests", func() {
 					Value: backend,
 				}}
 				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-				_, err := Convert(ctx, c)
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
 				Expect(err).To(HaveOccurred())
 			},
 			Entry("host-local and vxlan", "host-local", "vxlan"),
@@ -201,7 +419,7 @@ var _ = Describe("Convert network tests", func() {
 				Value: "none",
 			}}
 			c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).To(HaveOccurred())
 		})
 		Context("HostLocal IPAM", func() {
@@ -217,7 +435,7 @@ var _ = Describe("Convert network tests", func() {
 						Value: backend,
 					}}
 					c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-					cfg, err := Convert(ctx, c)
+					cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(cfg).ToNot(BeNil())
 					Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
@@ -239,7 +457,7 @@ var _ = Describe("Convert network tests", func() {
 						Value: "bird",
 					}}
 					c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-					_, err := Convert(ctx, c)
+					_, err := Convert(ctx, c, operatorv1.ProviderNone)
 					Expect(err).NotTo(HaveOccurred())
 				},
 				Entry("name in conflist", `{"name": "k8s-pod-network",
@@ -259,8 +477,52 @@ var _ = Describe("Convert network tests", func() {
 		"nodename": "__KUBERNETES_NODE_NAME__",
 		"ipam": {"type": "host-local"},
 		"policy": {"type": "k8s"}
+  }`),
+				Entry("conflist wrapped in a top-level key", `{"conflist": {"name": "k8s-pod-network",
+	"plugins": [
+	  {
+		"type": "calico",
+		"datastore_type": "kubernetes",
+		"nodename": "__KUBERNETES_NODE_NAME__",
+		"ipam": {"type": "host-local"},
+		"policy": {"type": "k8s"}
+	  }
+	]
+  }}`),
+				Entry("single plugin object in conflist", `{"name": "k8s-pod-network",
+	"plugins": {
+		"type": "calico",
+		"datastore_type": "kubernetes",
+		"nodename": "__KUBERNETES_NODE_NAME__",
+		"ipam": {"type": "host-local"},
+		"policy": {"type": "k8s"}
+	}
+  }`),
+				Entry("numeric cniVersion", `{"name": "k8s-pod-network",
+	"cniVersion": 0.3,
+	"plugins": [
+	  {
+		"cniVersion": 0.3,
+		"type": "calico",
+		"datastore_type": "kubernetes",
+		"nodename": "__KUBERNETES_NODE_NAME__",
+		"ipam": {"type": "host-local"},
+		"policy": {"type": "k8s"}
+	  }
+	]
   }`),
 			)
+			It("should describe the problem with an unrecoverable plugins list", func() {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name:  "CNI_NETWORK_CONFIG",
+					Value: `{"name": "k8s-pod-network", "plugins": "calico"}`,
+				}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("plugins"))
+			})
 			DescribeTable("test bad CNI config name",
 				func(cni string) {
 					ds := emptyNodeSpec()
@@ -273,7 +535,7 @@ var _ = Describe("Convert network tests", func() {
 						Value: "bird",
 					}}
 					c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-					_, err := Convert(ctx, c)
+					_, err := Convert(ctx, c, operatorv1.ProviderNone)
 					Expect(err).To(HaveOccurred())
 				},
 				Entry("no name in conflist", `{
@@ -347,7 +609,7 @@ var _ = Describe("Convert network tests", func() {
 						Value: "bird",
 					}}
 					c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-					_, err := Convert(ctx, c)
+					_, err := Convert(ctx, c, operatorv1.ProviderNone)
 					Expect(err).To(HaveOccurred())
 				},
 				Entry("ranges", `"ranges": [[{ "subnet": "usePodCidr" }],[{ "subnet": "2001:db8::/96" }]]`),
@@ -389,12 +651,68 @@ var _ = Describe("Convert network tests", func() {
 						Value: "bird",
 					}}
 					c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-					_, err := Convert(ctx, c)
+					_, err := Convert(ctx, c, operatorv1.ProviderNone)
 					Expect(err).NotTo(HaveOccurred())
 				},
 				Entry("subnet in ipam section", `"subnet": "usePodCidr"`),
 				Entry("subnet in ranges section under ipam", `"ranges": [[{ "subnet": "usePodCidr" }]]`),
 			)
+			Describe("usePodCidr node controller dependency", func() {
+				var ds *appsv1.DaemonSet
+				BeforeEach(func() {
+					ds = emptyNodeSpec()
+					ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+						Name: "CNI_NETWORK_CONFIG",
+						Value: `{
+	"name": "k8s-pod-network",
+	"cniVersion": "0.3.1",
+	"plugins": [
+	  {
+		"type": "calico",
+		"datastore_type": "kubernetes",
+		"ipam": {
+			"type": "host-local",
+			"subnet": "usePodCidr"
+		},
+		"policy": {
+			"type": "k8s"
+		}
+	  }
+	]
+  }`,
+					}}
+					ds.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+						Name:  "CALICO_NETWORKING_BACKEND",
+						Value: "bird",
+					}}
+				})
+				It("should error if kube-controllers is not deployed", func() {
+					c := fake.NewFakeClientWithScheme(scheme, ds, pool, emptyFelixConfig())
+					_, err := Convert(ctx, c, operatorv1.ProviderNone)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("depends on the kube-controllers node controller, but it is not enabled"))
+				})
+				DescribeTable("ENABLED_CONTROLLERS", func(enabled string, valid bool) {
+					kc := emptyKubeControllerSpec()
+					kc.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+						Name:  "ENABLED_CONTROLLERS",
+						Value: enabled,
+					}}
+					comps, err := getComponents(ctx, fake.NewFakeClientWithScheme(scheme, ds, kc, pool, emptyFelixConfig()))
+					Expect(err).ToNot(HaveOccurred())
+					err = handleCalicoCNI(ctx, comps, &operatorv1.Installation{})
+					if valid {
+						Expect(err).ToNot(HaveOccurred())
+					} else {
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("depends on the kube-controllers node controller"))
+					}
+				},
+					Entry("node", "node", true),
+					Entry("node among others", "policy, node", true),
+					Entry("without node", "policy,namespace", false),
+				)
+			})
 		})
 
 		Context("Calico CNI config flags", func() {
@@ -431,7 +749,7 @@ var _ = Describe("Convert network tests", func() {
 						Value: "bird",
 					}}
 					c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-					cfg, err := Convert(ctx, c)
+					cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(cfg).ToNot(BeNil())
 					Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
@@ -476,7 +794,7 @@ var _ = Describe("Convert network tests", func() {
 						Value: "bird",
 					}}
 					c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-					cfg, err := Convert(ctx, c)
+					cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(cfg).ToNot(BeNil())
 					Expect(cfg.Spec.CNI.Type).To(Equal(operatorv1.PluginCalico))
@@ -485,6 +803,218 @@ var _ = Describe("Convert network tests", func() {
 					Expect(*cfg.Spec.CalicoNetwork.HostPorts).To(Equal(operatorv1.HostPortsEnabled))
 				})
 			})
+			DescribeTable("migrate allow_ip_forwarding", func(settings string, expected operatorv1.ContainerIPForwardingType) {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: fmt.Sprintf(`{
+"name": "k8s-pod-network",
+"cniVersion": "0.3.1",
+"plugins": [
+  {
+	"type": "calico",
+	"datastore_type": "kubernetes",
+	"ipam": { "type": "calico-ipam" },
+	"policy": { "type": "k8s" }
+	%s
+  }
+  ]
+}`, settings),
+				}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				cfg, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.Spec.CalicoNetwork.ContainerIPForwarding).ToNot(BeNil())
+				Expect(*cfg.Spec.CalicoNetwork.ContainerIPForwarding).To(Equal(expected))
+			},
+				Entry("unset", "", operatorv1.ContainerIPForwardingDisabled),
+				Entry("enabled", `, "container_settings": { "allow_ip_forwarding": true }`, operatorv1.ContainerIPForwardingEnabled),
+				Entry("disabled", `, "container_settings": { "allow_ip_forwarding": false }`, operatorv1.ContainerIPForwardingDisabled),
+			)
+			DescribeTable("migrate nodename_file_optional", func(settings string, warn bool) {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: fmt.Sprintf(`{
+"name": "k8s-pod-network",
+"cniVersion": "0.3.1",
+"plugins": [
+  {
+	"type": "calico",
+	"datastore_type": "kubernetes",
+	"ipam": { "type": "calico-ipam" },
+	"policy": { "type": "k8s" }
+	%s
+  }
+  ]
+}`, settings),
+				}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, report, err := ConvertWithReport(ctx, c, Options{})
+				Expect(err).ToNot(HaveOccurred())
+				expected := Warning{
+					Component: ComponentCNIConfig,
+					Message: "nodename_file_optional=true will not be carried forward as the operator only sets it for the DockerEnterprise provider, " +
+						"so pod networking will fail until calico-node has written the nodename file",
+				}
+				if warn {
+					Expect(report.Warnings).To(ContainElement(expected))
+				} else {
+					Expect(report.Warnings).ToNot(ContainElement(expected))
+				}
+			},
+				Entry("unset", "", false),
+				Entry("enabled", `, "nodename_file_optional": true`, true),
+				Entry("disabled", `, "nodename_file_optional": false`, false),
+			)
+			DescribeTable("migrate kubernetes.kubeconfig", func(settings string, warn bool) {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: fmt.Sprintf(`{
+"name": "k8s-pod-network",
+"cniVersion": "0.3.1",
+"plugins": [
+  {
+	"type": "calico",
+	"datastore_type": "kubernetes",
+	"ipam": { "type": "calico-ipam" },
+	"policy": { "type": "k8s" }
+	%s
+  }
+  ]
+}`, settings),
+				}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, report, err := ConvertWithReport(ctx, c, Options{})
+				Expect(err).ToNot(HaveOccurred())
+				expected := Warning{
+					Component: ComponentCNIConfig,
+					Message: "kubernetes.kubeconfig=/etc/kubernetes/calico.conf will not be carried forward, " +
+						"the calico plugin will use the kubeconfig at /etc/cni/net.d/calico-kubeconfig",
+				}
+				if warn {
+					Expect(report.Warnings).To(ContainElement(expected))
+				} else {
+					Expect(report.Warnings).ToNot(ContainElement(WithTransform(func(w Warning) string { return w.Message }, ContainSubstring("kubernetes.kubeconfig"))))
+				}
+			},
+				Entry("unset", "", false),
+				Entry("template", `, "kubernetes": { "kubeconfig": "__KUBECONFIG_FILEPATH__" }`, false),
+				Entry("cni net dir", `, "kubernetes": { "kubeconfig": "/etc/cni/net.d/calico-kubeconfig" }`, false),
+				Entry("custom path", `, "kubernetes": { "kubeconfig": "/etc/kubernetes/calico.conf" }`, true),
+			)
+			It("compares the kubeconfig against the provider's CNI network config directory", func() {
+				comps := emptyComponents()
+				comps.provider = operatorv1.ProviderOpenShift
+				comps.node.Spec.Template.Spec.Volumes = nil
+				comps.cni.CalicoConfig = &cni.CalicoConf{Kubernetes: cni.Kubernetes{Kubeconfig: "/var/run/multus/cni/net.d/calico-kubeconfig"}}
+				checkCNIKubeconfig(&comps)
+				Expect(comps.report.Warnings).To(BeEmpty())
+
+				comps.cni.CalicoConfig.Kubernetes.Kubeconfig = "/etc/cni/net.d/calico-kubeconfig"
+				checkCNIKubeconfig(&comps)
+				Expect(comps.report.Warnings).To(ConsistOf(Warning{
+					Component: ComponentCNIConfig,
+					Message: "kubernetes.kubeconfig=/etc/cni/net.d/calico-kubeconfig will not be carried forward, " +
+						"the calico plugin will use the kubeconfig at /var/run/multus/cni/net.d/calico-kubeconfig",
+				}))
+			})
+			DescribeTable("migrate policy.k8s_api_root", func(apiRoot string, warn bool) {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: fmt.Sprintf(`{
+"name": "k8s-pod-network",
+"cniVersion": "0.3.1",
+"plugins": [
+  {
+	"type": "calico",
+	"datastore_type": "kubernetes",
+	"ipam": { "type": "calico-ipam" },
+	"policy": { "type": "k8s", "k8s_api_root": "%s" }
+  }
+  ]
+}`, apiRoot),
+				}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, report, err := ConvertWithReport(ctx, c, Options{})
+				Expect(err).ToNot(HaveOccurred())
+				expected := Warning{
+					Component: ComponentCNIConfig,
+					Message: fmt.Sprintf("policy.k8s_api_root=%s will not be carried forward, "+
+						"the calico plugin will reach the API server using the kubeconfig instead", apiRoot),
+				}
+				if warn {
+					Expect(report.Warnings).To(ContainElement(expected))
+				} else {
+					Expect(report.Warnings).ToNot(ContainElement(expected))
+				}
+			},
+				Entry("unset", "", false),
+				Entry("template", "https://__KUBERNETES_SERVICE_HOST__:__KUBERNETES_SERVICE_PORT__", false),
+				Entry("proxy", "https://apiserver-proxy.example.com:8443", true),
+			)
+			DescribeTable("migrate log rotation", func(rotation string, expected ...string) {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: fmt.Sprintf(`{
+"name": "k8s-pod-network",
+"cniVersion": "0.3.1",
+"plugins": [
+  {
+	"type": "calico",
+	"datastore_type": "kubernetes",
+	"ipam": { "type": "calico-ipam" },
+	"policy": { "type": "k8s" }%s
+  }
+  ]
+}`, rotation),
+				}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, report, err := ConvertWithReport(ctx, c, Options{})
+				Expect(err).ToNot(HaveOccurred())
+				var msgs []string
+				for _, w := range report.Warnings {
+					if w.Component == ComponentCNIConfig && strings.HasPrefix(w.Message, "log_file_") {
+						msgs = append(msgs, w.Message)
+					}
+				}
+				Expect(msgs).To(ConsistOf(expected))
+			},
+				Entry("unset", ""),
+				Entry("defaults", `, "log_file_max_size": 100, "log_file_max_age": 30, "log_file_max_count": 10`),
+				Entry("custom size", `, "log_file_max_size": 50`,
+					"log_file_max_size=50 will not be carried forward, the calico plugin will use the default of 100"),
+				Entry("custom values", `, "log_file_max_size": 200, "log_file_max_age": 7, "log_file_max_count": 3`,
+					"log_file_max_size=200 will not be carried forward, the calico plugin will use the default of 100",
+					"log_file_max_age=7 will not be carried forward, the calico plugin will use the default of 30",
+					"log_file_max_count=3 will not be carried forward, the calico plugin will use the default of 10"),
+			)
+			It("should block on an etcd datastore_type", func() {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: `{
+"name": "k8s-pod-network",
+"cniVersion": "0.3.1",
+"plugins": [
+  {
+	"type": "calico",
+	"datastore_type": "etcdv3",
+	"etcd_endpoints": "https://10.0.0.1:2379",
+	"ipam": { "type": "calico-ipam" },
+	"policy": { "type": "k8s" }
+  }
+  ]
+}`,
+				}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("calico CNI config has datastore_type=etcdv3, only datastore_type=kubernetes is supported"))
+			})
 			DescribeTable("block on IPAM flags", func(ipam string) {
 				ds := emptyNodeSpec()
 				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
@@ -515,7 +1045,7 @@ var _ = Describe("Convert network tests", func() {
 					Value: "bird",
 				}}
 				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
-				_, err := Convert(ctx, c)
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
 				Expect(err).To(HaveOccurred())
 			},
 				Entry("subnet", `"subnet": "usePodCidr"`),
@@ -523,6 +1053,36 @@ var _ = Describe("Convert network tests", func() {
 				Entry("ipv6_pools", `"ipv6_pools": ["2001:db8::1/120"]`),
 				Entry("both pools", `"ipv4_pools": ["10.0.0.0/24"], "ipv6_pools": ["2001:db8::1/120"]`),
 			)
+			DescribeTable("policy type", func(policy string, valid bool) {
+				ds := emptyNodeSpec()
+				ds.Spec.Template.Spec.InitContainers[0].Env = []corev1.EnvVar{{
+					Name: "CNI_NETWORK_CONFIG",
+					Value: fmt.Sprintf(`{
+"name": "k8s-pod-network",
+"cniVersion": "0.3.1",
+"plugins": [
+  {
+	"type": "calico",
+	"datastore_type": "kubernetes",
+	"ipam": { "type": "calico-ipam" }
+	%s
+  }
+  ]
+}`, policy),
+				}}
+				c := fake.NewFakeClientWithScheme(scheme, ds, emptyKubeControllerSpec(), pool, emptyFelixConfig())
+				_, err := Convert(ctx, c, operatorv1.ProviderNone)
+				if valid {
+					Expect(err).ToNot(HaveOccurred())
+				} else {
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("unexpected policy type"))
+				}
+			},
+				Entry("k8s policy", `, "policy": { "type": "k8s" }`, true),
+				Entry("no policy", ``, true),
+				Entry("cilium policy", `, "policy": { "type": "cilium" }`, false),
+			)
 		})
 	})
 
@@ -537,35 +1097,217 @@ var _ = Describe("Convert network tests", func() {
 			i = &operatorv1.Installation{}
 		})
 		It("should not error if ipv6 settings are untouched", func() {
-			Expect(handleIPv6(&c, i)).ToNot(HaveOccurred())
+			Expect(handleIPv6(ctx, &c, i)).ToNot(HaveOccurred())
 		})
 		It("should not error if IP6 is none", func() {
 			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
 				Name:  "IP6",
 				Value: "none",
 			}}
-			Expect(handleIPv6(&c, i)).ToNot(HaveOccurred())
+			Expect(handleIPv6(ctx, &c, i)).ToNot(HaveOccurred())
 		})
 		It("should error if IP6 is not none", func() {
 			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
 				Name:  "IP6",
 				Value: "autodetect",
 			}}
-			Expect(handleIPv6(&c, i)).To(HaveOccurred())
+			Expect(handleIPv6(ctx, &c, i)).To(HaveOccurred())
 		})
 		It("should not error if FELIX_IPV6SUPPORT is false", func() {
 			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
 				Name:  "FELIX_IPV6SUPPORT",
 				Value: "false",
 			}}
-			Expect(handleIPv6(&c, i)).ToNot(HaveOccurred())
+			Expect(handleIPv6(ctx, &c, i)).ToNot(HaveOccurred())
+		})
+		It("should error if FELIX_IPV6SUPPORT is not false on an install with IPv6 addresses", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_IPV6SUPPORT", Value: "true"},
+				{Name: "IP6_AUTODETECTION_METHOD", Value: "first-found"},
+			}
+			Expect(handleIPv6(ctx, &c, i)).To(HaveOccurred())
 		})
-		It("should error if FELIX_IPV6SUPPORT is not false", func() {
+		It("should error if FELIX_IPV6SUPPORT is not false on an install with an IPv6 pool", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_IPV6SUPPORT", Value: "true"},
+				{Name: "CALICO_IPV6POOL_CIDR", Value: "fd00::/48"},
+			}
+			Expect(handleIPv6(ctx, &c, i)).To(HaveOccurred())
+		})
+		DescribeTable("should warn if FELIX_IPV6SUPPORT is true without an IPv6 pool or IPv6 addresses", func(env []v1.EnvVar) {
+			c.node.Spec.Template.Spec.Containers[0].Env = env
+			Expect(handleIPv6(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(c.report.Warnings).To(HaveLen(1))
+			Expect(c.report.Warnings[0].Message).To(ContainSubstring("FELIX_IPV6SUPPORT=true has no effect"))
+			Expect(c.node.uncheckedVars()).ToNot(ContainElement(HavePrefix("calico-node/")))
+		},
+			Entry("IP6 unset", []v1.EnvVar{{Name: "FELIX_IPV6SUPPORT", Value: "true"}}),
+			Entry("IP6 none", []v1.EnvVar{{Name: "FELIX_IPV6SUPPORT", Value: "true"}, {Name: "IP6", Value: "none"}}),
+		)
+		It("should not warn if the install is dual-stack", func() {
+			c.dualStack = true
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "FELIX_IPV6SUPPORT", Value: "true"},
+				{Name: "IP6", Value: "autodetect"},
+			}
+			Expect(handleIPv6(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(c.report.Warnings).To(BeEmpty())
+		})
+	})
+
+	Describe("handle router id", func() {
+		var (
+			c = emptyComponents()
+			i = &operatorv1.Installation{}
+		)
+
+		BeforeEach(func() {
+			c = emptyComponents()
+			i = &operatorv1.Installation{}
+		})
+		It("should not error if CALICO_ROUTER_ID is not set", func() {
+			Expect(handleRouterID(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(c.report.Warnings).To(BeEmpty())
+		})
+		DescribeTable("should error if CALICO_ROUTER_ID is set and BGP is in use", func(routerID string) {
 			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
-				Name:  "FELIX_IPV6SUPPORT",
-				Value: "true",
+				Name:  "CALICO_ROUTER_ID",
+				Value: routerID,
+			}}
+			err := handleRouterID(ctx, &c, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("CALICO_ROUTER_ID=" + routerID + " is not supported"))
+		},
+			Entry("hash", "hash"),
+			Entry("explicit router id", "10.0.0.1"),
+		)
+		It("should not carry forward CALICO_ROUTER_ID when lenient", func() {
+			c.mode = ModeLenient
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "CALICO_ROUTER_ID", Value: "hash"}}
+			Expect(handleRouterID(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(c.report.ManualSteps).To(HaveLen(1))
+			Expect(c.report.ManualSteps[0].Issue).To(ContainSubstring("CALICO_ROUTER_ID=hash is not supported"))
+		})
+		It("should warn if CALICO_ROUTER_ID is set but BGP is not in use", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "CALICO_ROUTER_ID", Value: "hash"},
+				{Name: "CALICO_NETWORKING_BACKEND", Value: "vxlan"},
+			}
+			Expect(handleRouterID(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(c.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message:   "CALICO_ROUTER_ID=hash will not be carried forward, it has no effect since BGP is disabled",
+			}))
+		})
+	})
+
+	Describe("handle autodetection method", func() {
+		var (
+			c = emptyComponents()
+			i = &operatorv1.Installation{}
+		)
+
+		setMethod := func(value string) {
+			c.client = fake.NewFakeClient(&v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "calico-config",
+					Namespace: "kube-system",
+				},
+				Data: map[string]string{"ip_autodetection_method": value},
+			})
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{
+				Name: "IP_AUTODETECTION_METHOD",
+				ValueFrom: &v1.EnvVarSource{
+					ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "calico-config"},
+						Key:                  "ip_autodetection_method",
+					},
+				},
 			}}
-			Expect(handleIPv6(&c, i)).To(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			c = emptyComponents()
+			i = &operatorv1.Installation{Spec: operatorv1.InstallationSpec{CalicoNetwork: &operatorv1.CalicoNetworkSpec{}}}
+		})
+		It("should trim surrounding whitespace", func() {
+			setMethod("  can-reach=8.8.8.8\n")
+			Expect(handleAutoDetectionMethod(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV4).To(Equal(&operatorv1.NodeAddressAutodetection{CanReach: "8.8.8.8"}))
+		})
+		It("should treat whitespace as first-found", func() {
+			setMethod(" \n")
+			Expect(handleAutoDetectionMethod(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV4.FirstFound).ToNot(BeNil())
+		})
+		It("should join a list of interface regexes", func() {
+			setMethod("interface=^eth0$,eth1")
+			Expect(handleAutoDetectionMethod(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV4).To(Equal(&operatorv1.NodeAddressAutodetection{Interface: "^eth0$|eth1"}))
+		})
+		It("should join a list of skip-interface regexes", func() {
+			setMethod("skip-interface=eth0,eth1")
+			Expect(handleAutoDetectionMethod(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV4).To(Equal(&operatorv1.NodeAddressAutodetection{SkipInterface: "eth0|eth1"}))
+		})
+		It("should strip skip-interface regexes which calico-node always excludes", func() {
+			setMethod("skip-interface=^docker.*$,eth1,cali.*")
+			Expect(handleAutoDetectionMethod(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV4).To(Equal(&operatorv1.NodeAddressAutodetection{SkipInterface: "eth1"}))
+			Expect(c.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentCalicoNode,
+				Message:   "IP_AUTODETECTION_METHOD skip-interface patterns ^docker.*$,cali.* are always excluded by calico-node and will not be migrated",
+			}))
+		})
+		It("should not warn about unique skip-interface regexes", func() {
+			setMethod("skip-interface=eth0,docker0")
+			Expect(handleAutoDetectionMethod(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV4).To(Equal(&operatorv1.NodeAddressAutodetection{SkipInterface: "eth0|docker0"}))
+			Expect(c.report.Warnings).To(BeEmpty())
+		})
+		It("should fall back to first-found when only default skip-interface regexes are set", func() {
+			setMethod("skip-interface=lo,veth.*")
+			Expect(handleAutoDetectionMethod(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV4.SkipInterface).To(BeEmpty())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV4.FirstFound).ToNot(BeNil())
+			Expect(*i.Spec.CalicoNetwork.NodeAddressAutodetectionV4.FirstFound).To(BeTrue())
+		})
+		It("should error on an invalid interface regex", func() {
+			setMethod("interface=eth0,eth[")
+			err := handleAutoDetectionMethod(ctx, &c, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid interface regex 'eth['"))
+		})
+		It("should convert IP6_AUTODETECTION_METHOD on a dual-stack install", func() {
+			c.dualStack = true
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "IP_AUTODETECTION_METHOD", Value: "first-found"},
+				{Name: "IP6_AUTODETECTION_METHOD", Value: "can-reach=fd00::1"},
+			}
+			Expect(handleAutoDetectionMethod(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV6).To(Equal(&operatorv1.NodeAddressAutodetection{CanReach: "fd00::1"}))
+		})
+		It("should not convert IP6_AUTODETECTION_METHOD on a single-stack install", func() {
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "IP6_AUTODETECTION_METHOD", Value: "can-reach=fd00::1"},
+			}
+			Expect(handleAutoDetectionMethod(ctx, &c, i)).ToNot(HaveOccurred())
+			Expect(i.Spec.CalicoNetwork.NodeAddressAutodetectionV6).To(BeNil())
+		})
+		It("should name IP6_AUTODETECTION_METHOD in errors", func() {
+			c.dualStack = true
+			c.node.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+				{Name: "IP6_AUTODETECTION_METHOD", Value: "interface=eth["},
+			}
+			err := handleAutoDetectionMethod(ctx, &c, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("IP6_AUTODETECTION_METHOD contains an invalid interface regex 'eth['"))
+		})
+		It("should error on a multi-line value", func() {
+			setMethod("interface=eth0\ncan-reach=8.8.8.8")
+			err := handleAutoDetectionMethod(ctx, &c, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spans multiple lines"))
 		})
 	})
 })
@@ -0,0 +1,134 @@
+package convert
+
+import (
+	"fmt"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+)
+
+// Warning describes a setting in the existing install which did not block the migration,
+// but which the user should be made aware of.
+type Warning struct {
+	// Component identifies which component the setting was found on.
+	Component string `json:"component"`
+	// Message describes the setting and why it is worth attention.
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s on %s", w.Message, w.Component)
+}
+
+// Note describes a finding in the existing install which needs no action, but which gives
+// context about how the cluster was installed.
+type Note struct {
+	// Component identifies which component the finding was made on.
+	Component string `json:"component"`
+	// Message describes the finding.
+	Message string `json:"message"`
+}
+
+func (n Note) String() string {
+	return fmt.Sprintf("%s on %s", n.Message, n.Component)
+}
+
+// ManualStep is an action the user must take after migrating, for a setting which the operator does
+// not support but which did not block a lenient migration.
+type ManualStep struct {
+	// Component identifies which component the setting was found on.
+	Component string `json:"component"`
+	// Issue describes the unsupported setting.
+	Issue string `json:"issue"`
+	// Step is what the user should do about it.
+	Step string `json:"step"`
+}
+
+func (s ManualStep) String() string {
+	return fmt.Sprintf("%s on %s, as %s", s.Step, s.Component, s.Issue)
+}
+
+// Report holds the findings of a migration which are not represented by the
+// resulting Installation resource.
+type Report struct {
+	Warnings []Warning `json:"warnings,omitempty"`
+
+	// Notes are informational findings which do not affect the migration.
+	Notes []Note `json:"notes,omitempty"`
+
+	// ManualSteps are the actions the user must take for the unsupported settings which a lenient
+	// migration carried on past, in the order they were found. Settings which fail even a lenient
+	// migration are not listed, so the list is only complete once the migration succeeds.
+	ManualSteps []ManualStep `json:"manualSteps,omitempty"`
+
+	// APIServer is set if the existing Enterprise install runs the API server, which the operator
+	// manages with an APIServer resource rather than the Installation.
+	APIServer *operatorv1.APIServer `json:"apiServer,omitempty"`
+
+	// NodeSpecHash is a hash of the calico-node pod spec which the migration read, which is the same
+	// for equivalent specs so that reruns against the same manifests can be compared.
+	NodeSpecHash string `json:"nodeSpecHash,omitempty"`
+
+	// CalicoVersion is the version of Calico detected on the cluster, if it could be detected.
+	CalicoVersion string `json:"calicoVersion,omitempty"`
+
+	// Handlers records the outcome of each handler reached by the migration, in the order they ran.
+	Handlers []HandlerRun `json:"handlers,omitempty"`
+}
+
+// HandlerStatus is the outcome of a single migration handler.
+type HandlerStatus string
+
+const (
+	// HandlerRan is recorded for a handler which completed without error.
+	HandlerRan HandlerStatus = "ran"
+	// HandlerSkipped is recorded for a handler whose component is absent from the cluster.
+	HandlerSkipped HandlerStatus = "skipped"
+	// HandlerFailed is recorded for a handler which returned an error. No handler runs after it.
+	HandlerFailed HandlerStatus = "failed"
+)
+
+// HandlerRun records the outcome of a single migration handler.
+type HandlerRun struct {
+	Name   string        `json:"name"`
+	Status HandlerStatus `json:"status"`
+}
+
+// warn records a warning against the given component in the migration report.
+func (c *components) warn(component, format string, a ...interface{}) {
+	c.report.Warnings = append(c.report.Warnings, Warning{
+		Component: component,
+		Message:   fmt.Sprintf(format, a...),
+	})
+}
+
+// note records an informational note against the given component in the migration report.
+func (c *components) note(component, format string, a ...interface{}) {
+	c.report.Notes = append(c.report.Notes, Note{
+		Component: component,
+		Message:   fmt.Sprintf(format, a...),
+	})
+}
+
+// recordHandler records the outcome of the named handler in the migration report.
+func (c *components) recordHandler(name string, status HandlerStatus) {
+	c.report.Handlers = append(c.report.Handlers, HandlerRun{Name: name, Status: status})
+}
+
+// incompatible returns err when the migration is strict. When lenient, err is instead recorded
+// as a warning so that the migration can proceed, and its fix is recorded as a manual step.
+func (c *components) incompatible(err ErrIncompatibleCluster) error {
+	if c.mode != ModeLenient {
+		return err
+	}
+	msg := err.err
+	if err.fix != "" {
+		msg += ". To fix it, " + err.fix
+		c.report.ManualSteps = append(c.report.ManualSteps, ManualStep{
+			Component: err.component,
+			Issue:     err.err,
+			Step:      err.fix,
+		})
+	}
+	c.warn(err.component, "%s", msg)
+	return nil
+}
@@ -0,0 +1,104 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+)
+
+// ResultVersion is the version of the Result schema. It must be bumped whenever a field of
+// Result is removed or changes meaning, so that tooling consuming the JSON can detect it.
+const ResultVersion = "v1"
+
+// Result is the outcome of a migration in a stable shape which can be serialized for
+// consumption by other tools.
+type Result struct {
+	// Version is the schema version of the Result, see ResultVersion.
+	Version string `json:"version"`
+
+	// Installation is the Installation converted from the existing install.
+	// It is omitted if the conversion failed.
+	Installation *operatorv1.Installation `json:"installation,omitempty"`
+
+	// APIServer is the APIServer which should be created alongside the Installation.
+	// It is omitted unless the existing install is Enterprise and runs the API server.
+	APIServer *operatorv1.APIServer `json:"apiServer,omitempty"`
+
+	// Warnings are the findings which did not block the migration.
+	Warnings []Warning `json:"warnings,omitempty"`
+
+	// Notes are the informational findings which do not affect the migration.
+	Notes []Note `json:"notes,omitempty"`
+
+	// ManualSteps are the actions the user must take after migrating. They are not complete if
+	// Error is set.
+	ManualSteps []ManualStep `json:"manualSteps,omitempty"`
+
+	// Error describes why the existing install could not be converted.
+	// It is omitted if the conversion succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// NewResult builds a Result from the values returned by ConvertWithReport.
+func NewResult(install *operatorv1.Installation, report *Report, err error) Result {
+	r := Result{
+		Version:      ResultVersion,
+		Installation: install,
+	}
+	if report != nil {
+		r.Warnings = report.Warnings
+		r.Notes = report.Notes
+		r.ManualSteps = report.ManualSteps
+		r.APIServer = report.APIServer
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// ResultFormat is the format in which a Result is written by WriteResult.
+type ResultFormat string
+
+const (
+	// ResultFormatText writes a human readable summary of the Result. It is the default.
+	ResultFormatText ResultFormat = "text"
+	// ResultFormatJSON writes the Result as JSON so that CI pipelines can assert on it.
+	ResultFormatJSON ResultFormat = "json"
+)
+
+// WriteResult writes the result to w in the given format. An empty format is treated as text.
+func WriteResult(w io.Writer, format ResultFormat, result Result) error {
+	switch format {
+	case ResultFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case ResultFormatText, "":
+		for _, note := range result.Notes {
+			if _, err := fmt.Fprintf(w, "note: %s\n", note); err != nil {
+				return err
+			}
+		}
+		for _, warning := range result.Warnings {
+			if _, err := fmt.Fprintf(w, "warning: %s\n", warning); err != nil {
+				return err
+			}
+		}
+		for _, step := range result.ManualSteps {
+			if _, err := fmt.Fprintf(w, "manual step: %s\n", step); err != nil {
+				return err
+			}
+		}
+		if result.Error != "" {
+			_, err := fmt.Fprintf(w, "error: %s\n", result.Error)
+			return err
+		}
+		_, err := fmt.Fprintf(w, "migration succeeded with %d warning(s)\n", len(result.Warnings))
+		return err
+	default:
+		return fmt.Errorf("unsupported result format '%s', should be one of %s,%s", format, ResultFormatText, ResultFormatJSON)
+	}
+}
@@ -0,0 +1,127 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+)
+
+var _ = Describe("Result", func() {
+	It("should serialize a successful migration", func() {
+		install := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{Variant: operatorv1.Calico}}
+		report := &Report{Warnings: []Warning{{Component: ComponentCalicoNode, Message: "foo"}}}
+
+		b, err := json.Marshal(NewResult(install, report, nil))
+		Expect(err).ToNot(HaveOccurred())
+
+		out := map[string]interface{}{}
+		Expect(json.Unmarshal(b, &out)).To(Succeed())
+		Expect(out).To(HaveKeyWithValue("version", ResultVersion))
+		Expect(out).To(HaveKey("installation"))
+		Expect(out).To(HaveKeyWithValue("warnings", ConsistOf(map[string]interface{}{
+			"component": ComponentCalicoNode,
+			"message":   "foo",
+		})))
+		Expect(out).ToNot(HaveKey("error"))
+		Expect(out).ToNot(HaveKey("apiServer"))
+	})
+
+	It("should serialize the APIServer of a migration", func() {
+		report := &Report{APIServer: &operatorv1.APIServer{}}
+		report.APIServer.Name = "tigera-secure"
+
+		b, err := json.Marshal(NewResult(&operatorv1.Installation{}, report, nil))
+		Expect(err).ToNot(HaveOccurred())
+
+		out := map[string]interface{}{}
+		Expect(json.Unmarshal(b, &out)).To(Succeed())
+		Expect(out).To(HaveKeyWithValue("apiServer", HaveKeyWithValue("metadata", HaveKeyWithValue("name", "tigera-secure"))))
+	})
+
+	It("should serialize the notes of a migration", func() {
+		report := &Report{Notes: []Note{{Component: ComponentCalicoNode, Message: "foo"}}}
+
+		b, err := json.Marshal(NewResult(&operatorv1.Installation{}, report, nil))
+		Expect(err).ToNot(HaveOccurred())
+
+		out := map[string]interface{}{}
+		Expect(json.Unmarshal(b, &out)).To(Succeed())
+		Expect(out).To(HaveKeyWithValue("notes", ConsistOf(map[string]interface{}{
+			"component": ComponentCalicoNode,
+			"message":   "foo",
+		})))
+	})
+
+	It("should serialize a failed migration", func() {
+		b, err := json.Marshal(NewResult(nil, &Report{}, fmt.Errorf("bad config")))
+		Expect(err).ToNot(HaveOccurred())
+
+		out := map[string]interface{}{}
+		Expect(json.Unmarshal(b, &out)).To(Succeed())
+		Expect(out).To(HaveKeyWithValue("version", ResultVersion))
+		Expect(out).To(HaveKeyWithValue("error", "bad config"))
+		Expect(out).ToNot(HaveKey("installation"))
+		Expect(out).ToNot(HaveKey("warnings"))
+	})
+
+	Context("WriteResult", func() {
+		var result Result
+
+		BeforeEach(func() {
+			install := &operatorv1.Installation{Spec: operatorv1.InstallationSpec{Variant: operatorv1.Calico}}
+			report := &Report{Warnings: []Warning{{Component: ComponentTypha, Message: "foo"}}}
+			result = NewResult(install, report, nil)
+		})
+
+		It("should write json which decodes back into a Result", func() {
+			var buf bytes.Buffer
+			Expect(WriteResult(&buf, ResultFormatJSON, result)).To(Succeed())
+
+			decoded := Result{}
+			Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(Succeed())
+			Expect(decoded.Version).To(Equal(ResultVersion))
+			Expect(decoded.Installation).ToNot(BeNil())
+			Expect(decoded.Installation.Spec.Variant).To(Equal(operatorv1.Calico))
+			Expect(decoded.Warnings).To(Equal([]Warning{{Component: ComponentTypha, Message: "foo"}}))
+			Expect(decoded.Error).To(BeEmpty())
+		})
+
+		It("should write text by default", func() {
+			var buf bytes.Buffer
+			Expect(WriteResult(&buf, "", result)).To(Succeed())
+			Expect(buf.String()).To(Equal("warning: foo on deployment/calico-typha\nmigration succeeded with 1 warning(s)\n"))
+		})
+
+		It("should write notes as text", func() {
+			var buf bytes.Buffer
+			result.Notes = []Note{{Component: ComponentCalicoNode, Message: "bar"}}
+			Expect(WriteResult(&buf, ResultFormatText, result)).To(Succeed())
+			Expect(buf.String()).To(Equal("note: bar on daemonset/calico-node\n" +
+				"warning: foo on deployment/calico-typha\nmigration succeeded with 1 warning(s)\n"))
+		})
+
+		It("should write manual steps as text", func() {
+			var buf bytes.Buffer
+			result.ManualSteps = []ManualStep{{Component: ComponentCalicoNode, Issue: "FOO is set", Step: "remove FOO"}}
+			Expect(WriteResult(&buf, ResultFormatText, result)).To(Succeed())
+			Expect(buf.String()).To(Equal("warning: foo on deployment/calico-typha\n" +
+				"manual step: remove FOO on daemonset/calico-node, as FOO is set\n" +
+				"migration succeeded with 1 warning(s)\n"))
+		})
+
+		It("should write the error as text", func() {
+			var buf bytes.Buffer
+			Expect(WriteResult(&buf, ResultFormatText, NewResult(nil, nil, fmt.Errorf("bad config")))).To(Succeed())
+			Expect(buf.String()).To(Equal("error: bad config\n"))
+		})
+
+		It("should reject an unknown format", func() {
+			Expect(WriteResult(&bytes.Buffer{}, "yaml", result)).ToNot(Succeed())
+		})
+	})
+})
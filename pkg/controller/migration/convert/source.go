@@ -0,0 +1,28 @@
+package convert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// hashPodSpec returns a hash of a pod spec which is stable across equivalent specs, so that reruns
+// of a migration against the same manifests can be correlated. Containers and volumes are sorted by
+// name first, as their order does not change the pods which are run. The order of init containers and
+// env vars is kept, since init containers run in order and env vars can only reference earlier ones.
+func hashPodSpec(spec corev1.PodSpec) (string, error) {
+	spec = *spec.DeepCopy()
+	sort.Slice(spec.Containers, func(i, j int) bool { return spec.Containers[i].Name < spec.Containers[j].Name })
+	sort.Slice(spec.Volumes, func(i, j int) bool { return spec.Volumes[i].Name < spec.Volumes[j].Name })
+
+	// json marshals map keys in sorted order, so the serialization is stable.
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
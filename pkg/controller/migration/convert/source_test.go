@@ -0,0 +1,84 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("node spec hash", func() {
+	var spec corev1.PodSpec
+
+	BeforeEach(func() {
+		spec = emptyNodeSpec().Spec.Template.Spec
+		spec.Containers[0].Image = "calico/node:v3.16.0"
+		spec.Containers[0].Env = []corev1.EnvVar{
+			{Name: "CALICO_NETWORKING_BACKEND", Value: "bird"},
+			{Name: "FELIX_HEALTHENABLED", Value: "true"},
+		}
+	})
+
+	It("should be stable across equivalent specs", func() {
+		h, err := hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(h).ToNot(BeEmpty())
+
+		again, err := hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(again).To(Equal(h))
+
+		spec.Containers = append(spec.Containers, corev1.Container{Name: "sidecar"})
+		h, err = hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+
+		reordered := *spec.DeepCopy()
+		reordered.Containers[0], reordered.Containers[1] = reordered.Containers[1], reordered.Containers[0]
+		reordered.Volumes = append([]corev1.Volume{}, spec.Volumes...)
+		for i, j := 0, len(reordered.Volumes)-1; i < j; i, j = i+1, j-1 {
+			reordered.Volumes[i], reordered.Volumes[j] = reordered.Volumes[j], reordered.Volumes[i]
+		}
+		reorderedHash, err := hashPodSpec(reordered)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reorderedHash).To(Equal(h))
+	})
+
+	It("should not modify the spec", func() {
+		spec.Containers = append(spec.Containers, corev1.Container{Name: "a-sidecar"})
+		_, err := hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spec.Containers[1].Name).To(Equal("a-sidecar"))
+	})
+
+	It("should change when the order of the init containers changes", func() {
+		spec.InitContainers = append(spec.InitContainers, corev1.Container{Name: "flexvol-driver"})
+		h, err := hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+
+		spec.InitContainers[0], spec.InitContainers[1] = spec.InitContainers[1], spec.InitContainers[0]
+		reordered, err := hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reordered).ToNot(Equal(h))
+	})
+
+	It("should change when the order of the env vars changes", func() {
+		h, err := hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+
+		env := spec.Containers[0].Env
+		env[0], env[1] = env[1], env[0]
+		reordered, err := hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reordered).ToNot(Equal(h))
+	})
+
+	It("should change when the spec changes", func() {
+		h, err := hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+
+		spec.Containers[0].Image = "calico/node:v3.17.0"
+		changed, err := hashPodSpec(spec)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).ToNot(Equal(h))
+	})
+})
@@ -1,6 +1,8 @@
 package convert
 
 import (
+	"context"
+
 	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -8,7 +10,11 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ctx is the context passed to handlers under test.
+var ctx = context.Background()
+
 func emptyNodeSpec() *appsv1.DaemonSet {
+	isPrivileged := true
 	return &appsv1.DaemonSet{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      "calico-node",
@@ -39,7 +45,8 @@ func emptyNodeSpec() *appsv1.DaemonSet {
 						}},
 					}},
 					Containers: []corev1.Container{{
-						Name: "calico-node",
+						Name:            "calico-node",
+						SecurityContext: &corev1.SecurityContext{Privileged: &isPrivileged},
 					}},
 					Volumes: []corev1.Volume{
 						{
@@ -97,6 +104,17 @@ func emptyNodeSpec() *appsv1.DaemonSet {
 	}
 }
 
+// nodeSpecWithCNISidecar returns a calico-node daemonset which runs install-cni as a
+// sidecar container rather than as an init container.
+func nodeSpecWithCNISidecar() *appsv1.DaemonSet {
+	ds := emptyNodeSpec()
+	cni := ds.Spec.Template.Spec.InitContainers[0]
+	cni.Env = append(cni.Env, corev1.EnvVar{Name: "SLEEP", Value: "true"})
+	ds.Spec.Template.Spec.InitContainers = nil
+	ds.Spec.Template.Spec.Containers = append(ds.Spec.Template.Spec.Containers, cni)
+	return ds
+}
+
 func emptyKubeControllerSpec() *appsv1.Deployment {
 	return &appsv1.Deployment{
 		ObjectMeta: v1.ObjectMeta{
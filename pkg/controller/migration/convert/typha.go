@@ -1,25 +1,115 @@
 package convert
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 
 	operatorv1 "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/common"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 const (
 	containerTypha = "calico-typha"
+
+	typhaPDBName        = "calico-typha"
+	typhaAutoscalerName = "calico-typha-horizontal-autoscaler"
 )
 
-func checkTypha(c *components, _ *operatorv1.Installation) error {
+func checkTypha(_ context.Context, c *components, _ *operatorv1.Installation) error {
 	// No validation required.
 	return nil
 }
 
+// handleTyphaTLS is a migration handler which detects TLS between felix and typha. The operator generates
+// and manages its own certificates for this connection, so any custom TLS material is replaced. The secret
+// volumes which hold it on calico-node are dropped rather than reported as unexpected volumes.
+func handleTyphaTLS(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	var vars, secrets []string
+
+	nodeSpec := c.node.Spec.Template.Spec
+	for _, key := range []string{"FELIX_TYPHACAFILE", "FELIX_TYPHACERTFILE", "FELIX_TYPHAKEYFILE", "FELIX_TYPHACN", "FELIX_TYPHAURISAN"} {
+		val, err := c.node.getEnv(ctx, c.client, containerCalicoNode, key)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			continue
+		}
+		vars = append(vars, containerCalicoNode+"/"+key)
+
+		if vol := getSecretVolumeForPath(nodeSpec, containerCalicoNode, *val); vol != nil {
+			secrets = appendIfMissing(secrets, vol.Secret.SecretName)
+			c.dropVolume(vol.Name)
+		}
+	}
+
+	if c.typha != nil {
+		typhaSpec := c.typha.Spec.Template.Spec
+		for _, key := range []string{"TYPHA_CAFILE", "TYPHA_SERVERCERTFILE", "TYPHA_SERVERKEYFILE", "TYPHA_CLIENTCN", "TYPHA_CLIENTURISAN"} {
+			val, err := getEnv(ctx, c.client, typhaSpec, ComponentTypha, containerTypha, key)
+			if err != nil {
+				return err
+			}
+			if val == nil {
+				continue
+			}
+			vars = append(vars, containerTypha+"/"+key)
+
+			if vol := getSecretVolumeForPath(typhaSpec, containerTypha, *val); vol != nil {
+				secrets = appendIfMissing(secrets, vol.Secret.SecretName)
+			}
+		}
+	}
+
+	if len(vars) == 0 {
+		return nil
+	}
+	if len(secrets) == 0 {
+		c.warn(ComponentTypha, "felix to typha TLS is configured by %s, the operator will replace it with certificates it manages", strings.Join(vars, ", "))
+	} else {
+		c.warn(ComponentTypha, "felix to typha TLS is configured by %s using secrets %s, the operator will replace it with certificates it manages",
+			strings.Join(vars, ", "), strings.Join(secrets, ", "))
+	}
+	return nil
+}
+
+// getSecretVolumeForPath returns the secret volume which holds the given path in the named container,
+// or nil if the path is not on a secret volume.
+func getSecretVolumeForPath(spec corev1.PodSpec, container, path string) *corev1.Volume {
+	ctr := getContainer(spec, container)
+	if ctr == nil {
+		return nil
+	}
+	mount := getVolumeMountForPath(*ctr, path)
+	if mount == nil {
+		return nil
+	}
+	if vol := getVolume(spec, mount.Name); vol != nil && vol.Secret != nil {
+		return vol
+	}
+	return nil
+}
+
+func appendIfMissing(list []string, s string) []string {
+	for _, l := range list {
+		if l == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
 // handleTyphaMetrics is a migration handler which detects custom prometheus settings for typha and
 // carries those options forward via the TyphaMetricsPort field.
-func handleTyphaMetrics(c *components, install *operatorv1.Installation) error {
+func handleTyphaMetrics(ctx context.Context, c *components, install *operatorv1.Installation) error {
 	if c.typha == nil {
 		return nil
 	}
@@ -35,18 +125,91 @@ func handleTyphaMetrics(c *components, install *operatorv1.Installation) error {
 			return err
 		}
 		if port != nil {
-			p, err := strconv.ParseInt(*port, 10, 32)
-			if err != nil || p <= 0 || p > 65535 {
-				return ErrIncompatibleCluster{
+			// an invalid port is left at the default when the migration is lenient.
+			if p, err := strconv.ParseInt(*port, 10, 32); err != nil || p <= 0 || p > 65535 {
+				if err := c.incompatible(ErrIncompatibleCluster{
 					err:       fmt.Sprintf("invalid port defined in TYPHA_PROMETHEUSMETRICSPORT=%s", *port),
 					component: ComponentTypha,
 					fix:       "adjust it to be within the range of 1-65535 or remove the env var",
+				}); err != nil {
+					return err
 				}
+			} else {
+				i := int32(p)
+				install.Spec.TyphaMetricsPort = &i
 			}
-			i := int32(p)
-			install.Spec.TyphaMetricsPort = &i
 		}
 	}
 
 	return nil
 }
+
+// handleTyphaScaling checks for a Typha PodDisruptionBudget and cluster-proportional-autoscaler in
+// kube-system. The operator renders its own PodDisruptionBudget with maxUnavailable=1 and scales
+// Typha based on the number of nodes, so any customizations to either are reported as they will
+// not be carried forward.
+func handleTyphaScaling(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	if c.typha == nil {
+		return nil
+	}
+
+	pdb := policyv1beta1.PodDisruptionBudget{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: typhaPDBName, Namespace: metav1.NamespaceSystem}, &pdb); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get typha PodDisruptionBudget: %v", err)
+		}
+	} else {
+		if pdb.Spec.MinAvailable != nil {
+			c.warn(ComponentTypha, "PodDisruptionBudget %s/%s sets minAvailable=%s, it will be replaced by the operator's PodDisruptionBudget with maxUnavailable=1",
+				pdb.Namespace, pdb.Name, pdb.Spec.MinAvailable.String())
+		} else if pdb.Spec.MaxUnavailable != nil && pdb.Spec.MaxUnavailable.String() != "1" {
+			c.warn(ComponentTypha, "PodDisruptionBudget %s/%s sets maxUnavailable=%s, it will be replaced by the operator's PodDisruptionBudget with maxUnavailable=1",
+				pdb.Namespace, pdb.Name, pdb.Spec.MaxUnavailable.String())
+		}
+	}
+
+	cm := corev1.ConfigMap{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: typhaAutoscalerName, Namespace: metav1.NamespaceSystem}, &cm); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get typha autoscaler ConfigMap: %v", err)
+		}
+	} else {
+		c.warn(ComponentTypha, "typha autoscaler settings in ConfigMap %s/%s will not be carried forward, the operator scales typha based on the number of nodes",
+			cm.Namespace, cm.Name)
+	}
+
+	autoscaler := appsv1.Deployment{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: typhaAutoscalerName, Namespace: metav1.NamespaceSystem}, &autoscaler); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get typha autoscaler Deployment: %v", err)
+		}
+	} else {
+		c.warn(ComponentTypha, "typha autoscaler Deployment %s/%s will not be managed by the operator, it should be removed after migration",
+			autoscaler.Namespace, autoscaler.Name)
+		// the replicas are set by the autoscaler, so they don't reflect any tuning.
+		return nil
+	}
+
+	return handleTyphaReplicas(ctx, c)
+}
+
+// handleTyphaReplicas warns if the Typha replica count was pinned to a value which differs from
+// the number of replicas the operator will scale Typha to for the current number of nodes.
+func handleTyphaReplicas(ctx context.Context, c *components) error {
+	if c.typha.Spec.Replicas == nil {
+		return nil
+	}
+	replicas := int(*c.typha.Spec.Replicas)
+
+	nodes := corev1.NodeList{}
+	if err := c.client.List(ctx, &nodes); err != nil {
+		return fmt.Errorf("failed to list nodes: %v", err)
+	}
+	nodeCount := len(nodes.Items)
+
+	if expected := common.GetExpectedTyphaScale(nodeCount); replicas != expected {
+		c.warn(ComponentTypha, "typha is pinned to %d replicas, but the operator will scale it to %d replicas for the %d nodes in the cluster",
+			replicas, expected, nodeCount)
+	}
+	return nil
+}
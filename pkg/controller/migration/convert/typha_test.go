@@ -7,9 +7,12 @@ import (
 	. "github.com/onsi/ginkgo"
 	//. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	kscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
@@ -54,7 +57,7 @@ var _ = Describe("Convert typha check tests", func() {
 			td.Spec.Replicas = int32Ptr(1)
 
 			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig(), td, getK8sNodes(2))
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 		})
 		It("should not return an error with 3 nodes and 1 typha", func() {
@@ -62,7 +65,7 @@ var _ = Describe("Convert typha check tests", func() {
 			td.Spec.Replicas = int32Ptr(1)
 
 			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig(), td, getK8sNodes(3))
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).NotTo(HaveOccurred())
 		})
 	})
@@ -72,7 +75,7 @@ var _ = Describe("Convert typha check tests", func() {
 			td.Spec.Replicas = int32Ptr(1)
 
 			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig(), td, getK8sNodes(5))
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).ToNot(HaveOccurred())
 		})
 		It("should succeed with 8 nodes and 4 typha ", func() {
@@ -80,7 +83,7 @@ var _ = Describe("Convert typha check tests", func() {
 			td.Spec.Replicas = int32Ptr(4)
 
 			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig(), td, getK8sNodes(8))
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
@@ -90,19 +93,19 @@ var _ = Describe("Convert typha check tests", func() {
 			td.Spec.Replicas = int32Ptr(0)
 
 			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig(), td, getK8sNodes(2))
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).ToNot(HaveOccurred())
 		})
 		It("should not error with no replicas", func() {
 			td := emptyTyphaDeployment()
 
 			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig(), td, getK8sNodes(2))
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).ToNot(HaveOccurred())
 		})
 		It("should not error with no typha deployment", func() {
 			c := fake.NewFakeClientWithScheme(scheme, emptyNodeSpec(), emptyKubeControllerSpec(), pool, emptyFelixConfig(), getK8sNodes(2))
-			_, err := Convert(ctx, c)
+			_, err := Convert(ctx, c, operatorv1.ProviderNone)
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
@@ -121,12 +124,12 @@ var _ = Describe("Convert typha check tests", func() {
 				Name:  "TYPHA_PROMETHEUSMETRICSENABLED",
 				Value: "true",
 			}}
-			Expect(handleTyphaMetrics(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleTyphaMetrics(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(*i.Spec.TyphaMetricsPort).To(Equal(int32(9091)))
 		})
 		It("defaults prometheus off when no prometheus environment variables set", func() {
 
-			Expect(handleFelixNodeMetrics(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleFelixNodeMetrics(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.TyphaMetricsPort).To(BeNil())
 		})
 		It("with metrics port env var only, metrics are still disabled", func() {
@@ -135,7 +138,7 @@ var _ = Describe("Convert typha check tests", func() {
 				Value: "5555",
 			}}
 
-			Expect(handleTyphaMetrics(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleTyphaMetrics(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(i.Spec.TyphaMetricsPort).To(BeNil())
 		})
 		It("with metrics port and enabled is reflected in installation", func() {
@@ -147,8 +150,188 @@ var _ = Describe("Convert typha check tests", func() {
 				Value: "7777",
 			}}
 
-			Expect(handleTyphaMetrics(&comps, i)).ToNot(HaveOccurred())
+			Expect(handleTyphaMetrics(ctx, &comps, i)).ToNot(HaveOccurred())
 			Expect(*i.Spec.TyphaMetricsPort).To(Equal(int32(7777)))
 		})
 	})
+
+	Context("typha scaling", func() {
+		var (
+			comps = emptyComponents()
+			i     = &operatorv1.Installation{}
+		)
+
+		typhaPDB := func(spec policyv1beta1.PodDisruptionBudgetSpec) *policyv1beta1.PodDisruptionBudget {
+			return &policyv1beta1.PodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "calico-typha",
+					Namespace: "kube-system",
+				},
+				Spec: spec,
+			}
+		}
+
+		BeforeEach(func() {
+			comps = emptyComponents()
+			i = &operatorv1.Installation{}
+		})
+
+		It("should not warn about the default PodDisruptionBudget", func() {
+			maxUnavailable := intstr.FromInt(1)
+			comps.client = fake.NewFakeClientWithScheme(scheme, typhaPDB(policyv1beta1.PodDisruptionBudgetSpec{
+				MaxUnavailable: &maxUnavailable,
+			}))
+			Expect(handleTyphaScaling(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+
+		It("should warn about a custom minAvailable", func() {
+			minAvailable := intstr.FromInt(2)
+			comps.client = fake.NewFakeClientWithScheme(scheme, typhaPDB(policyv1beta1.PodDisruptionBudgetSpec{
+				MinAvailable: &minAvailable,
+			}))
+			Expect(handleTyphaScaling(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Component).To(Equal(ComponentTypha))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("minAvailable=2"))
+		})
+
+		It("should warn about a custom maxUnavailable", func() {
+			maxUnavailable := intstr.FromString("50%")
+			comps.client = fake.NewFakeClientWithScheme(scheme, typhaPDB(policyv1beta1.PodDisruptionBudgetSpec{
+				MaxUnavailable: &maxUnavailable,
+			}))
+			Expect(handleTyphaScaling(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("maxUnavailable=50%"))
+		})
+
+		It("should warn about the typha autoscaler", func() {
+			comps.client = fake.NewFakeClientWithScheme(scheme,
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "calico-typha-horizontal-autoscaler",
+						Namespace: "kube-system",
+					},
+					Data: map[string]string{
+						"ladder": `{"coresToReplicas": [], "nodesToReplicas": [[1, 1], [10, 2]]}`,
+					},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "calico-typha-horizontal-autoscaler",
+						Namespace: "kube-system",
+					},
+				})
+			Expect(handleTyphaScaling(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(HaveLen(2))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("ConfigMap kube-system/calico-typha-horizontal-autoscaler"))
+			Expect(comps.report.Warnings[1].Message).To(ContainSubstring("Deployment kube-system/calico-typha-horizontal-autoscaler"))
+		})
+	})
+
+	Context("typha replicas", func() {
+		var (
+			comps = emptyComponents()
+			i     = &operatorv1.Installation{}
+		)
+
+		BeforeEach(func() {
+			comps = emptyComponents()
+			i = &operatorv1.Installation{}
+		})
+
+		It("should warn if the pinned replicas differ from the operator's scale", func() {
+			var replicas int32 = 5
+			comps.typha.Spec.Replicas = &replicas
+			comps.client = fake.NewFakeClientWithScheme(scheme, getK8sNodes(10))
+			Expect(handleTyphaScaling(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(ConsistOf(Warning{
+				Component: ComponentTypha,
+				Message:   "typha is pinned to 5 replicas, but the operator will scale it to 3 replicas for the 10 nodes in the cluster",
+			}))
+		})
+
+		It("should not warn if the pinned replicas match the operator's scale", func() {
+			var replicas int32 = 3
+			comps.typha.Spec.Replicas = &replicas
+			comps.client = fake.NewFakeClientWithScheme(scheme, getK8sNodes(10))
+			Expect(handleTyphaScaling(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+
+		It("should not warn about the replicas if typha is autoscaled", func() {
+			var replicas int32 = 5
+			comps.typha.Spec.Replicas = &replicas
+			comps.client = fake.NewFakeClientWithScheme(scheme, getK8sNodes(10), &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "calico-typha-horizontal-autoscaler",
+					Namespace: "kube-system",
+				},
+			})
+			Expect(handleTyphaScaling(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("Deployment kube-system/calico-typha-horizontal-autoscaler"))
+		})
+	})
+
+	Context("typha tls", func() {
+		var (
+			comps = emptyComponents()
+			i     = &operatorv1.Installation{}
+		)
+
+		secretVolume := func(name, secret string) corev1.Volume {
+			return corev1.Volume{
+				Name:         name,
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secret}},
+			}
+		}
+
+		BeforeEach(func() {
+			comps = emptyComponents()
+			i = &operatorv1.Installation{}
+
+			node := &comps.node.Spec.Template.Spec
+			node.Volumes = append(node.Volumes, secretVolume("felix-certs", "calico-felix-certs"))
+			node.Containers[0].VolumeMounts = append(node.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "felix-certs", MountPath: "/felix-certs"})
+			node.Containers[0].Env = []corev1.EnvVar{
+				{Name: "FELIX_TYPHACAFILE", Value: "/felix-certs/ca.crt"},
+				{Name: "FELIX_TYPHACERTFILE", Value: "/felix-certs/tls.crt"},
+				{Name: "FELIX_TYPHAKEYFILE", Value: "/felix-certs/tls.key"},
+				{Name: "FELIX_TYPHACN", Value: "calico-typha"},
+			}
+
+			typha := &comps.typha.Spec.Template.Spec
+			typha.Volumes = append(typha.Volumes, secretVolume("typha-certs", "calico-typha-certs"))
+			typha.Containers[0].VolumeMounts = append(typha.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "typha-certs", MountPath: "/typha-certs"})
+			typha.Containers[0].Env = []corev1.EnvVar{
+				{Name: "TYPHA_CAFILE", Value: "/typha-certs/ca.crt"},
+				{Name: "TYPHA_SERVERCERTFILE", Value: "/typha-certs/tls.crt"},
+				{Name: "TYPHA_SERVERKEYFILE", Value: "/typha-certs/tls.key"},
+				{Name: "TYPHA_CLIENTCN", Value: "calico-felix"},
+			}
+		})
+
+		It("should not warn if tls is not configured", func() {
+			comps = emptyComponents()
+			Expect(handleTyphaTLS(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+
+		It("should warn about custom tls and the secrets it uses", func() {
+			Expect(handleTyphaTLS(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Component).To(Equal(ComponentTypha))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("calico-node/FELIX_TYPHACAFILE"))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("calico-typha/TYPHA_CLIENTCN"))
+			Expect(comps.report.Warnings[0].Message).To(ContainSubstring("using secrets calico-felix-certs, calico-typha-certs"))
+			Expect(comps.node.uncheckedVars()).To(ConsistOf("install-cni/CNI_NETWORK_CONFIG"))
+		})
+
+		It("should not report the calico-node secret volume as unexpected", func() {
+			Expect(handleTyphaTLS(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(handleNodeVolumes(ctx, &comps, i)).ToNot(HaveOccurred())
+		})
+	})
 })
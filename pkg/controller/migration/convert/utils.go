@@ -1,6 +1,8 @@
 package convert
 
 import (
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -18,6 +20,16 @@ func getContainer(spec corev1.PodSpec, name string) *corev1.Container {
 	return nil
 }
 
+// isInitContainer returns true if the named container is one of the spec's init containers.
+func isInitContainer(spec corev1.PodSpec, name string) bool {
+	for _, container := range spec.InitContainers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func getVolume(spec corev1.PodSpec, name string) *corev1.Volume {
 	for _, volume := range spec.Volumes {
 		if volume.Name == name {
@@ -26,3 +38,76 @@ func getVolume(spec corev1.PodSpec, name string) *corev1.Volume {
 	}
 	return nil
 }
+
+func getVolumeMount(container corev1.Container, name string) *corev1.VolumeMount {
+	for _, mount := range container.VolumeMounts {
+		if mount.Name == name {
+			return &mount
+		}
+	}
+	return nil
+}
+
+// getVolumeMountForPath returns the volume mount of the container which contains the given path,
+// or nil if the path is not on a volume.
+func getVolumeMountForPath(container corev1.Container, path string) *corev1.VolumeMount {
+	var found *corev1.VolumeMount
+	for i, mount := range container.VolumeMounts {
+		mountPath := strings.TrimSuffix(mount.MountPath, "/")
+		if path != mountPath && !strings.HasPrefix(path, mountPath+"/") {
+			continue
+		}
+		// prefer the most specific mount if they are nested
+		if found == nil || len(mountPath) > len(strings.TrimSuffix(found.MountPath, "/")) {
+			found = &container.VolumeMounts[i]
+		}
+	}
+	return found
+}
+
+// containerImages maps the expected name of each calico-node container to the image it runs. It is used
+// to find the containers of manifests which have renamed them.
+var containerImages = map[string]string{
+	containerCalicoNode: "calico/node",
+	containerInstallCNI: "calico/cni",
+}
+
+// resolveContainerNames renames the calico-node and install-cni containers of spec to their expected
+// names if no container has the expected name, but exactly one container runs the expected image,
+// so that handlers can find them. It returns a map of each expected name to the name that was found.
+// The upgrade-ipam init container also runs the calico/cni image, so it is never treated as install-cni.
+func resolveContainerNames(spec *corev1.PodSpec) map[string]string {
+	names := map[string]string{}
+	for name, image := range containerImages {
+		if getContainer(*spec, name) != nil {
+			continue
+		}
+
+		var found []*corev1.Container
+		for _, containers := range [][]corev1.Container{spec.Containers, spec.InitContainers} {
+			for i := range containers {
+				if containers[i].Name != "upgrade-ipam" && isImage(containers[i].Image, image) {
+					found = append(found, &containers[i])
+				}
+			}
+		}
+		if len(found) != 1 {
+			continue
+		}
+
+		names[name] = found[0].Name
+		found[0].Name = name
+	}
+	return names
+}
+
+// isImage returns true if image is the given repository, e.g. calico/node, in any registry and with any tag or digest.
+func isImage(image, repository string) bool {
+	if i := strings.Index(image, "@"); i != -1 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image == repository || strings.HasSuffix(image, "/"+repository)
+}
@@ -0,0 +1,190 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gv "github.com/hashicorp/go-version"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+)
+
+var imageVersionRegexp = regexp.MustCompile(`^v?(\d+\.\d+(\.\d+)?)`)
+
+// featureMinVersions lists features which may be enabled on calico-node by an env var, along
+// with the minimum Calico version which supports them.
+var featureMinVersions = []struct {
+	feature string
+	envVar  string
+	version string
+}{
+	{"BPF", "FELIX_BPFENABLED", "v3.13.0"},
+	{"WireGuard", "FELIX_WIREGUARDENABLED", "v3.14.0"},
+}
+
+// detectCalicoVersion extracts the Calico version from the tag of an image, e.g. calico/node:v3.16.0.
+// The patch version and any pre-release suffix are optional. It returns nil if the image has no tag
+// (e.g. it is only referenced by digest) or the tag is not a version (e.g. master).
+func detectCalicoVersion(image string) *gv.Version {
+	// drop any digest
+	if i := strings.Index(image, "@"); i != -1 {
+		image = image[:i]
+	}
+	// the tag follows the last ':' of the final path segment, as the registry may include a port.
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	if i == -1 {
+		return nil
+	}
+
+	m := imageVersionRegexp.FindStringSubmatch(name[i+1:])
+	if m == nil {
+		return nil
+	}
+	v, err := gv.NewVersion(m[1])
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// handleCalicoVersion is a migration handler which detects the Calico version and verifies that
+// features enabled on calico-node are supported by that version, since they could not have taken
+// effect otherwise. The version recorded in the default ClusterInformation is preferred, falling back
+// to the tag of the calico-node image. If the version can't be detected, the checks are skipped.
+func handleCalicoVersion(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	node := getContainer(c.node.Spec.Template.Spec, containerCalicoNode)
+	var imageVersion *gv.Version
+	if node != nil && node.Image != "" {
+		imageVersion = detectCalicoVersion(node.Image)
+	}
+
+	clusterVersion, err := clusterInformationVersion(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case clusterVersion != nil:
+		c.calicoVersion = clusterVersion
+		if imageVersion != nil && !imageVersion.Equal(clusterVersion) {
+			c.warn(ComponentClusterInfo, "ClusterInformation reports Calico v%s but calico-node is running v%s, using v%s for feature version checks",
+				clusterVersion, imageVersion, clusterVersion)
+		}
+	case imageVersion != nil:
+		c.calicoVersion = imageVersion
+	case node == nil || node.Image == "":
+		return nil
+	default:
+		c.warn(ComponentCalicoNode, "could not detect the Calico version from image %s, feature version checks are skipped", node.Image)
+		return nil
+	}
+	c.report.CalicoVersion = "v" + c.calicoVersion.String()
+
+	for _, f := range featureMinVersions {
+		// read the env var without marking it as checked, since the feature is
+		// still migrated by whichever handler is responsible for it.
+		val, err := getEnv(ctx, c.client, c.node.Spec.Template.Spec, ComponentCalicoNode, containerCalicoNode, f.envVar)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			continue
+		}
+		if enabled, err := strconv.ParseBool(*val); err != nil || !enabled {
+			continue
+		}
+
+		min := gv.Must(gv.NewVersion(f.version))
+		if c.calicoVersion.LessThan(min) {
+			if err := c.incompatible(ErrIncompatibleCluster{
+				err:       fmt.Sprintf("%s detection requires Calico >= %s, but calico-node is running v%s", f.feature, f.version, c.calicoVersion),
+				component: ComponentCalicoNode,
+				fix:       fmt.Sprintf("remove %s or upgrade Calico", f.envVar),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// clusterInformationVersion returns the Calico version recorded in the default ClusterInformation, or
+// nil if there is no ClusterInformation or its version can't be parsed. A ClusterInformation which marks
+// the datastore as not ready is incompatible, as a datastore migration is still in progress.
+func clusterInformationVersion(ctx context.Context, c *components) (*gv.Version, error) {
+	ci, err := c.getClusterInformation(ctx)
+	if err != nil || ci == nil {
+		return nil, err
+	}
+
+	if ci.Spec.DatastoreReady != nil && !*ci.Spec.DatastoreReady {
+		if err := c.incompatible(ErrIncompatibleCluster{
+			err:       "ClusterInformation marks the datastore as not ready",
+			component: ComponentClusterInfo,
+			fix:       "wait for the in-progress datastore migration to complete before migrating",
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if ci.Spec.CalicoVersion == "" {
+		return nil, nil
+	}
+	m := imageVersionRegexp.FindStringSubmatch(ci.Spec.CalicoVersion)
+	if m == nil {
+		c.warn(ComponentClusterInfo, "could not parse Calico version %s", ci.Spec.CalicoVersion)
+		return nil, nil
+	}
+	v, err := gv.NewVersion(m[1])
+	if err != nil {
+		c.warn(ComponentClusterInfo, "could not parse Calico version %s", ci.Spec.CalicoVersion)
+		return nil, nil
+	}
+	return v, nil
+}
+
+// handleVariant detects whether the existing install is Calico or Tigera Secure Enterprise, using the
+// variant recorded in the default ClusterInformation and falling back to the calico-node image.
+// The variant is only recorded on the components, for handlers of features specific to one variant.
+func handleVariant(ctx context.Context, c *components, _ *operatorv1.Installation) error {
+	c.variant = operatorv1.Calico
+
+	ci, err := c.getClusterInformation(ctx)
+	if err != nil {
+		return err
+	}
+	if ci != nil && ci.Spec.Variant != "" {
+		c.variant = operatorv1.ProductVariant(ci.Spec.Variant)
+		return nil
+	}
+
+	if node := getContainer(c.node.Spec.Template.Spec, containerCalicoNode); node != nil && strings.Contains(node.Image, "cnx-node") {
+		c.variant = operatorv1.TigeraSecureEnterprise
+	}
+	return nil
+}
+
+// getClusterInformation returns the default ClusterInformation, or nil if there is none. It is only
+// fetched once, later calls return the same ClusterInformation.
+func (c *components) getClusterInformation(ctx context.Context) (*crdv1.ClusterInformation, error) {
+	if c.clusterInfoFetched {
+		return c.clusterInfo, nil
+	}
+	ci := &crdv1.ClusterInformation{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: "default"}, ci); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get ClusterInformation: %v", err)
+		}
+		ci = nil
+	}
+	c.clusterInfo, c.clusterInfoFetched = ci, true
+	return ci, nil
+}
@@ -0,0 +1,200 @@
+package convert
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	operatorv1 "github.com/tigera/operator/api/v1"
+	"github.com/tigera/operator/pkg/apis"
+	crdv1 "github.com/tigera/operator/pkg/apis/crd.projectcalico.org/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("calico version", func() {
+	DescribeTable("detectCalicoVersion", func(image, expected string) {
+		v := detectCalicoVersion(image)
+		if expected == "" {
+			Expect(v).To(BeNil())
+			return
+		}
+		Expect(v).ToNot(BeNil())
+		Expect(v.String()).To(Equal(expected))
+	},
+		Entry("full version", "calico/node:v3.16.0", "3.16.0"),
+		Entry("no v prefix", "calico/node:3.16.1", "3.16.1"),
+		Entry("no patch version", "calico/node:v3.16", "3.16.0"),
+		Entry("dev build", "calico/node:v3.17.0-0.dev-12-gabcdef", "3.17.0"),
+		Entry("registry with port", "registry.local:5000/calico/node:v3.15.2", "3.15.2"),
+		Entry("tag and digest", "quay.io/calico/node:v3.16.0@sha256:0123456789abcdef", "3.16.0"),
+		Entry("digest only", "quay.io/calico/node@sha256:0123456789abcdef", ""),
+		Entry("registry with port and no tag", "registry.local:5000/calico/node", ""),
+		Entry("master", "calico/node:master", ""),
+		Entry("latest", "calico/node:latest", ""),
+	)
+
+	Context("feature gating", func() {
+		var (
+			comps = emptyComponents()
+			i     = &operatorv1.Installation{}
+		)
+
+		BeforeEach(func() {
+			Expect(apis.AddToScheme(kscheme.Scheme)).To(Succeed())
+			comps = emptyComponents()
+			comps.client = fake.NewFakeClientWithScheme(kscheme.Scheme)
+			i = &operatorv1.Installation{}
+		})
+
+		It("should error if BPF is enabled on a version which does not support it", func() {
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:v3.12.1"
+			comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_BPFENABLED", Value: "true"}}
+			err := handleCalicoVersion(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("BPF detection requires Calico >= v3.13.0, but calico-node is running v3.12.1"))
+		})
+
+		It("should record a manual step for an unsupported feature in lenient mode", func() {
+			comps.mode = ModeLenient
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:v3.12.1"
+			comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_BPFENABLED", Value: "true"}}
+			Expect(handleCalicoVersion(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.report.ManualSteps).To(HaveLen(1))
+			Expect(comps.report.ManualSteps[0].Issue).To(ContainSubstring("BPF detection requires Calico >= v3.13.0"))
+		})
+
+		It("should not error if BPF is enabled on a version which supports it", func() {
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:v3.16.0"
+			comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_BPFENABLED", Value: "true"}}
+			Expect(handleCalicoVersion(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.calicoVersion.String()).To(Equal("3.16.0"))
+			Expect(comps.report.CalicoVersion).To(Equal("v3.16.0"))
+			Expect(comps.node.uncheckedVars()).To(ContainElement("calico-node/FELIX_BPFENABLED"))
+		})
+
+		It("should not error if a feature is disabled", func() {
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:v3.12.1"
+			comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_WIREGUARDENABLED", Value: "false"}}
+			Expect(handleCalicoVersion(ctx, &comps, i)).ToNot(HaveOccurred())
+		})
+
+		It("should warn and skip the checks if the version is unknown", func() {
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:master"
+			comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_BPFENABLED", Value: "true"}}
+			Expect(handleCalicoVersion(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.calicoVersion).To(BeNil())
+			Expect(comps.report.CalicoVersion).To(BeEmpty())
+			Expect(comps.report.Warnings).To(HaveLen(1))
+		})
+	})
+
+	Context("ClusterInformation", func() {
+		var (
+			comps = emptyComponents()
+			i     = &operatorv1.Installation{}
+		)
+
+		clusterInfo := func(spec crdv1.ClusterInformationSpec) *crdv1.ClusterInformation {
+			return &crdv1.ClusterInformation{
+				ObjectMeta: metav1.ObjectMeta{Name: "default"},
+				Spec:       spec,
+			}
+		}
+
+		BeforeEach(func() {
+			Expect(apis.AddToScheme(kscheme.Scheme)).To(Succeed())
+			comps = emptyComponents()
+			i = &operatorv1.Installation{}
+		})
+
+		It("should prefer the version from ClusterInformation", func() {
+			comps.client = fake.NewFakeClientWithScheme(kscheme.Scheme, clusterInfo(crdv1.ClusterInformationSpec{CalicoVersion: "v3.12.1"}))
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:master"
+			comps.node.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FELIX_BPFENABLED", Value: "true"}}
+			err := handleCalicoVersion(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("BPF detection requires Calico >= v3.13.0, but calico-node is running v3.12.1"))
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+
+		It("should warn if the image and ClusterInformation versions differ", func() {
+			comps.client = fake.NewFakeClientWithScheme(kscheme.Scheme, clusterInfo(crdv1.ClusterInformationSpec{CalicoVersion: "v3.16.1"}))
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:v3.16.0"
+			Expect(handleCalicoVersion(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.calicoVersion.String()).To(Equal("3.16.1"))
+			Expect(comps.report.Warnings).To(HaveLen(1))
+			Expect(comps.report.Warnings[0].Component).To(Equal(ComponentClusterInfo))
+		})
+
+		It("should fall back to the image tag if ClusterInformation has no version", func() {
+			comps.client = fake.NewFakeClientWithScheme(kscheme.Scheme, clusterInfo(crdv1.ClusterInformationSpec{ClusterGUID: "abc123"}))
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:v3.16.0"
+			Expect(handleCalicoVersion(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.calicoVersion.String()).To(Equal("3.16.0"))
+			Expect(comps.report.Warnings).To(BeEmpty())
+		})
+
+		It("should error if the datastore is not ready", func() {
+			ready := false
+			comps.client = fake.NewFakeClientWithScheme(kscheme.Scheme, clusterInfo(crdv1.ClusterInformationSpec{
+				CalicoVersion:  "v3.16.0",
+				DatastoreReady: &ready,
+			}))
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:v3.16.0"
+			err := handleCalicoVersion(ctx, &comps, i)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("datastore as not ready"))
+		})
+
+		It("should record a manual step if the datastore is not ready in lenient mode", func() {
+			comps.mode = ModeLenient
+			ready := false
+			comps.client = fake.NewFakeClientWithScheme(kscheme.Scheme, clusterInfo(crdv1.ClusterInformationSpec{
+				CalicoVersion:  "v3.16.0",
+				DatastoreReady: &ready,
+			}))
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:v3.16.0"
+			Expect(handleCalicoVersion(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.calicoVersion.String()).To(Equal("3.16.0"))
+			Expect(comps.report.ManualSteps).To(ConsistOf(ManualStep{
+				Component: ComponentClusterInfo,
+				Issue:     "ClusterInformation marks the datastore as not ready",
+				Step:      "wait for the in-progress datastore migration to complete before migrating",
+			}))
+		})
+
+		It("should only fetch the ClusterInformation once", func() {
+			ci := clusterInfo(crdv1.ClusterInformationSpec{CalicoVersion: "v3.16.0", Variant: string(operatorv1.TigeraSecureEnterprise)})
+			comps.client = fake.NewFakeClientWithScheme(kscheme.Scheme, ci)
+			comps.node.Spec.Template.Spec.Containers[0].Image = "calico/node:v3.16.0"
+			Expect(handleCalicoVersion(ctx, &comps, i)).ToNot(HaveOccurred())
+
+			// the variant is still read from the ClusterInformation fetched by handleCalicoVersion.
+			Expect(comps.client.Delete(ctx, ci)).To(Succeed())
+			Expect(handleVariant(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.variant).To(Equal(operatorv1.TigeraSecureEnterprise))
+		})
+
+		DescribeTable("variant", func(ci *crdv1.ClusterInformation, image string, expected operatorv1.ProductVariant) {
+			if ci != nil {
+				comps.client = fake.NewFakeClientWithScheme(kscheme.Scheme, ci)
+			} else {
+				comps.client = fake.NewFakeClientWithScheme(kscheme.Scheme)
+			}
+			comps.node.Spec.Template.Spec.Containers[0].Image = image
+			Expect(handleVariant(ctx, &comps, i)).ToNot(HaveOccurred())
+			Expect(comps.variant).To(Equal(expected))
+		},
+			Entry("calico image", nil, "calico/node:v3.16.0", operatorv1.Calico),
+			Entry("enterprise image", nil, "quay.io/tigera/cnx-node:v3.4.0", operatorv1.TigeraSecureEnterprise),
+			Entry("enterprise ClusterInformation", clusterInfo(crdv1.ClusterInformationSpec{Variant: "TigeraSecureEnterprise"}),
+				"registry.local/node:v3.4.0", operatorv1.TigeraSecureEnterprise),
+			Entry("calico ClusterInformation", clusterInfo(crdv1.ClusterInformationSpec{Variant: "Calico"}),
+				"calico/node:v3.16.0", operatorv1.Calico),
+		)
+	})
+})
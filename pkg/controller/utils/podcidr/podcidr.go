@@ -0,0 +1,153 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package podcidr holds the pod network CIDR detection and checks which are shared by the
+// installation controller and the migration of existing installs.
+package podcidr
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+var kubeadmPodSubnet = regexp.MustCompile(`podSubnet: (.*)`)
+
+// AKSClusterLabel is set by AKS on every node in the cluster.
+const AKSClusterLabel = "kubernetes.azure.com/cluster"
+
+// ExtractKubeadmCIDRs looks through the config map and parses lines starting with 'podSubnet'.
+func ExtractKubeadmCIDRs(kubeadmConfig *v1.ConfigMap) ([]string, error) {
+	var line []string
+	var foundCIDRs []string
+
+	// Look through the config map for a line starting with 'podSubnet', then assign the right variable
+	// according to the IP family of the matching string.
+	for _, l := range kubeadmConfig.Data {
+		if line = kubeadmPodSubnet.FindStringSubmatch(l); line != nil {
+			break
+		}
+	}
+
+	if len(line) == 0 {
+		return foundCIDRs, fmt.Errorf("kubeadm configuration is missing required podSubnet field")
+	}
+
+	// IPv4 and IPv6 CIDRs will be separated by a comma in a dual stack setup.
+	for _, cidr := range strings.Split(line[1], ",") {
+		_, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parsed successfully. Add it to the list.
+		foundCIDRs = append(foundCIDRs, cidr)
+	}
+
+	return foundCIDRs, nil
+}
+
+// ExtractAKSCIDRs returns the pod CIDRs allocated to the AKS nodes in the list. AKS does not expose
+// the cluster's pod CIDR through the API, but with kubenet each node is allocated a range from it.
+func ExtractAKSCIDRs(nodes *v1.NodeList) ([]string, error) {
+	var foundCIDRs []string
+	seen := map[string]bool{}
+	for _, node := range nodes.Items {
+		if _, ok := node.Labels[AKSClusterLabel]; !ok {
+			continue
+		}
+
+		cidrs := node.Spec.PodCIDRs
+		if len(cidrs) == 0 && node.Spec.PodCIDR != "" {
+			cidrs = []string{node.Spec.PodCIDR}
+		}
+		for _, cidr := range cidrs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return nil, fmt.Errorf("node %s has an invalid pod CIDR: %s", node.Name, err)
+			}
+			if !seen[cidr] {
+				seen[cidr] = true
+				foundCIDRs = append(foundCIDRs, cidr)
+			}
+		}
+	}
+	return foundCIDRs, nil
+}
+
+// CIDRWithinCIDR checks that all IPs in the pool passed in are within the
+// passed in CIDR
+func CIDRWithinCIDR(cidr, pool string) bool {
+	// an IPv4-mapped IPv6 CIDR can contain an IPv4 address, so compare the families first.
+	if IsIPv6CIDR(cidr) != IsIPv6CIDR(pool) {
+		return false
+	}
+	_, cNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	_, pNet, err := net.ParseCIDR(pool)
+	if err != nil {
+		return false
+	}
+	ipMin := pNet.IP
+	pOnes, _ := pNet.Mask.Size()
+	cOnes, _ := cNet.Mask.Size()
+
+	// If the cidr contains the network (1st) address of the pool and the
+	// prefix on the pool is larger than or equal to the cidr prefix (the pool size is
+	// smaller than the cidr) then the pool network is within the cidr network.
+	if cNet.Contains(ipMin) && pOnes >= cOnes {
+		return true
+	}
+	return false
+}
+
+// CheckPoolWithinCIDRs returns an error if the pool is not within one of the platform's pod network
+// CIDRs of the same IP family.
+func CheckPoolWithinCIDRs(pool string, platformCIDRs []string) error {
+	within := false
+	sameFamily := false
+	for _, c := range platformCIDRs {
+		if IsIPv6CIDR(c) != IsIPv6CIDR(pool) {
+			continue
+		}
+		sameFamily = true
+		within = within || CIDRWithinCIDR(c, pool)
+	}
+	if !sameFamily {
+		return fmt.Errorf("IPPool %v is an %s pool but the platform's configured pod network CIDR(s) %v have no %s CIDR",
+			pool, Family(pool), platformCIDRs, Family(pool))
+	}
+	if !within {
+		return fmt.Errorf("IPPool %v is not within the platform's configured pod network CIDR(s) %v", pool, platformCIDRs)
+	}
+	return nil
+}
+
+// IsIPv6CIDR returns true if the cidr is written in IPv6 notation. The family is taken from the notation
+// since IPv4-mapped IPv6 CIDRs parse to an IPv4 address.
+func IsIPv6CIDR(cidr string) bool {
+	return strings.Contains(cidr, ":")
+}
+
+// Family returns the name of the IP family of the cidr.
+func Family(cidr string) string {
+	if IsIPv6CIDR(cidr) {
+		return "IPv6"
+	}
+	return "IPv4"
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podcidr
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("kubeadm pod-network-cidr detection", func() {
+	It("should parse podSubnet if it exists", func() {
+		var data = `
+networking:
+  dnsDomain: cluster.local
+  podSubnet: 192.168.0.0/16
+  serviceSubnet: 10.96.0.0/12`
+		cidr, err := ExtractKubeadmCIDRs(&corev1.ConfigMap{
+			Data: map[string]string{
+				"ClusterConfiguration": data,
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cidr).To(Equal([]string{"192.168.0.0/16"}))
+	})
+
+	It("should error if podSubnet is missing", func() {
+		var data = `
+networking:
+  dnsDomain: cluster.local
+  serviceSubnet: 10.96.0.0/12`
+		_, err := ExtractKubeadmCIDRs(&corev1.ConfigMap{
+			Data: map[string]string{
+				"ClusterConfiguration": data,
+			},
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("pod CIDR checks", func() {
+	DescribeTable("CIDRWithinCIDR",
+		func(CIDR, pool string, expectedResult bool) {
+			if expectedResult {
+				Expect(CIDRWithinCIDR(CIDR, pool)).To(BeTrue(), "Expected pool %s to be within CIDR %s", pool, CIDR)
+			} else {
+				Expect(CIDRWithinCIDR(CIDR, pool)).To(BeFalse(), "Expected pool %s to not be within CIDR %s", pool, CIDR)
+			}
+		},
+
+		Entry("Default as CIDR and pool", "192.168.0.0/16", "192.168.0.0/16", true),
+		Entry("Pool larger than CIDR should fail", "192.168.0.0/16", "192.168.0.0/15", false),
+		Entry("Pool larger than CIDR should fail", "192.168.2.0/24", "192.168.0.0/16", false),
+		Entry("Non overlapping CIDR and pool should fail", "192.168.0.0/16", "172.168.0.0/16", false),
+		Entry("CIDR with smaller pool", "192.168.0.0/16", "192.168.2.0/24", true),
+		Entry("IPv6 matching CIDR and pool", "fd00:1234::/32", "fd00:1234::/32", true),
+		Entry("IPv6 Pool larger than CIDR should fail", "fd00:1234::/32", "fd00:1234::/31", false),
+		Entry("IPv6 Pool larger than CIDR should fail", "fd00:1234:5600::/40", "fd00:1234::/32", false),
+		Entry("IPv6 Non overlapping CIDR and pool should fail", "fd00:1234::/32", "fd00:5678::/32", false),
+		Entry("IPv6 CIDR with smaller pool", "fd00:1234::/32", "fd00:1234:5600::/40", true),
+		Entry("IPv4-mapped IPv6 pool within IPv4 CIDR should fail", "192.168.0.0/16", "::ffff:192.168.1.0/120", false),
+		Entry("IPv4 pool within IPv4-mapped IPv6 CIDR should fail", "::ffff:192.168.0.0/112", "192.168.1.0/24", false),
+	)
+
+	It("should check a pool against the CIDRs of its own family", func() {
+		Expect(CheckPoolWithinCIDRs("192.168.1.0/24", []string{"fd00::/48", "192.168.0.0/16"})).To(Succeed())
+		Expect(CheckPoolWithinCIDRs("fd00::/64", []string{"fd00::/48", "192.168.0.0/16"})).To(Succeed())
+	})
+
+	It("should error if the pool is not within a CIDR of its family", func() {
+		err := CheckPoolWithinCIDRs("10.0.0.0/24", []string{"fd00::/48", "192.168.0.0/16"})
+		Expect(err).To(MatchError("IPPool 10.0.0.0/24 is not within the platform's configured pod network CIDR(s) [fd00::/48 192.168.0.0/16]"))
+	})
+
+	It("should error if there is no CIDR of the pool's family", func() {
+		err := CheckPoolWithinCIDRs("fd00::/64", []string{"192.168.0.0/16"})
+		Expect(err).To(MatchError("IPPool fd00::/64 is an IPv6 pool but the platform's configured pod network CIDR(s) [192.168.0.0/16] have no IPv6 CIDR"))
+	})
+})
+
+var _ = Describe("AKS pod CIDR detection", func() {
+	aksNode := func(name string, cidrs ...string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{AKSClusterLabel: "MC_rg_cluster_eastus"},
+			},
+			Spec: corev1.NodeSpec{PodCIDRs: cidrs},
+		}
+	}
+
+	It("should return the pod CIDRs of AKS nodes", func() {
+		nodes := &corev1.NodeList{Items: []corev1.Node{
+			aksNode("aks-nodepool1-0", "10.244.0.0/24"),
+			aksNode("aks-nodepool1-1", "10.244.1.0/24", "fd00:10:244:1::/64"),
+			{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Spec: corev1.NodeSpec{PodCIDR: "172.16.0.0/24"}},
+		}}
+		cidrs, err := ExtractAKSCIDRs(nodes)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cidrs).To(Equal([]string{"10.244.0.0/24", "10.244.1.0/24", "fd00:10:244:1::/64"}))
+	})
+
+	It("should fall back to the single pod CIDR", func() {
+		node := aksNode("aks-nodepool1-0")
+		node.Spec.PodCIDR = "10.244.0.0/24"
+		cidrs, err := ExtractAKSCIDRs(&corev1.NodeList{Items: []corev1.Node{node}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cidrs).To(Equal([]string{"10.244.0.0/24"}))
+	})
+
+	It("should error on an invalid pod CIDR", func() {
+		_, err := ExtractAKSCIDRs(&corev1.NodeList{Items: []corev1.Node{aksNode("aks-nodepool1-0", "10.244.0.0")}})
+		Expect(err).To(HaveOccurred())
+	})
+})
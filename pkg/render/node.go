@@ -650,8 +650,32 @@ func (c *nodeComponent) nodeDaemonset(cniCfgMap *v1.ConfigMap) *apps.DaemonSet {
 
 // cniDirectories returns the binary and network config directories for the configured platform.
 func (c *nodeComponent) cniDirectories() (string, string, string) {
-	var cniBinDir, cniNetDir, cniLogDir string
-	switch c.cr.KubernetesProvider {
+	cniNetDir, cniBinDir := CNIDirectories(c.cr.KubernetesProvider)
+	cniLogDir := "/var/log/calico/cni"
+	return cniNetDir, cniBinDir, cniLogDir
+}
+
+// DefaultFlexVolumePath returns the location of the flexvolume plugins for the given provider, which is
+// used when the Installation does not set one.
+func DefaultFlexVolumePath(provider operator.Provider) string {
+	switch provider {
+	case operator.ProviderOpenShift:
+		// In OpenShift 4.x, the location for flexvolume plugins has changed.
+		// See: https://bugzilla.redhat.com/show_bug.cgi?id=1667606#c5
+		return "/etc/kubernetes/kubelet-plugins/volume/exec/"
+	case operator.ProviderGKE:
+		return "/home/kubernetes/flexvolume/"
+	case operator.ProviderAKS:
+		return "/etc/kubernetes/volumeplugins/"
+	default:
+		return "/usr/libexec/kubernetes/kubelet-plugins/volume/exec/"
+	}
+}
+
+// CNIDirectories returns the network config and binary directories which are rendered for the given provider.
+func CNIDirectories(provider operator.Provider) (string, string) {
+	var cniBinDir, cniNetDir string
+	switch provider {
 	case operator.ProviderOpenShift:
 		cniNetDir = "/var/run/multus/cni/net.d"
 		cniBinDir = "/var/lib/cni/bin"
@@ -664,8 +688,7 @@ func (c *nodeComponent) cniDirectories() (string, string, string) {
 		cniBinDir = "/opt/cni/bin"
 		cniNetDir = "/etc/cni/net.d"
 	}
-	cniLogDir = "/var/log/calico/cni"
-	return cniNetDir, cniBinDir, cniLogDir
+	return cniNetDir, cniBinDir
 }
 
 // nodeVolumes creates the node's volumes.